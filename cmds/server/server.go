@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	log "github.com/duglin/dlog"
 	"github.com/duglin/xreg-github/registry"
@@ -17,6 +18,7 @@ var Verbose = 2
 var doDelete *bool
 var doRecreate *bool
 var doVerify *bool
+var retentionInterval *time.Duration
 var firstTimeDB = true
 
 func InitDB() {
@@ -86,6 +88,8 @@ func main() {
 	doDelete = flag.Bool("delete", false, "Delete DB and exit")
 	doRecreate = flag.Bool("recreate", false, "Recreate DB, then run")
 	doVerify = flag.Bool("verify", false, "Exit after loading - for testing")
+	retentionInterval = flag.Duration("retention", time.Hour,
+		"How often to apply version retention policies (0=never)")
 	flag.IntVar(&Verbose, "v", Verbose, "Verbose level")
 	flag.Parse()
 
@@ -102,5 +106,9 @@ func main() {
 	// registry.DB_InitFunc = InitDB
 	InitDB()
 
+	if *retentionInterval > 0 {
+		registry.StartRetentionSweeper(*retentionInterval)
+	}
+
 	registry.NewServer(Port).Serve()
 }
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	// log "github.com/duglin/dlog"
+	"github.com/duglin/xreg-github/registry"
+	"github.com/spf13/cobra"
+)
+
+// The connection info for one of the servers/registries that the CLI
+// can talk to
+type Profile struct {
+	Server   string `json:"server,omitempty"`
+	Registry string `json:"registry,omitempty"` // Name, w/o the "reg-"
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	CACert   string `json:"cacert,omitempty"` // File with the CA bundle
+}
+
+type Config struct {
+	Current  string              `json:"current,omitempty"`
+	Profiles map[string]*Profile `json:"profiles,omitempty"`
+}
+
+// The names of the Profile fields that "xr config set" can change
+var ProfileKeys = []string{"server", "registry", "token", "username",
+	"password", "cacert"}
+
+// The name of the profile to use instead of the config's current one
+var ProfileName = EnvString("XR_PROFILE", "")
+
+// The registry (on the Server) to use, "" means the default one
+var Registry = EnvString("XR_REGISTRY", "")
+
+// The profile in use, once ApplyProfile is called. Never nil.
+var ActiveProfile = &Profile{}
+
+// The HTTP client used to talk to the Server
+var Client = http.DefaultClient
+
+// Returns the name of the CLI's config file. XR_CONFIG can override it.
+func ConfigFile() string {
+	if file := os.Getenv("XR_CONFIG"); file != "" {
+		return file
+	}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		ErrStop(err, "Error finding the home directory: %s", err)
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "xr", "config")
+}
+
+// Reads the CLI's config file. It's ok if it doesn't exist yet.
+func LoadConfig() *Config {
+	config := &Config{}
+	fileName := ConfigFile()
+
+	buf, err := os.ReadFile(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		Error("Error reading config file %q: %s", fileName, err)
+	}
+	if len(buf) > 0 {
+		err = json.Unmarshal(buf, config)
+		ErrStop(err, "Error parsing config file %q: %s", fileName, err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]*Profile{}
+	}
+	return config
+}
+
+// Writes the CLI's config file. It can have credentials in it so only
+// the user can read it.
+func (config *Config) Save() {
+	fileName := ConfigFile()
+
+	err := os.MkdirAll(filepath.Dir(fileName), 0700)
+	ErrStop(err, "Error creating directory for %q: %s", fileName, err)
+
+	buf, _ := json.MarshalIndent(config, "", "  ")
+	err = os.WriteFile(fileName, append(buf, '\n'), 0600)
+	ErrStop(err, "Error writing config file %q: %s", fileName, err)
+}
+
+// Sets up the Server, Registry and HTTP Client based on the selected
+// profile (--profile, XR_PROFILE or the config's current one). Explicit
+// -s/--registry flags and XR_SERVER/XR_REGISTRY env vars win over the
+// profile's values. If the server is a different one then the profile
+// isn't used at all, so its credentials aren't sent to the wrong place.
+func ApplyProfile() {
+	config := LoadConfig()
+
+	name := ProfileName
+	if name == "" {
+		name = config.Current
+	}
+	if name == "" {
+		return
+	}
+
+	profile := config.Profiles[name]
+	if profile == nil {
+		Error("Profile %q not found in %q", name, ConfigFile())
+	}
+
+	if Server == "" {
+		Server = profile.Server
+	} else if strings.TrimSuffix(Server, "/") !=
+		strings.TrimSuffix(profile.Server, "/") {
+		return
+	}
+	ActiveProfile = profile
+
+	if Registry == "" {
+		Registry = profile.Registry
+	}
+
+	if profile.CACert != "" {
+		buf, err := os.ReadFile(profile.CACert)
+		ErrStop(err, "Error reading CA bundle %q: %s", profile.CACert, err)
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			Error("No certificates found in CA bundle %q", profile.CACert)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		Client = &http.Client{Transport: transport}
+	}
+}
+
+// Returns the URL of the Registry on the Server, w/o a trailing "/"
+func ServerURL() string {
+	url := strings.TrimSuffix(Server, "/")
+	if Registry != "" {
+		url += "/reg-" + Registry
+	}
+	return url
+}
+
+// Adds the active profile's credentials to "req", but only if it's going
+// to our Server so they're never sent to someone else (e.g. model imports)
+func AddAuth(req *http.Request) {
+	if Server == "" || !IsServerURL(req.URL) {
+		return
+	}
+
+	if ActiveProfile.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+ActiveProfile.Token)
+	} else if ActiveProfile.Username != "" {
+		req.SetBasicAuth(ActiveProfile.Username, ActiveProfile.Password)
+	}
+}
+
+// Returns true if "u" has the same scheme, host and port as our Server.
+// A missing port is the scheme's default one.
+func IsServerURL(u *url.URL) bool {
+	srv, err := url.Parse(Server)
+	if err != nil || srv.Host == "" {
+		return false
+	}
+
+	return strings.EqualFold(u.Scheme, srv.Scheme) &&
+		strings.EqualFold(u.Hostname(), srv.Hostname()) &&
+		urlPort(u) == urlPort(srv)
+}
+
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if strings.EqualFold(u.Scheme, "https") {
+		return "443"
+	}
+	return "80"
+}
+
+func addConfigCmd(parent *cobra.Command) {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the profiles used to talk to servers",
+		Long: "Manage the profiles used to talk to servers. They're " +
+			"saved in $XDG_CONFIG_HOME/xr/config (~/.config/xr/config " +
+			"by default), XR_CONFIG can name a different file.",
+		// Don't load the profile, these commands need to work even if
+		// it's missing or broken
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the profiles, \"*\" marks the current one",
+		Args:  cobra.NoArgs,
+		Run:   configListFunc,
+	}
+	configCmd.AddCommand(listCmd)
+
+	useCmd := &cobra.Command{
+		Use:   "use NAME",
+		Short: "Make NAME the current profile",
+		Args:  cobra.ExactArgs(1),
+		Run:   configUseFunc,
+	}
+	configCmd.AddCommand(useCmd)
+
+	setCmd := &cobra.Command{
+		Use:   "set KEY=VALUE ...",
+		Short: "Set values in a profile, creating it if needed",
+		Long: "Set values in the current profile, or the one named by " +
+			"--profile, creating it if needed. An empty VALUE removes " +
+			"it. KEY is one of: " + strings.Join(ProfileKeys, ", ") + ".",
+		Args: cobra.MinimumNArgs(1),
+		Run:  configSetFunc,
+	}
+	configCmd.AddCommand(setCmd)
+
+	parent.AddCommand(configCmd)
+}
+
+func configListFunc(cmd *cobra.Command, args []string) {
+	config := LoadConfig()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tNAME\tSERVER\tREGISTRY\tAUTH")
+	for _, name := range registry.SortedKeys(config.Profiles) {
+		profile := config.Profiles[name]
+
+		current, auth := "", ""
+		if name == config.Current {
+			current = "*"
+		}
+		if profile.Token != "" {
+			auth = "token"
+		} else if profile.Username != "" {
+			auth = "basic (" + profile.Username + ")"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", current, name,
+			profile.Server, profile.Registry, auth)
+	}
+	tw.Flush()
+}
+
+func configUseFunc(cmd *cobra.Command, args []string) {
+	config := LoadConfig()
+
+	if config.Profiles[args[0]] == nil {
+		Error("Profile %q not found", args[0])
+	}
+	config.Current = args[0]
+	config.Save()
+}
+
+func configSetFunc(cmd *cobra.Command, args []string) {
+	config := LoadConfig()
+
+	name := ProfileName
+	if name == "" {
+		name = config.Current
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	profile := config.Profiles[name]
+	if profile == nil {
+		profile = &Profile{}
+		config.Profiles[name] = profile
+	}
+	if config.Current == "" {
+		config.Current = name
+	}
+
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		if !found {
+			Error("Invalid argument %q, must be of the form KEY=VALUE", arg)
+		}
+
+		switch key {
+		case "server":
+			profile.Server = value
+		case "registry":
+			profile.Registry = strings.TrimPrefix(value, "reg-")
+		case "token":
+			profile.Token = value
+		case "username":
+			profile.Username = value
+		case "password":
+			profile.Password = value
+		case "cacert":
+			if value != "" {
+				abs, err := filepath.Abs(value)
+				ErrStop(err, "Error with file %q: %s", value, err)
+				value = abs
+			}
+			profile.CACert = value
+		default:
+			Error("Unknown key %q, must be one of: %s", key,
+				strings.Join(ProfileKeys, ", "))
+		}
+	}
+
+	config.Save()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsServerURL(t *testing.T) {
+	tests := []struct {
+		server string
+		url    string
+		exp    bool
+	}{
+		{"http://localhost:8181", "http://localhost:8181/model", true},
+		{"http://localhost:8181/", "http://localhost:8181", true},
+		{"http://localhost:8181", "HTTP://LOCALHOST:8181/x", true},
+		{"https://example.com", "https://example.com:443/x", true},
+		{"http://example.com:80", "http://example.com/x", true},
+
+		{"http://localhost:8181", "http://localhost:81810/x", false},
+		{"http://localhost:8181", "http://localhost:8182/x", false},
+		{"http://localhost", "http://localhost.evil.com/x", false},
+		{"http://localhost:8181", "https://localhost:8181/x", false},
+		{"http://example.com", "http://example.com:8080/x", false},
+		{"http://example.com", "http://user@evil.com/x", false},
+		{"", "http://localhost:8181/x", false},
+		{"localhost:8181", "http://localhost:8181/x", false},
+	}
+
+	defer func(save string) { Server = save }(Server)
+
+	for _, test := range tests {
+		Server = test.server
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatalf("%s: %s", test.url, err)
+		}
+		if got := IsServerURL(u); got != test.exp {
+			t.Fatalf("%s vs %s: Exp: %v Got: %v", test.server, test.url,
+				test.exp, got)
+		}
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	t.Setenv("XR_CONFIG", filepath.Join(t.TempDir(), "config"))
+
+	defer func(server, reg, name string, profile *Profile) {
+		Server, Registry, ProfileName, ActiveProfile = server, reg, name,
+			profile
+	}(Server, Registry, ProfileName, ActiveProfile)
+
+	config := &Config{
+		Current: "p1",
+		Profiles: map[string]*Profile{
+			"p1": {Server: "http://localhost:8181/", Registry: "r1",
+				Token: "tok"},
+			"p2": {Server: "https://example.com", Username: "me",
+				Password: "pw"},
+		},
+	}
+	config.Save()
+
+	tests := []struct {
+		profile  string // ProfileName, "" means the config's current one
+		server   string // Server before ApplyProfile
+		expSrv   string
+		expReg   string
+		expAuth  string // Authorization header sent to expSrv
+		otherURL string // Some other URL, which never gets credentials
+	}{
+		{"", "", "http://localhost:8181/", "r1", "Bearer tok",
+			"http://localhost:8282/"},
+		{"", "http://localhost:8181", "http://localhost:8181", "r1",
+			"Bearer tok", "http://example.com/"},
+		{"p2", "", "https://example.com", "", "Basic bWU6cHc=",
+			"http://example.com/"},
+		// A different server means the profile isn't used at all
+		{"", "http://localhost:9999", "http://localhost:9999", "", "",
+			"http://localhost:8181/"},
+	}
+
+	for _, test := range tests {
+		Server, Registry, ProfileName = test.server, "", test.profile
+		ActiveProfile = &Profile{}
+		ApplyProfile()
+
+		if Server != test.expSrv || Registry != test.expReg {
+			t.Fatalf("%v:\nExp: %s %s\nGot: %s %s", test, test.expSrv,
+				test.expReg, Server, Registry)
+		}
+
+		req, _ := http.NewRequest("GET", test.expSrv+"/x", nil)
+		AddAuth(req)
+		if got := req.Header.Get("Authorization"); got != test.expAuth {
+			t.Fatalf("%v:\nExp: %q\nGot: %q", test, test.expAuth, got)
+		}
+
+		req, _ = http.NewRequest("GET", test.otherURL, nil)
+		AddAuth(req)
+		if got := req.Header.Get("Authorization"); got != "" {
+			t.Fatalf("%v: %s got credentials: %q", test, test.otherURL, got)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// log "github.com/duglin/dlog"
+	"github.com/duglin/xreg-github/registry"
+	"github.com/spf13/cobra"
+)
+
+// Flags for the commands that can upload a Resource's document
+func addDocumentFlags(cmd *cobra.Command) {
+	cmd.Flags().String("content-type", "", "Content type of the document, "+
+		"by default it's based on the file name or contents")
+	cmd.Flags().String("setdefaultversionid", "", "Make this Version the "+
+		"default one (vID, \"request\" or \"null\")")
+}
+
+// Sends the document in "fileName" ("-" for stdin) as the body of the
+// request, with "values" as xRegistry HTTP headers since the metadata can't
+// be in the body. Then shows the metadata of the Version that was written.
+func SendDocument(cmd *cobra.Command, method string, path string,
+	fileName string, values []AttrValue) {
+
+	var buf []byte
+	var err error
+
+	if fileName == "-" {
+		buf, err = io.ReadAll(os.Stdin)
+		ErrStop(err, "Error reading from stdin: %s", err)
+	} else {
+		buf, err = os.ReadFile(fileName)
+		ErrStop(err, "Error reading file %q: %s", fileName, err)
+	}
+
+	headers := AttrHeaders(values)
+	if ct, _ := cmd.Flags().GetString("content-type"); ct != "" {
+		headers["Content-Type"] = ct
+	} else {
+		headers["Content-Type"] = ContentType(fileName, buf)
+	}
+
+	code, resHeaders, resBody := HTTPSend(method, path+QueryFlags(cmd),
+		headers, buf)
+	if code/100 != 2 {
+		Error("%s", strings.TrimSpace(string(resBody)))
+	}
+
+	// The response is the document, so go get the metadata instead
+	location := resHeaders.Get("Content-Location")
+	if location == "" {
+		Error("Missing the \"Content-Location\" header in the response")
+	}
+	fmt.Printf("%s", HTTPMust("GET", location+"$meta", nil))
+}
+
+// Saves the document of the Resource or Version at "path" in "fileName",
+// "-" means stdout
+func GetDocument(path string, fileName string) {
+	buf := HTTPMust("GET", path, nil)
+
+	if fileName == "-" {
+		os.Stdout.Write(buf)
+		return
+	}
+
+	err := os.WriteFile(fileName, buf, 0644)
+	ErrStop(err, "Error writing file %q: %s", fileName, err)
+}
+
+// Returns the content type of a document, based on its file name's
+// extension if possible, otherwise on its contents
+func ContentType(fileName string, buf []byte) string {
+	if ct := mime.TypeByExtension(filepath.Ext(fileName)); ct != "" {
+		return ct
+	}
+	return http.DetectContentType(buf)
+}
+
+// Converts attribute values into xRegistry HTTP headers. Only scalars
+// (xRegistry-NAME) and the entries of maps (xRegistry-NAME-KEY) can be
+// headers. Deleted attributes have a value of "null".
+func AttrHeaders(values []AttrValue) map[string]string {
+	headers := map[string]string{}
+
+	for _, av := range values {
+		name := av.Path.Top()
+		if av.Path.Len() == 2 && av.Path.Parts[1].Index < 0 {
+			name += "-" + av.Path.Parts[1].Text
+		} else if av.Path.Len() != 1 {
+			Error("Attribute %q can't be set along with the document, use "+
+				"\"update\" instead", av.Path.UI())
+		}
+
+		val := "null"
+		if !registry.IsNil(av.Value) {
+			if !registry.IsScalar(registry.GoToOurType(av.Value)) {
+				Error("Attribute %q can't be set to a non-scalar value "+
+					"along with the document, use \"update\" instead",
+					av.Path.UI())
+			}
+			val = fmt.Sprintf("%v", av.Value)
+		}
+
+		headers["xRegistry-"+name] = val
+	}
+
+	return headers
+}
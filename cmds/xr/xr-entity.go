@@ -0,0 +1,443 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	// log "github.com/duglin/dlog"
+	"github.com/duglin/xreg-github/registry"
+	"github.com/spf13/cobra"
+)
+
+// The entities, at one level of the Registry, that a set of commands (e.g.
+// "xr groups ...") works on
+type EntityType struct {
+	Name     string // e.g. Group
+	CollPath string // Path to a collection of them, for the usage text
+	IDName   string // e.g. gID, for the usage text
+	Parts    int    // Number of parts in the path of one of them
+	Docs     bool   // Can have a document, if its Resource type allows it
+}
+
+var GroupType = &EntityType{
+	Name:     "Group",
+	CollPath: "GROUPS",
+	IDName:   "gID",
+	Parts:    2,
+}
+
+var ResourceType = &EntityType{
+	Name:     "Resource",
+	CollPath: "GROUPS/gID/RESOURCES",
+	IDName:   "rID",
+	Parts:    4,
+	Docs:     true,
+}
+
+var VersionType = &EntityType{
+	Name:     "Version",
+	CollPath: "GROUPS/gID/RESOURCES/rID/versions",
+	IDName:   "vID",
+	Parts:    6,
+	Docs:     true,
+}
+
+// Adds the list, get, add, update and delete commands for "et" to "parent"
+func addEntityCmds(parent *cobra.Command, et *EntityType) {
+	entityPath := et.CollPath + "/" + et.IDName
+
+	listCmd := &cobra.Command{
+		Use:   "list " + et.CollPath,
+		Short: "List the " + et.Name + "s in a collection",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entityListFunc(et, cmd, args)
+		},
+	}
+	addOutputFlags(listCmd, false)
+	parent.AddCommand(listCmd)
+
+	getCmd := &cobra.Command{
+		Use:   "get " + entityPath,
+		Short: "Retrieve a " + et.Name,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entityGetFunc(et, cmd, args)
+		},
+	}
+	addOutputFlags(getCmd, et.Docs)
+	parent.AddCommand(getCmd)
+
+	addCmd := &cobra.Command{
+		Use:   "add " + entityPath + " [ attributePath[=value] ... ]",
+		Short: "Add a new " + et.Name,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entityAddFunc(et, cmd, args)
+		},
+	}
+	addBodyFlags(addCmd)
+	if et.Docs {
+		addDocumentFlags(addCmd)
+		addCmd.Flags().String("document", "", "File with the "+et.Name+
+			"'s document, \"-\" for stdin")
+	}
+	if et == VersionType {
+		addCmd.Use = "add " + et.CollPath + "[/" + et.IDName + "] " +
+			"[ attributePath[=value] ... ]"
+		addCmd.Long = "Add a new Version. If the vID isn't provided then " +
+			"the server will pick one."
+	}
+	parent.AddCommand(addCmd)
+
+	updateCmd := &cobra.Command{
+		Use:   "update " + entityPath + " [ attributePath[=value | -] ... ]",
+		Short: "Update an existing " + et.Name,
+		Long: "Update an existing " + et.Name + ". Just the specified " +
+			"attributes are changed unless --replace is used, in which " +
+			"case the " + et.Name + " is replaced with the --data or " +
+			"--file value. Values are parsed as JSON if possible, so use " +
+			"quotes (e.g. labels.v='\"1\"') to force a string. \"null\" " +
+			"is a string too, use \"attributePath-\" to delete an " +
+			"attribute.",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entityUpdateFunc(et, cmd, args)
+		},
+	}
+	addBodyFlags(updateCmd)
+	updateCmd.Flags().Bool("replace", false, "Replace the whole "+et.Name)
+	updateCmd.Flags().Int("epoch", -1, "Fail if the "+et.Name+"'s epoch "+
+		"isn't this value")
+	parent.AddCommand(updateCmd)
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete " + entityPath,
+		Short: "Delete a " + et.Name,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entityDeleteFunc(et, cmd, args)
+		},
+	}
+	deleteCmd.Flags().Int("epoch", -1, "Fail if the "+et.Name+"'s epoch "+
+		"isn't this value")
+	parent.AddCommand(deleteCmd)
+
+	if et.Docs {
+		putCmd := &cobra.Command{
+			Use: "put " + entityPath + " --file FILE " +
+				"[ attributePath[=value | -] ... ]",
+			Short: "Upload the document of a " + et.Name,
+			Long: "Upload the document of a " + et.Name + ", creating " +
+				"it if needed. Attribute values are sent as xRegistry " +
+				"HTTP headers, so only scalars and map entries " +
+				"(e.g. labels.NAME=VALUE) are allowed.",
+			Args: cobra.MinimumNArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				entityPutFunc(et, cmd, args)
+			},
+		}
+		putCmd.Flags().StringP("file", "f", "", "File with the "+et.Name+
+			"'s document, \"-\" for stdin")
+		addDocumentFlags(putCmd)
+		parent.AddCommand(putCmd)
+	}
+}
+
+func addBodyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("data", "d", "", "JSON to send to the server")
+	cmd.Flags().StringP("file", "f", "", "File with the JSON to send to "+
+		"the server, \"-\" for stdin")
+	cmd.Flags().Bool("nested", false, "Include nested collections")
+}
+
+// Checks that "path" points to one of "et"'s entities, or to a collection
+// of them if "coll" is true. Returns the cleaned up path.
+func (et *EntityType) CheckPath(path string, coll bool) string {
+	path = strings.Trim(path, "/")
+	parts := strings.Split(path, "/")
+
+	exp, usage := et.Parts, et.CollPath+"/"+et.IDName
+	if coll {
+		exp, usage = et.Parts-1, et.CollPath
+	}
+
+	if len(parts) != exp || (exp >= 5 && parts[4] != "versions") ||
+		strings.Contains("/"+path+"/", "//") {
+		Error("Invalid %s path %q, must be of the form: %s", et.Name, path,
+			usage)
+	}
+	return path
+}
+
+// Returns true if the entity at "path" has a document, which is up to its
+// Resource type's "hasdocument". Unknown types return false and are left
+// for the server to complain about.
+func (et *EntityType) HasDocument(path string) bool {
+	if !et.Docs {
+		return false
+	}
+
+	parts := strings.Split(path, "/")
+	gm := ServerModel().FindGroupModel(parts[0])
+	if gm == nil || gm.Resources[parts[2]] == nil {
+		return false
+	}
+	return gm.Resources[parts[2]].GetHasDocument()
+}
+
+// Returns the URL path of the metadata of the entity at "path"
+func (et *EntityType) MetaPath(path string) string {
+	if et.HasDocument(path) {
+		return path + "$meta"
+	}
+	return path
+}
+
+// Stops if the entity at "path" can't have a document
+func (et *EntityType) CheckHasDocument(path string) {
+	if !et.HasDocument(path) {
+		parts := strings.Split(path, "/")
+		Error("The %q Resource type doesn't have documents",
+			parts[0]+"/"+parts[2])
+	}
+}
+
+func entityListFunc(et *EntityType, cmd *cobra.Command, args []string) {
+	path := et.CheckPath(args[0], true)
+	PrintResult(cmd, path, HTTPMust("GET", path, nil))
+}
+
+func entityGetFunc(et *EntityType, cmd *cobra.Command, args []string) {
+	path := et.CheckPath(args[0], false)
+
+	output, _ := cmd.Flags().GetString("output")
+	if !IsOutputFormat(output) && et.HasDocument(path) {
+		GetDocument(path, output)
+		return
+	}
+
+	PrintResult(cmd, path, HTTPMust("GET", et.MetaPath(path), nil))
+}
+
+func entityAddFunc(et *EntityType, cmd *cobra.Command, args []string) {
+	// A new Version can be added to the "versions" collection, in which
+	// case the server picks its ID. That's done via a POST to the Resource.
+	newVersion := et == VersionType &&
+		len(strings.Split(strings.Trim(args[0], "/"), "/")) == et.Parts-1
+	path := et.CheckPath(args[0], newVersion)
+	values := ParseAttrValues(args[1:])
+
+	method, target := "PUT", path
+	if newVersion {
+		method, target = "POST", strings.TrimSuffix(path, "/versions")
+	} else {
+		code, buf := HTTPDo("GET", et.MetaPath(path), nil)
+		if code == 200 {
+			Error("%s %q already exists", et.Name, "/"+path)
+		} else if code != 404 {
+			Error("%s", strings.TrimSpace(string(buf)))
+		}
+	}
+
+	body := ReadBody(cmd)
+
+	if document, _ := cmd.Flags().GetString("document"); document != "" {
+		et.CheckHasDocument(path)
+		if body != nil {
+			Error("--document can't be used with --data or --file, use " +
+				"attribute values for the metadata instead")
+		}
+		SendDocument(cmd, method, target, document, values)
+		return
+	}
+
+	if body == nil {
+		body = map[string]any{}
+	}
+	SetAttrValues(body, values)
+
+	fmt.Printf("%s", HTTPMust(method, et.MetaPath(target)+QueryFlags(cmd),
+		ToBody(body)))
+}
+
+func entityPutFunc(et *EntityType, cmd *cobra.Command, args []string) {
+	path := et.CheckPath(args[0], false)
+	values := ParseAttrValues(args[1:])
+
+	file, _ := cmd.Flags().GetString("file")
+	if file == "" {
+		Error("A document is required, use --file")
+	}
+	et.CheckHasDocument(path)
+
+	SendDocument(cmd, "PUT", path, file, values)
+}
+
+func entityUpdateFunc(et *EntityType, cmd *cobra.Command, args []string) {
+	path := et.CheckPath(args[0], false)
+	values := ParseAttrValues(args[1:])
+	replace, _ := cmd.Flags().GetBool("replace")
+
+	body := ReadBody(cmd)
+	if body == nil {
+		if replace {
+			Error("--replace needs either --data or --file")
+		}
+		if len(values) == 0 {
+			Error("Nothing to update, provide attribute values, --data " +
+				"or --file")
+		}
+		body = PatchBody(et.MetaPath(path), values)
+	} else {
+		SetAttrValues(body, values)
+	}
+
+	if cmd.Flags().Changed("epoch") {
+		body["epoch"], _ = cmd.Flags().GetInt("epoch")
+	}
+
+	method := "PATCH"
+	if replace {
+		method = "PUT"
+	}
+	fmt.Printf("%s", HTTPMust(method, et.MetaPath(path)+QueryFlags(cmd),
+		ToBody(body)))
+}
+
+func entityDeleteFunc(et *EntityType, cmd *cobra.Command, args []string) {
+	path := et.CheckPath(args[0], false)
+	if cmd.Flags().Changed("epoch") {
+		epoch, _ := cmd.Flags().GetInt("epoch")
+		path += fmt.Sprintf("?epoch=%d", epoch)
+	}
+	HTTPMust("DELETE", path, nil)
+}
+
+// Returns the query parameters for the --nested and --setdefaultversionid
+// flags, for the commands that have them
+func QueryFlags(cmd *cobra.Command) string {
+	params := []string{}
+	if nested, _ := cmd.Flags().GetBool("nested"); nested {
+		params = append(params, "nested")
+	}
+	if cmd.Flags().Changed("setdefaultversionid") {
+		vID, _ := cmd.Flags().GetString("setdefaultversionid")
+		params = append(params, "setdefaultversionid="+url.QueryEscape(vID))
+	}
+
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + strings.Join(params, "&")
+}
+
+// Returns the JSON object from the --data or --file flag, nil if neither
+// was used
+func ReadBody(cmd *cobra.Command) map[string]any {
+	data, _ := cmd.Flags().GetString("data")
+	file, _ := cmd.Flags().GetString("file")
+
+	var buf []byte
+	var err error
+
+	switch {
+	case data != "" && file != "":
+		Error("Only one of --data or --file is allowed")
+	case file == "-":
+		buf, err = io.ReadAll(os.Stdin)
+		ErrStop(err, "Error reading from stdin: %s", err)
+	case file != "":
+		buf, err = os.ReadFile(file)
+		ErrStop(err, "Error reading file %q: %s", file, err)
+	case data != "":
+		buf = []byte(data)
+	default:
+		return nil
+	}
+
+	body := map[string]any{}
+	if err := registry.Unmarshal(buf, &body); err != nil {
+		Error("Error parsing the JSON: %s", err)
+	}
+	return body
+}
+
+func ToBody(obj map[string]any) []byte {
+	buf, err := json.Marshal(obj)
+	ErrStop(err, "Error serializing the JSON: %s", err)
+	return buf
+}
+
+type AttrValue struct {
+	Path  *registry.PropPath
+	Value any // nil means delete it
+}
+
+// Parses "name=value" and "name-" (delete) arguments. Values that are valid
+// JSON (e.g. numbers, booleans and objects) are used as is, anything else
+// is treated as a string. That includes "null", only "name-" deletes.
+func ParseAttrValues(args []string) []AttrValue {
+	values := []AttrValue{}
+
+	for _, arg := range args {
+		// Note: foo= and foo are equivalent
+		// Note: foo- means delete it
+		path, value, found := strings.Cut(arg, "=")
+		if len(path) == 0 {
+			Error("Missing an attribute path on %q", arg)
+		}
+
+		var val any = value
+		del := false
+		if path, del = strings.CutSuffix(path, "-"); del {
+			if found {
+				Error("Using both \"-\" and \"=\" on %q isn't allowed", arg)
+			}
+			val = nil
+		} else if tmp := any(nil); json.Unmarshal([]byte(value), &tmp) == nil &&
+			tmp != nil {
+			val = tmp
+		}
+
+		if len(path) == 0 {
+			Error("Missing an attribute path on %q", arg)
+		}
+		pp, err := registry.PropPathFromUI(path)
+		if err != nil {
+			Error("Invalid attribute path %q: %s", path, err)
+		}
+
+		values = append(values, AttrValue{Path: pp, Value: val})
+	}
+
+	return values
+}
+
+func SetAttrValues(obj map[string]any, values []AttrValue) {
+	for _, av := range values {
+		err := registry.ObjectSetProp(obj, av.Path, av.Value)
+		ErrStop(err, "Error setting %q: %s", av.Path.UI(), err)
+	}
+}
+
+// Applies "values" to the current entity at "path" and returns just the
+// top-level attributes that were changed, which is what a PATCH needs.
+// Deleted ones are included with a value of null.
+func PatchBody(path string, values []AttrValue) map[string]any {
+	current := map[string]any{}
+	err := registry.Unmarshal(HTTPMust("GET", path, nil), &current)
+	ErrStop(err, "Error parsing the server's response: %s", err)
+
+	SetAttrValues(current, values)
+
+	body := map[string]any{}
+	for _, av := range values {
+		body[av.Path.Top()] = current[av.Path.Top()]
+	}
+	return body
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	// log "github.com/duglin/dlog"
+	"github.com/duglin/xreg-github/registry"
+	"github.com/spf13/cobra"
+)
+
+func addExportCmds(parent *cobra.Command) {
+	exportCmd := &cobra.Command{
+		Use:   "export DIR",
+		Short: "Save the Registry (model, entities and documents) in DIR",
+		Long: "Save the Registry in DIR, which must be empty or not exist. " +
+			"It gets a model.json file, a registry.json file with the " +
+			"Registry's attributes, and a directory per Group, Resource " +
+			"and Version (e.g. dirs/d1/files/f1/versions/v1) with a " +
+			"meta.json file of its attributes. Version directories also " +
+			"have the Version's document, named by its content type (e.g. " +
+			"file.json). Versions are assumed to have been created in " +
+			"the order of their \"createdat\" values.",
+		Args: cobra.ExactArgs(1),
+		Run:  exportFunc,
+	}
+	parent.AddCommand(exportCmd)
+
+	importCmd := &cobra.Command{
+		Use:   "import DIR",
+		Short: "Load a Registry saved by \"xr export\" into the server",
+		Long: "Load a Registry saved by \"xr export\" into the server, " +
+			"which must not have any Groups yet. Its model is replaced, " +
+			"and IDs, the order of Versions, default Versions and " +
+			"version tags are kept.",
+		Args: cobra.ExactArgs(1),
+		Run:  importFunc,
+	}
+	parent.AddCommand(importCmd)
+}
+
+func exportFunc(cmd *cobra.Command, args []string) {
+	dir := args[0]
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		Error("Directory %q isn't empty", dir)
+	}
+
+	buf := HTTPMust("GET", "model", nil)
+	model := &registry.Model{}
+	err := registry.Unmarshal(buf, model)
+	ErrStop(err, "Error parsing the model: %s", err)
+
+	err = os.MkdirAll(dir, 0755)
+	ErrStop(err, "Error creating directory %q: %s", dir, err)
+	fileName := filepath.Join(dir, registry.ExportModelFile)
+	err = os.WriteFile(fileName, buf, 0644)
+	ErrStop(err, "Error writing %q: %s", fileName, err)
+
+	write := func(ee *registry.ExportEntity, singular string) {
+		err := registry.WriteExportEntity(dir, ee, singular)
+		ErrStop(err)
+	}
+
+	write(&registry.ExportEntity{
+		Level: 0,
+		Object: registry.ExportObject(GetObject(""), 0, "",
+			registry.SortedKeys(model.Groups)),
+	}, "")
+
+	for _, gPlural := range registry.SortedKeys(model.Groups) {
+		gm := model.Groups[gPlural]
+		groups := GetObject(gPlural)
+
+		for _, gID := range registry.SortedKeys(groups) {
+			gPath := gPlural + "/" + gID
+			write(&registry.ExportEntity{
+				Level: 1,
+				Path:  gPath,
+				Object: registry.ExportObject(groups[gID].(map[string]any),
+					1, "", registry.SortedKeys(gm.Resources)),
+			}, "")
+
+			for _, rPlural := range registry.SortedKeys(gm.Resources) {
+				exportResources(gm.Resources[rPlural],
+					gPath+"/"+rPlural, write)
+			}
+		}
+	}
+}
+
+// Exports the Resources (and their Versions) in the collection at "path"
+func exportResources(rm *registry.ResourceModel, path string,
+	write func(ee *registry.ExportEntity, singular string)) {
+
+	singular := rm.Singular
+	hasDoc := rm.GetHasDocument()
+	resources := GetObject(path)
+
+	for _, rID := range registry.SortedKeys(resources) {
+		rPath := path + "/" + rID
+		rObj := registry.ExportObject(resources[rID].(map[string]any), 2,
+			singular, []string{"versions"})
+		versions := GetObject(rPath + "/versions")
+
+		for _, vID := range registry.SortedKeys(versions) {
+			vPath := rPath + "/versions/" + vID
+			vObj := registry.ExportObject(versions[vID].(map[string]any), 3,
+				singular, nil)
+			ee := &registry.ExportEntity{Level: 3, Path: vPath, Object: vObj}
+
+			// Documents that live elsewhere are just referenced
+			_, isURL := vObj[singular+"url"]
+			_, isProxy := vObj[singular+"proxyurl"]
+			if hasDoc && !isURL && !isProxy {
+				ee.Document = HTTPMust("GET", vPath, nil)
+				if _, ok := vObj["contenttype"]; !ok && len(ee.Document) == 0 {
+					ee.Document = nil
+				}
+			}
+			write(ee, singular)
+		}
+
+		write(&registry.ExportEntity{
+			Level:      2,
+			Path:       rPath,
+			Object:     rObj,
+			VersionIDs: VersionOrder(versions, rObj),
+		}, singular)
+	}
+}
+
+// Returns the IDs of the Versions in "versions", oldest first. The server
+// doesn't say what order they were created in so assume it's the order of
+// their "createdat" values, except that if the Resource ("rObj") isn't
+// sticky then its default Version must be the newest one.
+func VersionOrder(versions map[string]any, rObj map[string]any) []string {
+	vIDs := registry.SortedKeys(versions)
+	createdAt := func(vID string) string {
+		str, _ := versions[vID].(map[string]any)["createdat"].(string)
+		return str
+	}
+	sort.SliceStable(vIDs, func(i, j int) bool {
+		return createdAt(vIDs[i]) < createdAt(vIDs[j])
+	})
+
+	if rObj["stickydefaultversion"] != true {
+		if defID, ok := rObj["defaultversionid"].(string); ok {
+			for i, vID := range vIDs {
+				if vID == defID {
+					vIDs = append(append(vIDs[:i:i], vIDs[i+1:]...), defID)
+					break
+				}
+			}
+		}
+	}
+	return vIDs
+}
+
+// GETs the entity, or collection, at "path" from the server
+func GetObject(path string) map[string]any {
+	obj := map[string]any{}
+	err := registry.Unmarshal(HTTPMust("GET", path, nil), &obj)
+	ErrStop(err, "Error parsing server response for %q: %s", path, err)
+	return obj
+}
+
+func importFunc(cmd *cobra.Command, args []string) {
+	dir := args[0]
+
+	model, err := registry.ReadExportModel(dir)
+	ErrStop(err)
+
+	// Only load into an empty Registry, otherwise IDs and the order of
+	// Versions couldn't be kept
+	for key, val := range GetObject("") {
+		if count, ok := val.(float64); ok && count > 0 &&
+			strings.HasSuffix(key, "count") {
+			Error("The Registry isn't empty")
+		}
+	}
+
+	buf, err := json.Marshal(model)
+	ErrStop(err, "Error serializing the model: %s", err)
+	HTTPMust("PUT", "model", buf)
+
+	// The model (after the server's defaults are applied) tells us which
+	// Resources have documents
+	model = &registry.Model{}
+	err = registry.Unmarshal(HTTPMust("GET", "model", nil), model)
+	ErrStop(err, "Error parsing the model: %s", err)
+
+	err = registry.WalkExportDir(dir, model, func(ee *registry.ExportEntity) error {
+		parts := strings.Split(ee.Path, "/")
+
+		switch ee.Level {
+		case 0:
+			HTTPMust("PATCH", "", ToBody(ee.Object))
+			return nil
+		case 1:
+			HTTPMust("PUT", ee.Path, ToBody(ee.Object))
+			return nil
+		}
+
+		rm := model.Groups[parts[0]].Resources[parts[2]]
+		meta := ""
+		if rm.GetHasDocument() {
+			meta = "$meta"
+		}
+
+		if ee.Level == 3 {
+			if ee.Document != nil {
+				ee.Object[rm.Singular+"base64"] =
+					base64.StdEncoding.EncodeToString(ee.Document)
+			}
+			HTTPMust("PUT", ee.Path+meta, ToBody(ee.Object))
+			return nil
+		}
+
+		// The Resource's own attributes, now that its Versions are there
+		rPath := ee.Path + meta
+		if ee.Object["stickydefaultversion"] == true {
+			vID, _ := ee.Object["defaultversionid"].(string)
+			HTTPMust("POST", rPath+"?setdefaultversionid="+
+				url.QueryEscape(vID), nil)
+		}
+
+		body := map[string]any{}
+		for _, key := range []string{"versiontags", "deprecated"} {
+			if val, ok := ee.Object[key]; ok {
+				body[key] = val
+			}
+		}
+		if len(body) > 0 {
+			HTTPMust("PATCH", rPath, ToBody(body))
+		}
+		return nil
+	})
+	ErrStop(err)
+
+	fmt.Printf("Imported %q\n", dir)
+}
@@ -11,15 +11,7 @@ func addGroupCmd(parent *cobra.Command) {
 		Short: "groups commands",
 	}
 
-	groupAddCmd := &cobra.Command{
-		Use:   "add",
-		Short: "Add a new Group",
-		Run:   groupAddFunc,
-	}
-	groupsCmd.AddCommand(groupAddCmd)
+	addEntityCmds(groupsCmd, GroupType)
 
 	parent.AddCommand(groupsCmd)
 }
-
-func groupAddFunc(cmd *cobra.Command, args []string) {
-}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	// log "github.com/duglin/dlog"
+	"github.com/duglin/xreg-github/registry"
+	"github.com/spf13/cobra"
+)
+
+func addModelCmd(parent *cobra.Command) {
+	modelCmd := &cobra.Command{
+		Use:   "model",
+		Short: "model commands",
+	}
+
+	modelNormalizeCmd := &cobra.Command{
+		Use:   "normalize [ - | FILE... ]",
+		Short: "Parse and resolve imports in an xRegistry model document",
+		Long: "Parse and resolve imports in xRegistry model documents. " +
+			"Each FILE is shown separately unless --merge is used, in " +
+			"which case they're combined into one model, which is then " +
+			"verified. Conflicting definitions in the files are errors.",
+		Run: modelNormalizeFunc,
+	}
+	modelNormalizeCmd.Flags().Bool("merge", false, "Merge the files into "+
+		"one model")
+	modelCmd.AddCommand(modelNormalizeCmd)
+
+	modelVerifyCmd := &cobra.Command{
+		Use:   "verify [ - | FILE... ]",
+		Short: "Parse and verify xRegistry model document",
+		Run:   modelVerifyFunc,
+	}
+	modelCmd.AddCommand(modelVerifyCmd)
+
+	modelCodegenCmd := &cobra.Command{
+		Use:   "codegen [ - | FILE ]",
+		Short: "Generate code for the entities of an xRegistry model document",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   modelCodegenFunc,
+	}
+	modelCodegenCmd.Flags().StringP("lang", "l", "go", "Language to generate")
+	modelCodegenCmd.Flags().StringP("package", "p", "xregistry",
+		"Name of the generated package")
+	modelCmd.AddCommand(modelCodegenCmd)
+
+	modelDiffCmd := &cobra.Command{
+		Use:   "diff [ OLD ] NEW",
+		Short: "Show the safe and breaking changes between two models",
+		Long: "Show the safe and breaking changes between two models. If " +
+			"OLD isn't provided then the server's model is used and its " +
+			"entities are checked against NEW.",
+		Args: cobra.RangeArgs(1, 2),
+		Run:  modelDiffFunc,
+	}
+	modelCmd.AddCommand(modelDiffCmd)
+
+	modelGetCmd := &cobra.Command{
+		Use:   "get",
+		Short: "Retrieve the server's model",
+		Args:  cobra.NoArgs,
+		Run:   modelGetFunc,
+	}
+	modelGetCmd.Flags().String("schema", "", "Format of the model, "+
+		"one of: "+strings.Join(registry.SortedKeys(registry.ModelSerializers),
+		", "))
+	modelGetCmd.Flags().Int("revision", 0, "Get this model revision "+
+		"instead of the latest one")
+	modelCmd.AddCommand(modelGetCmd)
+
+	modelApplyCmd := &cobra.Command{
+		Use:   "apply FILE",
+		Short: "Replace the server's model, after showing the changes",
+		Long: "Replace the server's model with the one in FILE, after " +
+			"processing its imports and verifying it. The changes, and " +
+			"how many existing entities would fail validation, are shown " +
+			"and need to be confirmed unless --yes is used. The update " +
+			"fails if someone else changes the model in the meantime.",
+		Args: cobra.ExactArgs(1),
+		Run:  modelApplyFunc,
+	}
+	modelApplyCmd.Flags().BoolP("yes", "y", false, "Don't ask for "+
+		"confirmation")
+	modelApplyCmd.Flags().Int("epoch", -1, "Fail if the epoch of the "+
+		"server's model isn't this value")
+	modelCmd.AddCommand(modelApplyCmd)
+
+	parent.AddCommand(modelCmd)
+}
+
+func modelNormalizeFunc(cmd *cobra.Command, args []string) {
+	var err error
+	var buf []byte
+
+	merge, _ := cmd.Flags().GetBool("merge")
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	fragments := []map[string]any{}
+	for _, fileName := range args {
+		prefix := ""
+		if len(args) > 1 {
+			prefix = fileName + ": "
+		}
+
+		if fileName == "-" {
+			buf, err = io.ReadAll(os.Stdin)
+			if err != nil {
+				Error("Error reading from stdin: %s", err)
+			}
+			fileName = ""
+		} else {
+			buf = ReadModel(fileName)
+		}
+
+		buf, err = registry.ProcessImports(fileName, buf, true)
+		if err != nil {
+			Error("%s%s", prefix, err)
+		}
+
+		tmp := map[string]any{}
+		err = registry.Unmarshal(buf, &tmp)
+		if err != nil {
+			Error("%s%s", prefix, err)
+		}
+
+		if merge {
+			fragments = append(fragments, tmp)
+			continue
+		}
+
+		if len(args) > 1 {
+			fmt.Printf("%s:\n", fileName)
+		}
+		fmt.Printf("%s\n", registry.ToJSON(tmp))
+	}
+
+	if !merge {
+		return
+	}
+
+	tmp, err := registry.MergeModels(args, fragments)
+	if err != nil {
+		Error(err.Error())
+	}
+
+	// Make sure the fragments added up to a valid model
+	buf, err = json.Marshal(tmp)
+	if err != nil {
+		Error("Error generating JSON: %s", err)
+	}
+	model := &registry.Model{}
+	if err = registry.Unmarshal(buf, model); err == nil {
+		err = model.Verify()
+	}
+	if err != nil {
+		Error("Merged model: %s", err)
+	}
+
+	fmt.Printf("%s\n", registry.ToJSON(tmp))
+}
+
+func modelVerifyFunc(cmd *cobra.Command, args []string) {
+	var buf []byte
+	var err error
+
+	if len(args) == 0 {
+		buf, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			Error("Error reading from stdin: %s", err)
+		}
+		VerifyModel("", buf)
+	}
+
+	for _, fileName := range args {
+		if Verbose {
+			fmt.Printf("%s:\n", fileName)
+		}
+		VerifyModel(fileName, ReadModel(fileName))
+	}
+
+}
+
+func modelCodegenFunc(cmd *cobra.Command, args []string) {
+	lang, _ := cmd.Flags().GetString("lang")
+	pkg, _ := cmd.Flags().GetString("package")
+
+	if lang != "go" {
+		Error("Unsupported language %q, must be one of: go", lang)
+	}
+
+	var buf []byte
+	var err error
+	fileName := ""
+
+	if len(args) == 0 || args[0] == "-" {
+		buf, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			Error("Error reading from stdin: %s", err)
+		}
+	} else {
+		fileName = args[0]
+		buf = ReadModel(fileName)
+	}
+
+	model := VerifyModel(fileName, buf)
+
+	buf, err = registry.Model2Go(model, pkg)
+	if err != nil {
+		Error(err.Error())
+	}
+	fmt.Print(string(buf))
+}
+
+func modelDiffFunc(cmd *cobra.Command, args []string) {
+	oldFile := ""
+	if len(args) == 2 {
+		oldFile = args[0]
+	} else if Server != "" {
+		oldFile = ServerURL() + "/model"
+	} else {
+		Error("No Server address provided. Try either -s, XR_SERVER " +
+			"env var or a profile, or provide both OLD and NEW")
+	}
+	newFile := args[len(args)-1]
+
+	oldModel := VerifyModel(oldFile, ReadModel(oldFile))
+	newModel := VerifyModel(newFile, ReadModel(newFile))
+
+	changes := registry.DiffModels(oldModel, newModel)
+	for _, change := range changes {
+		fmt.Printf("%s\n", change)
+	}
+	if len(changes) == 0 {
+		fmt.Printf("No changes\n")
+	} else if registry.HasBreakingChanges(changes) {
+		fmt.Printf("\nThere are breaking changes\n")
+	}
+
+	if len(args) == 2 {
+		return
+	}
+
+	// Now check the server's data against the new model
+	buf := ReadModel(ServerURL() + "?inline=*")
+	data := map[string]any{}
+	if err := registry.Unmarshal(buf, &data); err != nil {
+		Error("Error parsing the Registry: %s", err)
+	}
+
+	errs := registry.ValidateRegistryData(oldModel, newModel, data)
+	fmt.Printf("\n%d existing entities would fail validation\n", len(errs))
+	for _, err := range errs {
+		fmt.Printf("  %s\n", err)
+	}
+}
+
+func modelGetFunc(cmd *cobra.Command, args []string) {
+	path := "model"
+	next := "?"
+
+	if schema, _ := cmd.Flags().GetString("schema"); schema != "" {
+		path += next + "schema=" + url.QueryEscape(schema)
+		next = "&"
+	}
+	if cmd.Flags().Changed("revision") {
+		revision, _ := cmd.Flags().GetInt("revision")
+		path += fmt.Sprintf("%srevision=%d", next, revision)
+	}
+
+	fmt.Printf("%s", HTTPMust("GET", path, nil))
+}
+
+func modelApplyFunc(cmd *cobra.Command, args []string) {
+	fileName := args[0]
+	newModel := VerifyModel(fileName, ReadModel(fileName))
+
+	// Get the epoch before the model so that if the model changes after
+	// we look at it, the update will fail
+	epoch := ModelEpoch()
+	if cmd.Flags().Changed("epoch") {
+		epoch, _ = cmd.Flags().GetInt("epoch")
+	}
+
+	path := "model"
+	if epoch > 0 {
+		path += fmt.Sprintf("?revision=%d", epoch)
+	}
+	oldModel := VerifyModel("", HTTPMust("GET", path, nil))
+
+	changes := registry.DiffModels(oldModel, newModel)
+	for _, change := range changes {
+		fmt.Printf("%s\n", change)
+	}
+	if len(changes) == 0 {
+		fmt.Printf("No changes\n")
+		return
+	}
+	if registry.HasBreakingChanges(changes) {
+		fmt.Printf("\nThere are breaking changes\n")
+	}
+
+	body, err := json.Marshal(newModel)
+	ErrStop(err, "Error serializing the model: %s", err)
+
+	// Let the server check its entities against the new model
+	result := struct {
+		Violations []string `json:"violations"`
+	}{}
+	buf := HTTPMust("PUT", fmt.Sprintf("model?dryrun&epoch=%d", epoch), body)
+	err = json.Unmarshal(buf, &result)
+	ErrStop(err, "Error parsing server response: %s", err)
+
+	if len(result.Violations) > 0 {
+		fmt.Printf("\n%d existing entities would fail validation\n",
+			len(result.Violations))
+		for _, violation := range result.Violations {
+			fmt.Printf("  %s\n", violation)
+		}
+	}
+
+	if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+		if !Confirm("\nApply these changes?") {
+			Error("Model not applied")
+		}
+	}
+
+	HTTPMust("PUT", fmt.Sprintf("model?epoch=%d", epoch), body)
+	fmt.Printf("Model applied\n")
+}
+
+// The server's model, it's only fetched the first time it's needed
+var serverModel *registry.Model
+
+func ServerModel() *registry.Model {
+	if serverModel == nil {
+		serverModel = &registry.Model{}
+		err := registry.Unmarshal(HTTPMust("GET", "model", nil), serverModel)
+		ErrStop(err, "Error parsing the model: %s", err)
+	}
+	return serverModel
+}
+
+// Returns the epoch of the server's latest model revision
+func ModelEpoch() int {
+	revs := []*registry.ModelRevision{}
+	err := json.Unmarshal(HTTPMust("GET", "model/history", nil), &revs)
+	ErrStop(err, "Error parsing the model history: %s", err)
+
+	if len(revs) == 0 {
+		return 0
+	}
+	return revs[len(revs)-1].Epoch
+}
+
+// Asks the user a yes/no question, the default is "no"
+func Confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Returns the contents of a model file, "fileName" can be a URL
+func ReadModel(fileName string) []byte {
+	var buf []byte
+	var err error
+
+	if strings.HasPrefix(fileName, "http") {
+		var res *http.Response
+		req, err := http.NewRequest("GET", fileName, nil)
+		if err == nil {
+			AddAuth(req)
+			res, err = Client.Do(req)
+		}
+		if err == nil {
+			buf, err = io.ReadAll(res.Body)
+			res.Body.Close()
+
+			if res.StatusCode/100 != 2 {
+				err = fmt.Errorf("Error getting model: %s\n%s",
+					res.Status, string(buf))
+			}
+		}
+		if err != nil {
+			Error("Error reading %q: %s", fileName, err)
+		}
+	} else {
+		buf, err = os.ReadFile(fileName)
+		if err != nil {
+			Error("Error reading %q: %s", fileName, err)
+		}
+	}
+	return buf
+}
+
+func VerifyModel(fileName string, buf []byte) *registry.Model {
+	var err error
+
+	if len(os.Args) > 2 && fileName != "" {
+		fileName += ": "
+	} else {
+		fileName = ""
+	}
+
+	buf, err = registry.ProcessImports(fileName, buf, true)
+	if err != nil {
+		Error("%s%s", fileName, err)
+	}
+
+	model := &registry.Model{}
+
+	if err := registry.Unmarshal(buf, model); err != nil {
+		Error("%s%s", fileName, err)
+	}
+
+	if err := model.Verify(); err != nil {
+		Error("%s%s", fileName, err)
+	}
+
+	return model
+}
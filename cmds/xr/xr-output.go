@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	// log "github.com/duglin/dlog"
+	"github.com/duglin/xreg-github/registry"
+	"github.com/spf13/cobra"
+)
+
+// The values of "--output" that are formats rather than file names
+var OutputFormats = []string{"table", "json", "yaml", "ids"}
+
+// Flags for the commands that show entities. If "docFile" is true then
+// "-o" can also be the name of the file to save the document to.
+func addOutputFlags(cmd *cobra.Command, docFile bool) {
+	help := "Output format (" + strings.Join(OutputFormats, ", ") + ")"
+	if docFile {
+		help += ", or the file to save the document to (\"-\" for stdout)"
+	}
+	cmd.Flags().StringP("output", "o", "", help)
+	cmd.Flags().String("query", "", "Just show the values at this "+
+		"attribute path, \"*\" matches every key (e.g. '*.name')")
+}
+
+// Returns true if the "--output" flag is a format rather than a file name
+func IsOutputFormat(output string) bool {
+	return output == "" || slices.Contains(OutputFormats, output)
+}
+
+// Shows the response ("body") of a GET of "path" based on the "--output"
+// and "--query" flags. With neither, the response is shown as is.
+func PrintResult(cmd *cobra.Command, path string, body []byte) {
+	format, _ := cmd.Flags().GetString("output")
+	query, _ := cmd.Flags().GetString("query")
+
+	if !IsOutputFormat(format) {
+		Error("Unknown output format %q, must be one of: %s", format,
+			strings.Join(OutputFormats, ", "))
+	}
+
+	if format == "" && query == "" {
+		fmt.Printf("%s", body)
+		return
+	}
+
+	var data any
+	err := json.Unmarshal(body, &data)
+	ErrStop(err, "Error parsing server response: %s", err)
+
+	if query != "" {
+		if format == "table" || format == "ids" {
+			Error("--query can't be used with an output format of %q", format)
+		}
+		PrintQuery(format, query, data)
+		return
+	}
+
+	switch format {
+	case "json":
+		fmt.Printf("%s\n", registry.ToJSON(data))
+	case "yaml":
+		fmt.Print(ToYAML(data))
+	case "ids":
+		for _, row := range ResultRows(path, data) {
+			fmt.Printf("%v\n", row["id"])
+		}
+	case "table":
+		PrintTable(path, data)
+	}
+}
+
+// Shows the values in "data" that "query" points to. Scalars are shown
+// as is, one per line, unless a format is asked for.
+func PrintQuery(format string, query string, data any) {
+	pp, err := registry.PropPathFromUI(query)
+	ErrStop(err, "Invalid query %q: %s", query, err)
+
+	vals, found := QueryValues(data, pp)
+	if !found && !strings.Contains(query, "*") {
+		Error("Attribute %q not found", query)
+	}
+
+	for i, val := range vals {
+		switch format {
+		case "json":
+			fmt.Printf("%s\n", registry.ToJSON(val))
+		case "yaml":
+			if i > 0 {
+				fmt.Print("---\n")
+			}
+			fmt.Print(ToYAML(val))
+		default:
+			switch val.(type) {
+			case map[string]any, []any:
+				fmt.Printf("%s\n", registry.ToJSON(val))
+			case string:
+				fmt.Printf("%s\n", val)
+			default:
+				fmt.Printf("%s\n", YAMLScalar(val))
+			}
+		}
+	}
+}
+
+// Returns the level (0=Registry, 1=Group, 2=Resource, 3=Version) of the
+// entities that "path" points to, and whether it's a collection of them
+func PathLevel(path string) (int, bool) {
+	path, _, _ = strings.Cut(path, "?")
+	path = strings.TrimSuffix(strings.Trim(path, "/"), "$meta")
+	if path == "" {
+		return 0, false
+	}
+
+	parts := len(strings.Split(path, "/"))
+	return (parts + 1) / 2, parts%2 == 1
+}
+
+// Returns the entities in "data", sorted by ID, whether it's a single
+// entity or a collection of them
+func ResultRows(path string, data any) []map[string]any {
+	_, coll := PathLevel(path)
+
+	daMap, ok := data.(map[string]any)
+	if !ok {
+		Error("Unexpected response from the server, not a JSON object")
+	}
+
+	if !coll {
+		return []map[string]any{daMap}
+	}
+
+	rows := []map[string]any{}
+	for _, key := range registry.SortedKeys(daMap) {
+		row, ok := daMap[key].(map[string]any)
+		if !ok {
+			Error("Unexpected response from the server, %q isn't a JSON "+
+				"object", key)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Shows the entities in "data" as a table, one row per entity. Resources
+// also show how many Versions they have.
+func PrintTable(path string, data any) {
+	level, _ := PathLevel(path)
+
+	columns := []string{"id", "name", "epoch", "modifiedat"}
+	if level == 2 {
+		columns = append(columns, "versionscount")
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := strings.ToUpper(strings.Join(columns, "\t"))
+	fmt.Fprintln(tw, strings.Replace(header, "VERSIONSCOUNT", "VERSIONS", 1))
+
+	for _, row := range ResultRows(path, data) {
+		vals := []string{}
+		for _, col := range columns {
+			val := ""
+			if v, ok := row[col]; ok && !registry.IsNil(v) {
+				val = fmt.Sprintf("%v", v)
+				if f, ok := v.(float64); ok {
+					val = YAMLScalar(f)
+				}
+			}
+			vals = append(vals, val)
+		}
+		fmt.Fprintln(tw, strings.Join(vals, "\t"))
+	}
+	tw.Flush()
+}
+
+// Returns the values in "data" (parsed JSON) that "pp" points to. A "*"
+// part of the path matches every value of a map (sorted by key) or array.
+// "found" is false if nothing matched.
+func QueryValues(data any, pp *registry.PropPath) ([]any, bool) {
+	if pp == nil || pp.Len() == 0 {
+		return []any{data}, true
+	}
+
+	part := pp.Parts[0]
+	next := []any{}
+
+	if part.Index >= 0 {
+		if daArray, ok := data.([]any); ok && part.Index < len(daArray) {
+			next = append(next, daArray[part.Index])
+		}
+	} else if part.Text == "*" {
+		switch daVal := data.(type) {
+		case map[string]any:
+			for _, key := range registry.SortedKeys(daVal) {
+				next = append(next, daVal[key])
+			}
+		case []any:
+			next = append(next, daVal...)
+		}
+	} else if daMap, ok := data.(map[string]any); ok {
+		if val, ok := daMap[part.Text]; ok {
+			next = append(next, val)
+		}
+	}
+
+	results := []any{}
+	for _, val := range next {
+		vals, found := QueryValues(val, pp.Next())
+		if found {
+			results = append(results, vals...)
+		}
+	}
+
+	return results, len(results) > 0
+}
+
+// Returns the YAML version of "val", which is expected to be parsed JSON
+// (maps, arrays and scalars). Map keys are sorted.
+func ToYAML(val any) string {
+	buf := &strings.Builder{}
+	writeYAML(buf, val, 0)
+	return buf.String()
+}
+
+func writeYAML(buf *strings.Builder, val any, indent int) {
+	prefix := strings.Repeat(" ", indent)
+
+	switch daVal := val.(type) {
+	case map[string]any:
+		if len(daVal) == 0 {
+			buf.WriteString(prefix + "{}\n")
+			return
+		}
+		for _, key := range registry.SortedKeys(daVal) {
+			buf.WriteString(prefix + YAMLScalar(key) + ":")
+			writeYAMLValue(buf, daVal[key], indent+2)
+		}
+	case []any:
+		if len(daVal) == 0 {
+			buf.WriteString(prefix + "[]\n")
+			return
+		}
+		for _, item := range daVal {
+			if IsYAMLScalar(item) {
+				buf.WriteString(prefix + "- " + YAMLScalar(item) + "\n")
+				continue
+			}
+			// Write the item as if it were indented 2 more spaces, then
+			// replace the first of those spaces with the "- "
+			tmp := &strings.Builder{}
+			writeYAML(tmp, item, indent+2)
+			buf.WriteString(prefix + "- " + tmp.String()[indent+2:])
+		}
+	default:
+		buf.WriteString(prefix + YAMLScalar(val) + "\n")
+	}
+}
+
+// Writes the value part of a "key: value" line
+func writeYAMLValue(buf *strings.Builder, val any, indent int) {
+	if IsYAMLScalar(val) {
+		buf.WriteString(" " + YAMLScalar(val) + "\n")
+		return
+	}
+	buf.WriteString("\n")
+	writeYAML(buf, val, indent)
+}
+
+// Scalars, and empty maps and arrays, are written on the same line as
+// their key or "-"
+func IsYAMLScalar(val any) bool {
+	switch daVal := val.(type) {
+	case map[string]any:
+		return len(daVal) == 0
+	case []any:
+		return len(daVal) == 0
+	}
+	return true
+}
+
+func YAMLScalar(val any) string {
+	switch daVal := val.(type) {
+	case nil:
+		return "null"
+	case string:
+		if YAMLNeedsQuotes(daVal) {
+			return strconv.Quote(daVal)
+		}
+		return daVal
+	case float64:
+		return strconv.FormatFloat(daVal, 'f', -1, 64)
+	case map[string]any:
+		return "{}"
+	case []any:
+		return "[]"
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+// Returns true if "str" would be parsed as something other than that
+// string (e.g. a number) or would break the YAML if it's not quoted
+func YAMLNeedsQuotes(str string) bool {
+	if str == "" || strings.TrimSpace(str) != str ||
+		strings.Contains(str, ": ") || strings.Contains(str, " #") ||
+		strings.HasSuffix(str, ":") ||
+		strings.ContainsAny(str[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+
+	for _, ch := range str {
+		if ch < ' ' || ch == '"' || ch == '\\' {
+			return true
+		}
+	}
+
+	switch strings.ToLower(str) {
+	case "true", "false", "null", "~", "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+
+	_, err := strconv.ParseFloat(str, 64)
+	return err == nil
+}
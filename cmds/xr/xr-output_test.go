@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/duglin/xreg-github/registry"
+)
+
+func TestQueryValues(t *testing.T) {
+	data := map[string]any{
+		"id": "reg",
+		"endpoints": map[string]any{
+			"e2": map[string]any{"name": "two", "tags": []any{"a", "b"}},
+			"e1": map[string]any{"name": "one"},
+		},
+	}
+
+	tests := []struct {
+		query string
+		exp   []any
+		found bool
+	}{
+		{"id", []any{"reg"}, true},
+		{"endpoints.e1.name", []any{"one"}, true},
+		{"endpoints.*.name", []any{"one", "two"}, true},
+		{"endpoints.e2.tags[1]", []any{"b"}, true},
+		{"endpoints.e2.tags.*", []any{"a", "b"}, true},
+		{"endpoints.*.tags[0]", []any{"a"}, true},
+		{"endpoints.e3", []any{}, false},
+		{"id.foo", []any{}, false},
+		{"endpoints.e2.tags[5]", []any{}, false},
+		{"*", []any{map[string]any{
+			"e1": map[string]any{"name": "one"},
+			"e2": map[string]any{"name": "two", "tags": []any{"a", "b"}},
+		}, "reg"}, true},
+		{"*.*.name", []any{"one", "two"}, true},
+	}
+
+	for _, test := range tests {
+		pp, err := registry.PropPathFromUI(test.query)
+		if err != nil {
+			t.Fatalf("%s: %s", test.query, err)
+		}
+		got, found := QueryValues(data, pp)
+		if registry.ToJSON(got) != registry.ToJSON(test.exp) || found != test.found {
+			t.Fatalf("%s:\nExp: %v(%v)\nGot: %v(%v)", test.query, test.exp,
+				test.found, got, found)
+		}
+	}
+}
+
+func TestToYAML(t *testing.T) {
+	data := map[string]any{
+		"id":      "reg",
+		"epoch":   1.0,
+		"ratio":   1.5,
+		"ok":      true,
+		"none":    nil,
+		"self":    "http://localhost:8181/",
+		"version": "1.0",
+		"empty":   "",
+		"yes":     "yes",
+		"note":    "a: b",
+		"labels":  map[string]any{},
+		"tags":    []any{"a", 2.0, []any{"x"}},
+		"meta": map[string]any{
+			"list": []any{
+				map[string]any{"a": "1", "b": "two"},
+				map[string]any{"c": []any{}},
+			},
+		},
+	}
+
+	exp := `empty: ""
+epoch: 1
+id: reg
+labels: {}
+meta:
+  list:
+    - a: "1"
+      b: two
+    - c: []
+none: null
+note: "a: b"
+ok: true
+ratio: 1.5
+self: http://localhost:8181/
+tags:
+  - a
+  - 2
+  - - x
+version: "1.0"
+"yes": "yes"
+`
+
+	if got := ToYAML(data); got != exp {
+		t.Fatalf("Exp:\n%s\nGot:\n%s", exp, got)
+	}
+
+	if got := ToYAML("str"); got != "str\n" {
+		t.Fatalf("Got: %q", got)
+	}
+}
+
+func TestPathLevel(t *testing.T) {
+	tests := []struct {
+		path  string
+		level int
+		coll  bool
+	}{
+		{"", 0, false},
+		{"/", 0, false},
+		{"?inline", 0, false},
+		{"dirs", 1, true},
+		{"/dirs/", 1, true},
+		{"dirs/d1", 1, false},
+		{"dirs/d1?inline", 1, false},
+		{"dirs/d1/files", 2, true},
+		{"dirs/d1/files/f1", 2, false},
+		{"dirs/d1/files/f1$meta", 2, false},
+		{"dirs/d1/files/f1/versions", 3, true},
+		{"dirs/d1/files/f1/versions/v1$meta", 3, false},
+	}
+
+	for _, test := range tests {
+		level, coll := PathLevel(test.path)
+		if level != test.level || coll != test.coll {
+			t.Fatalf("%q: Exp: %d(%v) Got: %d(%v)", test.path, test.level,
+				test.coll, level, coll)
+		}
+	}
+}
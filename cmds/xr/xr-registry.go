@@ -2,8 +2,6 @@ package main
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -24,25 +22,30 @@ func addRegistryCmd(parent *cobra.Command) {
 	registryGetCmd.Flags().BoolP("model", "m", false, "Show model")
 	registryGetCmd.Flags().StringArrayP("inline", "i", nil, "Inline value")
 	registryGetCmd.Flags().StringArrayP("filter", "f", nil, "Filter value")
+	addOutputFlags(registryGetCmd, false)
 
 	registrySetCmd := &cobra.Command{
-		Use:   "set attributePath[=value | -]",
-		Short: "Modify an attribute on the Registry entity",
-		Run:   registrySetFunc,
+		Use:   "set attributePath[=value | -] ...",
+		Short: "Modify attributes on the Registry entity",
+		Long: "Modify attributes on the Registry entity. Values are " +
+			"parsed as JSON if possible, so use quotes (e.g. " +
+			"labels.v='\"1\"') to force a string. \"null\" is a string " +
+			"too, use \"attributePath-\" to delete an attribute.",
+		Run: registrySetFunc,
 	}
+	registrySetCmd.Flags().Int("epoch", -1, "Fail if the Registry's epoch "+
+		"isn't this value")
 	registryCmd.AddCommand(registrySetCmd)
 
 	parent.AddCommand(registryCmd)
 }
 
 func registryGetFunc(cmd *cobra.Command, args []string) {
-	if Server == "" {
-		Error("No Server address provided. Try either -s or XR_SERVER env var")
-	}
-
-	url := Server
+	path := ""
+	url := ""
 	if len(args) == 1 {
-		url += "/" + args[0]
+		path = args[0]
+		url = args[0]
 	} else if len(args) > 1 {
 		Error("Too many arguments - just PATH[?QUERY] allowed")
 	}
@@ -70,47 +73,18 @@ func registryGetFunc(cmd *cobra.Command, args []string) {
 		next = "&"
 	}
 
-	res, err := http.Get(url)
-	ErrStop(err, "Error talking to server (%s): %s", Server, err)
-	if err != nil {
-		Error(err.Error())
-	}
-
-	body, err := io.ReadAll(res.Body)
-	ErrStop(err, "Error reading server response: %s", err)
-	fmt.Printf("%s", string(body))
+	PrintResult(cmd, path, HTTPMust("GET", url, nil))
 }
 
 func registrySetFunc(cmd *cobra.Command, args []string) {
-	if Server == "" {
-		Error("No Server address provided. Try either -s or XR_SERVER env var")
-	}
-
 	if len(args) == 0 {
 		Error("Need at least one name=value pair")
 	}
 
-	values := map[string]*string{}
-
-	for _, arg := range args {
-		// Note: foo= and foo are equivalent
-		// Note: foo- means delete it
-		path, value, found := strings.Cut(arg, "=")
-		if len(path) == 0 {
-			Error("Missing an attribute path on %q", arg)
-		}
-		valPtr := &value
-
-		del := false
-		if path, del = strings.CutSuffix(path, "-"); del {
-			if found {
-				Error("Using both \"-\" and \"=\" on %q isn't allowed", arg)
-			}
-			valPtr = nil
-		}
-
-		values[path] = valPtr
+	body := PatchBody("", ParseAttrValues(args))
+	if cmd.Flags().Changed("epoch") {
+		body["epoch"], _ = cmd.Flags().GetInt("epoch")
 	}
 
-	fmt.Printf("Values:\n%v\n", values)
+	fmt.Printf("%s", HTTPMust("PATCH", "", ToBody(body)))
 }
@@ -0,0 +1,17 @@
+package main
+
+import (
+	// log "github.com/duglin/dlog"
+	"github.com/spf13/cobra"
+)
+
+func addResourceCmd(parent *cobra.Command) {
+	resourcesCmd := &cobra.Command{
+		Use:   "resources",
+		Short: "resources commands",
+	}
+
+	addEntityCmds(resourcesCmd, ResourceType)
+
+	parent.AddCommand(resourcesCmd)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+
+	// log "github.com/duglin/dlog"
+	"github.com/duglin/xreg-github/registry"
+	"github.com/spf13/cobra"
+)
+
+func addServeCmd(parent *cobra.Command) {
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run an xRegistry server",
+		Long: "Run an xRegistry server in this process. Its data is kept " +
+			"in a MySQL database, DBHOST, DBPORT, DBUSER and DBPASSWORD " +
+			"say which server to use (localhost:3306 by default), and " +
+			"the database is created if needed. With --data it's kept in " +
+			"a SQLite file in that directory instead, so no MySQL server " +
+			"is needed. --import only applies when the Registry is first " +
+			"created.",
+		Args: cobra.NoArgs,
+		Run:  serveFunc,
+		// The profile is for talking to servers, not running one
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	}
+	serveCmd.Flags().IntP("port", "P", 8080, "Port to listen on")
+	serveCmd.Flags().String("db", "registry", "Name of the database")
+	serveCmd.Flags().String("data", "",
+		"Directory to keep the database in, rather than MySQL")
+	serveCmd.Flags().String("name", "xRegistry", "ID of the Registry")
+	serveCmd.Flags().StringP("model", "m", "", "Model file to load")
+	serveCmd.Flags().String("import", "",
+		"Directory, from \"xr export\", to load the Registry from")
+	serveCmd.MarkFlagsMutuallyExclusive("model", "import")
+
+	parent.AddCommand(serveCmd)
+}
+
+func serveFunc(cmd *cobra.Command, args []string) {
+	port, _ := cmd.Flags().GetInt("port")
+	dbName, _ := cmd.Flags().GetString("db")
+	name, _ := cmd.Flags().GetString("name")
+	modelFile, _ := cmd.Flags().GetString("model")
+	importDir, _ := cmd.Flags().GetString("import")
+
+	if dataDir, _ := cmd.Flags().GetString("data"); dataDir != "" {
+		registry.DBDIR = dataDir
+	}
+
+	OpenDB(dbName)
+
+	reg, err := registry.FindRegistry(nil, name)
+	ErrStop(err, "Error finding Registry %q: %s", name, err)
+
+	if reg == nil {
+		reg, err = registry.NewRegistry(nil, name)
+		ErrStop(err, "Error creating Registry %q: %s", name, err)
+
+		if importDir != "" {
+			if err = reg.ImportDir(importDir); err != nil {
+				reg.Rollback()
+				Error("Error importing %q: %s", importDir, err)
+			}
+		}
+	}
+
+	if modelFile != "" {
+		if err = reg.LoadModelFromFile(modelFile); err != nil {
+			reg.Rollback()
+			Error("Error loading model %q: %s", modelFile, err)
+		}
+	}
+
+	err = reg.Commit()
+	ErrStop(err, "Error saving Registry %q: %s", name, err)
+	registry.DefaultRegDbSID = reg.DbSID
+
+	fmt.Printf("Serving Registry %q on port %d\n", name, port)
+	registry.NewServer(port).Serve()
+}
+
+// Opens the database "name", creating it if needed. The registry's DB
+// funcs panic if they can't talk to the MySQL server so turn that into a
+// normal error.
+func OpenDB(name string) {
+	defer func() {
+		if r := recover(); r != nil {
+			if registry.DBDIR != "" {
+				Error("Error using %q: %v", registry.SQLiteFile(name), r)
+			}
+			Error("Error talking to MySQL (%s:%s): %v", registry.DBHOST,
+				registry.DBPORT, r)
+		}
+	}()
+
+	if !registry.DBExists(name) {
+		err := registry.CreateDB(name)
+		ErrStop(err, "Error creating database %q: %s", name, err)
+	}
+	err := registry.OpenDB(name)
+	ErrStop(err, "Error opening database %q: %s", name, err)
+}
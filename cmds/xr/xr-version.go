@@ -0,0 +1,17 @@
+package main
+
+import (
+	// log "github.com/duglin/dlog"
+	"github.com/spf13/cobra"
+)
+
+func addVersionCmd(parent *cobra.Command) {
+	versionsCmd := &cobra.Command{
+		Use:   "versions",
+		Short: "versions commands",
+	}
+
+	addEntityCmds(versionsCmd, VersionType)
+
+	parent.AddCommand(versionsCmd)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	// log "github.com/duglin/dlog"
+	"github.com/spf13/cobra"
+)
+
+var worked = true
+var Verbose = EnvBool("XR_VERBOSE", false)
+var Server = EnvString("XR_SERVER", "")
+
+func EnvBool(name string, def bool) bool {
+	val := os.Getenv(name)
+	if val != "" {
+		def = strings.EqualFold(val, "true")
+	}
+	return def
+}
+
+func EnvString(name string, def string) string {
+	val := os.Getenv(name)
+	if val != "" {
+		def = val
+	}
+	return def
+}
+
+func ErrStop(err error, prefix ...any) {
+	if err == nil {
+		return
+	}
+
+	str := err.Error()
+	if prefix != nil {
+		str = fmt.Sprintf(prefix[0].(string), prefix[1:]...)
+	}
+	Error(str)
+}
+
+func Error(str string, args ...any) {
+	str = strings.TrimSpace(str) + "\n"
+	fmt.Fprintf(os.Stderr, str, args...)
+	worked = false
+	os.Exit(1)
+}
+
+// Stops the CLI if we don't know which server to talk to
+func CheckServer() {
+	if Server == "" {
+		Error("No Server address provided. Try either -s, XR_SERVER env " +
+			"var or a profile (see \"xr config\")")
+	}
+}
+
+// Sends a request to the server, "path" is relative to the Registry's URL
+// (see ServerURL) unless it's a full URL.
+// Returns the HTTP status code and the body of the response.
+func HTTPDo(method string, path string, body []byte) (int, []byte) {
+	code, _, buf := HTTPSend(method, path, nil, body)
+	return code, buf
+}
+
+// Same as HTTPDo but with extra request headers, and the response headers
+// are returned too. A "Content-Type" header replaces the default of
+// "application/json" used when there's a body.
+func HTTPSend(method string, path string, headers map[string]string,
+	body []byte) (int, http.Header, []byte) {
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	url := path
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		CheckServer()
+		url = ServerURL() + "/" + strings.TrimPrefix(path, "/")
+	}
+	req, err := http.NewRequest(method, url, reader)
+	ErrStop(err, "Error creating request for %q: %s", url, err)
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	AddAuth(req)
+
+	res, err := Client.Do(req)
+	ErrStop(err, "Error talking to server (%s): %s", Server, err)
+	defer res.Body.Close()
+
+	buf, err := io.ReadAll(res.Body)
+	ErrStop(err, "Error reading server response: %s", err)
+	return res.StatusCode, res.Header, buf
+}
+
+// Same as HTTPDo except that any non-2xx response stops the CLI with the
+// error from the server
+func HTTPMust(method string, path string, body []byte) []byte {
+	code, buf := HTTPDo(method, path, body)
+	if code/100 != 2 {
+		Error("%s", strings.TrimSpace(string(buf)))
+	}
+	return buf
+}
+
+func main() {
+	xrCmd := &cobra.Command{
+		Use:   "xr",
+		Short: "xRegistry CLI",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			ApplyProfile()
+		},
+	}
+	xrCmd.CompletionOptions.HiddenDefaultCmd = true
+	xrCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false,
+		"Chatty?")
+	xrCmd.PersistentFlags().StringVarP(&Server, "server", "s", Server,
+		"URL to server")
+	xrCmd.PersistentFlags().StringVar(&ProfileName, "profile", ProfileName,
+		"Config profile to use")
+	xrCmd.PersistentFlags().StringVar(&Registry, "registry", Registry,
+		"Name of the registry on the server (w/o the \"reg-\")")
+
+	addConfigCmd(xrCmd)
+	addExportCmds(xrCmd)
+	addServeCmd(xrCmd)
+	addModelCmd(xrCmd)
+	addRegistryCmd(xrCmd)
+	addGroupCmd(xrCmd)
+	addResourceCmd(xrCmd)
+	addVersionCmd(xrCmd)
+
+	if err := xrCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,451 @@
+package registry
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Go names for the spec defined attributes that are made up of more than
+// one word, see GoName()
+var GoSpecNames = map[string]string{
+	"specversion":          "SpecVersion",
+	"id":                   "ID",
+	"defaultversionid":     "DefaultVersionID",
+	"defaultversionurl":    "DefaultVersionURL",
+	"stickydefaultversion": "StickyDefaultVersion",
+	"isdefault":            "IsDefault",
+	"createdat":            "CreatedAt",
+	"modifiedat":           "ModifiedAt",
+	"contenttype":          "ContentType",
+	"versiontags":          "VersionTags",
+}
+
+// Turns an xRegistry name into an exported Go identifier, e.g.
+// "my_attr" -> "MyAttr"
+func GoName(name string) string {
+	if goName, ok := GoSpecNames[name]; ok {
+		return goName
+	}
+
+	res := strings.Builder{}
+	upper := true
+	for _, ch := range name {
+		if !unicode.IsLetter(ch) && !unicode.IsDigit(ch) {
+			upper = true
+			continue
+		}
+		if upper {
+			ch = unicode.ToUpper(ch)
+			upper = false
+		}
+		res.WriteRune(ch)
+	}
+
+	str := res.String()
+	if str == "" || unicode.IsDigit(rune(str[0])) {
+		str = "X" + str
+	}
+	return str
+}
+
+// Generates the Go source code for package "pkg" from a model
+type GoGenerator struct {
+	Model *Model
+
+	buf      strings.Builder
+	types    map[string]bool // Go type names that are already used
+	usesTime bool
+}
+
+// Generates Go source code (in package "pkg") with a struct for the
+// Registry and for each Group, Resource and Version type in the model,
+// along with a small client that uses them
+func Model2Go(m *Model, pkg string) ([]byte, error) {
+	gen := &GoGenerator{
+		Model: m,
+		types: map[string]bool{"Client": true},
+	}
+	return gen.Generate(pkg)
+}
+
+func (gen *GoGenerator) Printf(format string, args ...any) {
+	fmt.Fprintf(&gen.buf, format, args...)
+}
+
+// Returns the first of "names" that isn't already used, or the last one
+// with a number appended
+func (gen *GoGenerator) TypeName(names ...string) string {
+	for _, name := range names {
+		if !gen.types[name] {
+			gen.types[name] = true
+			return name
+		}
+	}
+	for i := 2; ; i++ {
+		name := names[len(names)-1] + strconv.Itoa(i)
+		if !gen.types[name] {
+			gen.types[name] = true
+			return name
+		}
+	}
+}
+
+// The Go names of the model's types, figured out up-front so that the
+// collections can refer to the types before they're generated
+type goGroup struct {
+	gm        *GroupModel
+	name      string
+	resources []*goResource
+}
+
+type goResource struct {
+	rm      *ResourceModel
+	name    string // e.g. File
+	version string // e.g. FileVersion
+	method  string // e.g. DirFile, used in the names of the client methods
+}
+
+func (gen *GoGenerator) Generate(pkg string) ([]byte, error) {
+	m := gen.Model
+	regName := gen.TypeName("Registry")
+
+	groups := []*goGroup{}
+	for _, gmName := range SortedKeys(m.Groups) {
+		gm := m.Groups[gmName]
+		groups = append(groups, &goGroup{
+			gm:   gm,
+			name: gen.TypeName(GoName(gm.Singular), GoName(gm.Plural)+"Group"),
+		})
+	}
+	for _, group := range groups {
+		for _, rmName := range SortedKeys(group.gm.Resources) {
+			rm := group.gm.Resources[rmName]
+			name := gen.TypeName(GoName(rm.Singular),
+				GoName(group.gm.Singular)+GoName(rm.Singular))
+			group.resources = append(group.resources, &goResource{
+				rm:      rm,
+				name:    name,
+				version: gen.TypeName(name + "Version"),
+				method:  GoName(group.gm.Singular) + GoName(rm.Singular),
+			})
+		}
+	}
+
+	// The Registry
+	colls := []string{}
+	for _, group := range groups {
+		colls = append(colls, gen.CollectionFields(group.gm.Plural, group.name))
+	}
+	gen.Struct(regName, LevelAttributes(m.Attributes, 0), colls...)
+
+	for _, group := range groups {
+		colls = []string{}
+		for _, res := range group.resources {
+			colls = append(colls, gen.CollectionFields(res.rm.Plural, res.name))
+		}
+		gen.Struct(group.name,
+			LevelAttributes(group.gm.GetBaseAttributes(), 1), colls...)
+
+		for _, res := range group.resources {
+			gen.Struct(res.name, res.rm.GetResourceAttributes(),
+				gen.CollectionFields("versions", res.version))
+			gen.Struct(res.version, res.rm.GetVersionAttributes())
+		}
+	}
+
+	gen.Client(regName, groups)
+
+	imports := []string{"bytes", "encoding/json", "fmt", "io", "net/http",
+		"net/url", "strings"}
+	if gen.usesTime {
+		imports = append(imports, "time")
+	}
+
+	src := strings.Builder{}
+	src.WriteString("// Code generated from an xRegistry model. DO NOT EDIT.\n\n")
+	src.WriteString("package " + pkg + "\n\n")
+	src.WriteString("import (\n")
+	for _, imp := range imports {
+		src.WriteString(strconv.Quote(imp) + "\n")
+	}
+	src.WriteString(")\n\n")
+	src.WriteString(gen.buf.String())
+
+	buf, err := format.Source([]byte(src.String()))
+	if err != nil {
+		return nil, fmt.Errorf("Error formatting the generated code: %s", err)
+	}
+	return buf, nil
+}
+
+// Returns the fields for a collection, e.g. "dirs", "dirsurl", "dirscount"
+func (gen *GoGenerator) CollectionFields(plural string, typeName string) string {
+	name := GoName(plural)
+	return fmt.Sprintf("%s map[string]*%s `json:%q`\n"+
+		"%sURL string `json:%q`\n"+
+		"%sCount int `json:%q`\n",
+		name, typeName, plural+",omitempty",
+		name, plural+"url,omitempty",
+		name, plural+"count,omitempty")
+}
+
+// Generates a struct, and any nested structs, for "attrs". "extra" are
+// additional field definitions to add at the end.
+func (gen *GoGenerator) Struct(name string, attrs Attributes, extra ...string) {
+	// "ifvalues" sibling attributes are just more (optional) fields
+	all := Attributes{}
+	var addAttrs func(attrs Attributes, optional bool)
+	addAttrs = func(attrs Attributes, optional bool) {
+		for attrName, attr := range attrs {
+			if attrName == "*" || all[attrName] != nil {
+				continue
+			}
+			if optional && attr.ClientRequired {
+				tmp := *attr
+				tmp.ClientRequired = false
+				attr = &tmp
+			}
+			all[attrName] = attr
+			for _, ifValue := range attr.IfValues {
+				addAttrs(ifValue.SiblingAttributes, true)
+			}
+		}
+	}
+	addAttrs(attrs, false)
+
+	// Spec defined attributes first, then the extensions alphabetically
+	names := []string{}
+	for _, specProp := range OrderedSpecProps {
+		if all[specProp.Name] != nil {
+			names = append(names, specProp.Name)
+		}
+	}
+	for _, attrName := range SortedKeys(all) {
+		if SpecProps[attrName] == nil {
+			names = append(names, attrName)
+		}
+	}
+
+	nested := []func(){}
+	fields := map[string]bool{}
+
+	gen.Printf("type %s struct {\n", name)
+	for _, attrName := range names {
+		attr := all[attrName]
+
+		fieldName := GoName(attrName)
+		for i := 2; fields[fieldName]; i++ {
+			fieldName = GoName(attrName) + strconv.Itoa(i)
+		}
+		fields[fieldName] = true
+
+		if attr.Description != "" {
+			gen.Printf("// %s\n", strings.ReplaceAll(attr.Description,
+				"\n", "\n// "))
+		}
+
+		daType := gen.GoType(attr.Type, attr.Attributes, attr.Item,
+			name+fieldName, &nested)
+		// Optional scalars are pointers so that their zero values can
+		// still be sent
+		tag := attrName
+		if !attr.ClientRequired || daType == "any" {
+			if IsScalar(attr.Type) {
+				daType = "*" + daType
+			}
+			tag += ",omitempty"
+		}
+		gen.Printf("%s %s `json:%q`\n", fieldName, daType, tag)
+	}
+	for _, str := range extra {
+		gen.Printf("%s", str)
+	}
+	gen.Printf("}\n\n")
+
+	for _, fn := range nested {
+		fn()
+	}
+}
+
+// Returns the Go type for an attribute (or item) of "daType". Any nested
+// structs will be named "name" and will be generated by the funcs added to
+// "nested".
+func (gen *GoGenerator) GoType(daType string, attrs Attributes, item *Item,
+	name string, nested *[]func()) string {
+
+	switch daType {
+	case BOOLEAN:
+		return "bool"
+	case DECIMAL:
+		return "float64"
+	case INTEGER:
+		return "int"
+	case UINTEGER:
+		return "uint"
+	case TIMESTAMP:
+		gen.usesTime = true
+		return "time.Time"
+	case STRING, URI, URI_REFERENCE, URI_TEMPLATE, URL, XID:
+		return "string"
+	case ARRAY, MAP:
+		itemType := "any"
+		if item != nil {
+			itemType = gen.GoType(item.Type, item.Attributes, item.Item,
+				name+"Item", nested)
+		}
+		if daType == ARRAY {
+			return "[]" + itemType
+		}
+		return "map[string]" + itemType
+	case OBJECT:
+		hasNames := false
+		for attrName := range attrs {
+			hasNames = hasNames || attrName != "*"
+		}
+		// Objects with just "*" (or nothing) are really maps
+		if !hasNames {
+			if attr := attrs["*"]; attr != nil {
+				return "map[string]" + gen.GoType(attr.Type, attr.Attributes,
+					attr.Item, name+"Item", nested)
+			}
+			return "map[string]any"
+		}
+
+		name = gen.TypeName(name)
+		*nested = append(*nested, func() { gen.Struct(name, attrs) })
+		return "*" + name
+	}
+	return "any"
+}
+
+// Generates the client, with Get, Put, Delete and List methods for each
+// entity type
+func (gen *GoGenerator) Client(regName string, groups []*goGroup) {
+	gen.Printf(`// Client is a small client for a Registry that uses this model
+type Client struct {
+	Server     string
+	HTTPClient *http.Client
+}
+
+func NewClient(server string) *Client {
+	return &Client{
+		Server:     strings.TrimRight(server, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Returns a pointer to "val", useful for setting optional attributes
+func Ptr[T any](val T) *T {
+	return &val
+}
+
+func (c *Client) do(method string, path string, body any, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, c.Server+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	buf, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("%%s %%s: %%s: %%s", method, path, res.Status,
+			strings.TrimSpace(string(buf)))
+	}
+	if result != nil && len(buf) > 0 {
+		return json.Unmarshal(buf, result)
+	}
+	return nil
+}
+
+func (c *Client) GetRegistry() (*%[1]s, error) {
+	result := &%[1]s{}
+	return result, c.do("GET", "/", nil, result)
+}
+
+func (c *Client) PutRegistry(reg *%[1]s) (*%[1]s, error) {
+	result := &%[1]s{}
+	return result, c.do("PUT", "/", reg, result)
+}
+
+`, regName)
+
+	for _, group := range groups {
+		gm := group.gm
+		gPath := fmt.Sprintf(`"/%s/" + url.PathEscape(gID)`, gm.Plural)
+		gen.Entity(GoName(gm.Singular), GoName(gm.Plural), group.name,
+			fmt.Sprintf(`"/%s"`, gm.Plural), "", gPath, "gID string")
+
+		for _, res := range group.resources {
+			rm := res.rm
+			meta := ""
+			if rm.GetHasDocument() {
+				// The client only deals with the metadata
+				meta = ` + "$meta"`
+			}
+
+			rPath := fmt.Sprintf(`%s + "/%s/" + url.PathEscape(rID)`, gPath,
+				rm.Plural)
+			gen.Entity(res.method, GoName(gm.Singular)+GoName(rm.Plural),
+				res.name, fmt.Sprintf(`%s + "/%s"`, gPath, rm.Plural),
+				"gID string", rPath+meta, "gID string, rID string")
+
+			vPath := rPath + ` + "/versions/" + url.PathEscape(vID)`
+			gen.Entity(res.method+"Version", res.method+"Versions",
+				res.version, rPath+` + "/versions"`, "gID string, rID string",
+				vPath+meta, "gID string, rID string, vID string")
+		}
+	}
+}
+
+// Generates the List, Get, Put and Delete methods for an entity type.
+// "listPath" and "path" are Go expressions that use the ID parameters
+// defined in "listParams" and "params".
+func (gen *GoGenerator) Entity(name string, listName string, typeName string,
+	listPath string, listParams string, path string, params string) {
+
+	gen.Printf(`func (c *Client) List%[1]s(%[3]s) (map[string]*%[2]s, error) {
+	result := map[string]*%[2]s{}
+	return result, c.do("GET", %[4]s, nil, &result)
+}
+
+`, listName, typeName, listParams, listPath)
+
+	gen.Printf(`func (c *Client) Get%[1]s(%[3]s) (*%[2]s, error) {
+	result := &%[2]s{}
+	return result, c.do("GET", %[4]s, nil, result)
+}
+
+func (c *Client) Put%[1]s(%[3]s, entity *%[2]s) (*%[2]s, error) {
+	result := &%[2]s{}
+	return result, c.do("PUT", %[4]s, entity, result)
+}
+
+func (c *Client) Delete%[1]s(%[3]s) error {
+	return c.do("DELETE", %[4]s, nil, nil)
+}
+
+`, name, typeName, params, path)
+}
@@ -0,0 +1,133 @@
+package registry
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGoName(t *testing.T) {
+	type Test struct {
+		name string
+		exp  string
+	}
+
+	tests := []Test{
+		{"dir", "Dir"},
+		{"my_attr", "MyAttr"},
+		{"a.b/c", "ABC"},
+		{"createdat", "CreatedAt"},
+		{"id", "ID"},
+		{"_x", "X"},
+		{"3d", "X3d"},
+	}
+
+	for _, test := range tests {
+		if got := GoName(test.name); got != test.exp {
+			t.Fatalf("GoName(%q): Exp: %q Got: %q", test.name, test.exp, got)
+		}
+	}
+}
+
+func TestModel2Go(t *testing.T) {
+	m := &Model{
+		Attributes: Attributes{
+			"owner": {Name: "owner", Type: STRING, ClientRequired: true,
+				Description: "Who owns it"},
+		},
+		Groups: map[string]*GroupModel{
+			"dirs": {Plural: "dirs", Singular: "dir",
+				Attributes: Attributes{
+					"tags": {Name: "tags", Type: ARRAY,
+						Item: &Item{Type: STRING}},
+				},
+				Resources: map[string]*ResourceModel{
+					"files": {Plural: "files", Singular: "file",
+						HasDocument: PtrBool(true),
+						Attributes: Attributes{
+							"format": {Name: "format", Type: STRING,
+								IfValues: IfValues{
+									"json": {SiblingAttributes: Attributes{
+										"indent": {Name: "indent",
+											Type:           UINTEGER,
+											ClientRequired: true},
+									}},
+								}},
+							"meta": {Name: "meta", Type: OBJECT,
+								Attributes: Attributes{
+									"size": {Name: "size", Type: INTEGER},
+									"env": {Name: "env", Type: MAP,
+										Item: &Item{Type: DECIMAL}},
+								}},
+							"extra": {Name: "extra", Type: OBJECT,
+								Attributes: Attributes{
+									"*": {Name: "*", Type: BOOLEAN},
+								}},
+						}},
+				}},
+			// Same Resource name in another Group
+			"backups": {Plural: "backups", Singular: "backup",
+				Resources: map[string]*ResourceModel{
+					"files": {Plural: "files", Singular: "file",
+						HasDocument: PtrBool(false)},
+				}},
+		},
+	}
+
+	src, err := Model2Go(m, "myreg")
+	if err != nil {
+		t.Fatalf("Model2Go: %s", err)
+	}
+
+	// Make sure it compiles
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "gen.go", src, 0)
+	if err != nil {
+		t.Fatalf("Parse: %s\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("myreg", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("Type check: %s\n%s", err, src)
+	}
+
+	// gofmt aligns the fields, so ignore how many spaces are used
+	spaces := regexp.MustCompile(`[ \t]+`)
+	got := spaces.ReplaceAllString(string(src), " ")
+
+	for _, exp := range []string{
+		"// Code generated from an xRegistry model. DO NOT EDIT.\n",
+		"package myreg\n",
+		"\t// Who owns it\n\tOwner string `json:\"owner\"`\n",
+		"\tDirs      map[string]*Dir `json:\"dirs,omitempty\"`\n",
+		"\tTags      []string        `json:\"tags,omitempty\"`\n",
+		"\tCreatedAt *time.Time",
+		// First come first served, then it's qualified by the Group
+		"type File struct {\n",
+		"type FileVersion struct {\n",
+		"type DirFile struct {\n",
+		"type DirFileVersion struct {\n",
+		"\tIndent               *uint                        `json:\"indent,omitempty\"`\n",
+		"\tMeta *DirFileMeta ",
+		"\tExtra                map[string]bool ",
+		"\tVersionTags          map[string]string ",
+		"\tFile                 any ",
+		"type DirFileMeta struct {\n\tEnv  map[string]float64 `json:\"env,omitempty\"`\n\tSize *int                `json:\"size,omitempty\"`\n}\n",
+		"func (c *Client) ListDirFiles(gID string) (map[string]*DirFile, error) {\n",
+		"func (c *Client) GetDirFile(gID string, rID string) (*DirFile, error) {\n",
+		`"/dirs/"+url.PathEscape(gID)+"/files/"+url.PathEscape(rID)+"$meta"`,
+		`"/backups/"+url.PathEscape(gID)+"/files/"+url.PathEscape(rID), nil, result)`,
+		"func (c *Client) PutBackupFileVersion(gID string, rID string, vID string, entity *FileVersion) (*FileVersion, error) {\n",
+		"func (c *Client) DeleteDir(gID string) error {\n",
+	} {
+		exp = spaces.ReplaceAllString(exp, " ")
+		if !strings.Contains(got, exp) {
+			t.Fatalf("Missing:\n%s\nIn:\n%s", exp, src)
+		}
+	}
+}
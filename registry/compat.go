@@ -0,0 +1,274 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Resource model "compatibility" values
+const COMPAT_NONE = "none"
+const COMPAT_BACKWARD = "backward"
+const COMPAT_FORWARD = "forward"
+const COMPAT_FULL = "full"
+
+var CompatibilityValues = []string{
+	COMPAT_NONE, COMPAT_BACKWARD, COMPAT_FORWARD, COMPAT_FULL}
+
+func IsValidCompatibility(val string) bool {
+	for _, v := range CompatibilityValues {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCompatibility compares two JSON Schema documents and returns the list
+// of changes that violate the "policy".
+// - backward: anything valid under "oldDoc" must still be valid under "newDoc"
+// - forward: anything valid under "newDoc" must still be valid under "oldDoc"
+// - full: both
+// An empty list means the documents are compatible. An error is returned if
+// either document isn't a JSON Schema object.
+func CheckCompatibility(policy string, oldDoc, newDoc []byte) ([]string, error) {
+	if policy == "" || policy == COMPAT_NONE {
+		return nil, nil
+	}
+	if !IsValidCompatibility(policy) {
+		return nil, fmt.Errorf("Invalid compatibility value %q", policy)
+	}
+
+	oldSchema := map[string]any{}
+	if err := json.Unmarshal(oldDoc, &oldSchema); err != nil {
+		return nil, fmt.Errorf("Previous document is not a valid JSON "+
+			"Schema: %s", err)
+	}
+	newSchema := map[string]any{}
+	if err := json.Unmarshal(newDoc, &newSchema); err != nil {
+		return nil, fmt.Errorf("New document is not a valid JSON "+
+			"Schema: %s", err)
+	}
+
+	issues := []string{}
+	if policy == COMPAT_BACKWARD || policy == COMPAT_FULL {
+		for _, issue := range schemaNarrowed("#", oldSchema, newSchema) {
+			issues = append(issues, "backward: "+issue)
+		}
+	}
+	if policy == COMPAT_FORWARD || policy == COMPAT_FULL {
+		for _, issue := range schemaNarrowed("#", newSchema, oldSchema) {
+			issues = append(issues, "forward: "+issue)
+		}
+	}
+	return issues, nil
+}
+
+// schemaNarrowed returns the list of ways in which "to" rejects data that
+// "from" would have accepted. It's not a full JSON Schema comparison, just
+// the common cases: type, required, enum, properties, additionalProperties,
+// items and the numeric/length bounds.
+func schemaNarrowed(path string, from, to map[string]any) []string {
+	issues := []string{}
+
+	// type
+	fromTypes := schemaTypes(from)
+	toTypes := schemaTypes(to)
+	if toTypes != nil {
+		for _, t := range typesOrAll(fromTypes) {
+			if !toTypes[t] && !(t == "integer" && toTypes["number"]) {
+				issues = append(issues, fmt.Sprintf("%s: type %q is no "+
+					"longer allowed", path, t))
+			}
+		}
+	}
+
+	// required
+	fromReq := map[string]bool{}
+	for _, r := range schemaStrings(from["required"]) {
+		fromReq[r] = true
+	}
+	for _, r := range schemaStrings(to["required"]) {
+		if !fromReq[r] {
+			issues = append(issues, fmt.Sprintf("%s: property %q is now "+
+				"required", path, r))
+		}
+	}
+
+	// enum
+	if toEnum, ok := to["enum"].([]any); ok {
+		fromEnum, ok := from["enum"].([]any)
+		if !ok {
+			issues = append(issues, fmt.Sprintf("%s: values are now "+
+				"restricted to an enum", path))
+		} else {
+			for _, fv := range fromEnum {
+				found := false
+				for _, tv := range toEnum {
+					if reflect.DeepEqual(fv, tv) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					issues = append(issues, fmt.Sprintf("%s: enum value "+
+						"%v was removed", path, fv))
+				}
+			}
+		}
+	}
+
+	// const
+	if toConst, ok := to["const"]; ok {
+		if fromConst, ok := from["const"]; !ok ||
+			!reflect.DeepEqual(fromConst, toConst) {
+			issues = append(issues, fmt.Sprintf("%s: \"const\" value was "+
+				"added or changed", path))
+		}
+	}
+
+	// properties & additionalProperties
+	fromProps, _ := from["properties"].(map[string]any)
+	toProps, _ := to["properties"].(map[string]any)
+	fromClosed := from["additionalProperties"] == false
+	toClosed := to["additionalProperties"] == false
+
+	if toClosed && !fromClosed {
+		issues = append(issues, fmt.Sprintf("%s: additional properties are "+
+			"no longer allowed", path))
+	}
+
+	for _, name := range SortedKeys(fromProps) {
+		fromProp, _ := fromProps[name].(map[string]any)
+		toPropAny, ok := toProps[name]
+		if !ok {
+			if toClosed && !fromClosed {
+				// Already reported above
+				continue
+			}
+			if toClosed {
+				issues = append(issues, fmt.Sprintf("%s: property %q was "+
+					"removed", path, name))
+			}
+			continue
+		}
+		toProp, _ := toPropAny.(map[string]any)
+		if fromProp != nil && toProp != nil {
+			issues = append(issues, schemaNarrowed(
+				path+"/properties/"+name, fromProp, toProp)...)
+		}
+	}
+
+	// New properties only narrow things if the old schema was open and
+	// had something to say about them via additionalProperties
+	if fromAdd, ok := from["additionalProperties"].(map[string]any); ok {
+		for _, name := range SortedKeys(toProps) {
+			if _, ok := fromProps[name]; ok {
+				continue
+			}
+			if toProp, ok := toProps[name].(map[string]any); ok {
+				issues = append(issues, schemaNarrowed(
+					path+"/properties/"+name, fromAdd, toProp)...)
+			}
+		}
+	}
+
+	// items
+	fromItems, _ := from["items"].(map[string]any)
+	toItems, _ := to["items"].(map[string]any)
+	if toItems != nil {
+		if fromItems == nil {
+			fromItems = map[string]any{}
+		}
+		issues = append(issues, schemaNarrowed(path+"/items",
+			fromItems, toItems)...)
+	}
+
+	// Lower bounds can't go up
+	for _, key := range []string{"minimum", "exclusiveMinimum", "minLength",
+		"minItems", "minProperties"} {
+		tv, tok := to[key].(float64)
+		fv, fok := from[key].(float64)
+		if tok && (!fok || tv > fv) {
+			issues = append(issues, fmt.Sprintf("%s: %q was added or "+
+				"increased", path, key))
+		}
+	}
+
+	// Upper bounds can't go down
+	for _, key := range []string{"maximum", "exclusiveMaximum", "maxLength",
+		"maxItems", "maxProperties"} {
+		tv, tok := to[key].(float64)
+		fv, fok := from[key].(float64)
+		if tok && (!fok || tv < fv) {
+			issues = append(issues, fmt.Sprintf("%s: %q was added or "+
+				"decreased", path, key))
+		}
+	}
+
+	// Any change to a pattern is assumed to be a narrowing
+	if tv, ok := to["pattern"].(string); ok {
+		if fv, _ := from["pattern"].(string); fv != tv {
+			issues = append(issues, fmt.Sprintf("%s: \"pattern\" was added "+
+				"or changed", path))
+		}
+	}
+
+	return issues
+}
+
+var allSchemaTypes = []string{"array", "boolean", "integer", "null",
+	"number", "object", "string"}
+
+// Returns nil if there are no restrictions on the type
+func schemaTypes(schema map[string]any) map[string]bool {
+	val, ok := schema["type"]
+	if !ok {
+		return nil
+	}
+	res := map[string]bool{}
+	for _, t := range schemaStrings(val) {
+		res[t] = true
+	}
+	if str, ok := val.(string); ok {
+		res[str] = true
+	}
+	return res
+}
+
+func typesOrAll(types map[string]bool) []string {
+	if types == nil {
+		return allSchemaTypes
+	}
+	list := []string{}
+	for t, _ := range types {
+		list = append(list, t)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func schemaStrings(val any) []string {
+	list, ok := val.([]any)
+	if !ok {
+		return nil
+	}
+	res := []string{}
+	for _, v := range list {
+		if str, ok := v.(string); ok {
+			res = append(res, str)
+		}
+	}
+	return res
+}
+
+// Returns the first 'max' issues as a single string for error messages
+func CompatIssuesString(issues []string, max int) string {
+	if max > 0 && len(issues) > max {
+		return strings.Join(issues[:max], "; ") +
+			fmt.Sprintf("; and %d more", len(issues)-max)
+	}
+	return strings.Join(issues, "; ")
+}
@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	type CompatTest struct {
+		Policy string
+		Old    string
+		New    string
+		Issues string
+	}
+
+	tests := []CompatTest{
+		{"none", `{"type":"string"}`, `{"type":"integer"}`, ``},
+		{"backward", `{"type":"object"}`, `{"type":"object"}`, ``},
+		{"backward", `{"type":"integer"}`, `{"type":"number"}`, ``},
+		{"backward", `{"type":"number"}`, `{"type":"integer"}`,
+			`backward: #: type "number" is no longer allowed`},
+		{"forward", `{"type":"integer"}`, `{"type":"number"}`,
+			`forward: #: type "number" is no longer allowed`},
+		{"backward",
+			`{"properties":{"a":{"type":"string"}}}`,
+			`{"properties":{"a":{"type":"string"}},"required":["a"]}`,
+			`backward: #: property "a" is now required`},
+		{"forward",
+			`{"properties":{"a":{"type":"string"}}}`,
+			`{"properties":{"a":{"type":"string"}},"required":["a"]}`,
+			``},
+		{"backward",
+			`{"properties":{"a":{"type":"string"}}}`,
+			`{"properties":{"a":{"type":"boolean"}}}`,
+			`backward: #/properties/a: type "string" is no longer allowed`},
+		{"backward",
+			`{"properties":{"a":{},"b":{}},"additionalProperties":false}`,
+			`{"properties":{"a":{}},"additionalProperties":false}`,
+			`backward: #: property "b" was removed`},
+		{"backward",
+			`{"properties":{"a":{}}}`,
+			`{"properties":{"a":{}},"additionalProperties":false}`,
+			`backward: #: additional properties are no longer allowed`},
+		{"backward", `{"enum":["a","b"]}`, `{"enum":["a"]}`,
+			`backward: #: enum value b was removed`},
+		{"forward", `{"enum":["a","b"]}`, `{"enum":["a"]}`, ``},
+		{"backward", `{"maxLength":10}`, `{"maxLength":5}`,
+			`backward: #: "maxLength" was added or decreased`},
+		{"backward", `{"minimum":1}`, `{"minimum":0}`, ``},
+		{"backward",
+			`{"type":"array","items":{"type":"string"}}`,
+			`{"type":"array","items":{"type":"integer"}}`,
+			`backward: #/items: type "string" is no longer allowed`},
+		{"full", `{"type":"number"}`, `{"type":"integer"}`,
+			`backward: #: type "number" is no longer allowed`},
+		{"full", `{"required":["a"]}`, `{}`, `forward: #: property "a" is now required`},
+	}
+
+	for _, test := range tests {
+		issues, err := CheckCompatibility(test.Policy, []byte(test.Old),
+			[]byte(test.New))
+		if err != nil {
+			t.Fatalf("Test: %s -> %s: unexpected error: %s", test.Old,
+				test.New, err)
+		}
+		got := strings.Join(issues, "\n")
+		if got != test.Issues {
+			t.Fatalf("Test(%s): %s -> %s\nExp: %s\nGot: %s", test.Policy,
+				test.Old, test.New, test.Issues, got)
+		}
+	}
+
+	_, err := CheckCompatibility("backward", []byte(`hello`), []byte(`{}`))
+	if err == nil {
+		t.Fatalf("Should have failed on non-json doc")
+	}
+
+	_, err = CheckCompatibility("sideways", []byte(`{}`), []byte(`{}`))
+	if err == nil || err.Error() != `Invalid compatibility value "sideways"` {
+		t.Fatalf("Bad policy error: %v", err)
+	}
+}
@@ -6,6 +6,10 @@ var TESTING = (os.Getenv("TESTING") != "")
 
 const SPECVERSION = "0.5"
 const XREGSCHEMA = "xRegistry-json"
+const JSONSCHEMA = "jsonschema"
+const JSONSCHEMA_DRAFT = "2020-12"
+const OPENAPI = "openapi"
+const OPENAPI_VERSION = "3.1.0"
 
 // Model attribute default values
 const STRICT = true
@@ -14,6 +18,8 @@ const SETVERSIONID = true
 const SETSTICKYDEFAULT = true
 const HASDOCUMENT = true
 const READONLY = false
+const COMPATIBILITY = "none"
+const VERSIONIDSTRATEGY = VERSIONID_COUNTER
 
 // Attribute types
 const ANY = "any"
@@ -30,6 +36,35 @@ const URI = "uri"
 const URI_REFERENCE = "urireference"
 const URI_TEMPLATE = "uritemplate"
 const URL = "url"
+const XID = "xid"
+
+// Attribute "ondelete" values. These control what happens to an entity that
+// references (via an xid) an entity that's being deleted
+const ONDELETE_BLOCK = "block"
+const ONDELETE_CASCADE = "cascade"
+
+// Attribute "format" values. These are hints about the syntax of a string
+const FORMAT_DATE = "date"
+const FORMAT_EMAIL = "email"
+const FORMAT_HOSTNAME = "hostname"
+const FORMAT_IPV4 = "ipv4"
+const FORMAT_IPV6 = "ipv6"
+const FORMAT_SEMVER = "semver"
+const FORMAT_UUID = "uuid"
+
+// Resource model "versionidstrategy" values
+const VERSIONID_COUNTER = "counter"
+const VERSIONID_SEMVER = "semver"
+const VERSIONID_TIMESTAMP = "timestamp"
+const VERSIONID_UUID = "uuid"
+
+// Format of the "timestamp" versionidstrategy IDs
+const VERSIONID_TIMESTAMP_FORMAT = "20060102T150405.000000000Z"
+
+// Semver "versionbump" values
+const SEMVER_MAJOR = "major"
+const SEMVER_MINOR = "minor"
+const SEMVER_PATCH = "patch"
 
 const IN_CHAR = '.'
 const IN_STR = string(IN_CHAR)
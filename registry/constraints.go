@@ -0,0 +1,236 @@
+package registry
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var RegexpHostname = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+var RegexpUUID = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// Checks for each of the supported "format" values
+var FormatCheckers = map[string]func(str string) bool{
+	FORMAT_DATE: func(str string) bool {
+		_, err := time.Parse(time.DateOnly, str)
+		return err == nil
+	},
+	FORMAT_EMAIL: func(str string) bool {
+		addr, err := mail.ParseAddress(str)
+		return err == nil && addr.Address == str
+	},
+	FORMAT_HOSTNAME: func(str string) bool {
+		return len(str) <= 253 && RegexpHostname.MatchString(str)
+	},
+	FORMAT_IPV4: func(str string) bool {
+		ip := net.ParseIP(str)
+		return ip != nil && ip.To4() != nil && !strings.Contains(str, ":")
+	},
+	FORMAT_IPV6: func(str string) bool {
+		ip := net.ParseIP(str)
+		return ip != nil && strings.Contains(str, ":")
+	},
+	FORMAT_SEMVER: func(str string) bool {
+		_, err := ParseSemver(str)
+		return err == nil
+	},
+	FORMAT_UUID: func(str string) bool {
+		return RegexpUUID.MatchString(str)
+	},
+}
+
+// Cache of compiled "pattern" values so we don't compile them on each write
+var patterns = sync.Map{} // pattern -> *regexp.Regexp
+
+func GetPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+func IsNumeric(daType string) bool {
+	return daType == DECIMAL || daType == INTEGER || daType == UINTEGER
+}
+
+// Make sure the attribute's constraints make sense for its type and
+// with each other
+func (attr *Attribute) VerifyConstraints(path *PropPath) error {
+	isString := IsString(attr.Type)
+
+	if attr.Pattern != "" {
+		if !isString {
+			return fmt.Errorf("%q is not a string, so \"pattern\" is not "+
+				"allowed", path.UI())
+		}
+		if _, err := GetPattern(attr.Pattern); err != nil {
+			return fmt.Errorf("%q has an invalid \"pattern\" value (%s): %s",
+				path.UI(), attr.Pattern, err)
+		}
+	}
+
+	if attr.Format != "" {
+		if !isString {
+			return fmt.Errorf("%q is not a string, so \"format\" is not "+
+				"allowed", path.UI())
+		}
+		if FormatCheckers[attr.Format] == nil {
+			return fmt.Errorf("%q has an invalid \"format\" value (%s), "+
+				"must be one of: %s", path.UI(), attr.Format,
+				strings.Join(SortedKeys(FormatCheckers), ", "))
+		}
+	}
+
+	checkRange := func(allowed bool, kind string, minName string, min *int,
+		maxName string, max *int) error {
+
+		if min == nil && max == nil {
+			return nil
+		}
+		if !allowed {
+			name := minName
+			if min == nil {
+				name = maxName
+			}
+			return fmt.Errorf("%q is not %s, so %q is not allowed",
+				path.UI(), kind, name)
+		}
+		if min != nil && *min < 0 {
+			return fmt.Errorf("%q %q must be >= 0", path.UI(), minName)
+		}
+		if max != nil && *max < 0 {
+			return fmt.Errorf("%q %q must be >= 0", path.UI(), maxName)
+		}
+		if min != nil && max != nil && *min > *max {
+			return fmt.Errorf("%q %q must be <= %q", path.UI(), minName,
+				maxName)
+		}
+		return nil
+	}
+
+	err := checkRange(isString, "a string", "minlength", attr.MinLength,
+		"maxlength", attr.MaxLength)
+	if err != nil {
+		return err
+	}
+
+	err = checkRange(attr.Type == ARRAY || attr.Type == MAP,
+		"an array or map", "minitems", attr.MinItems, "maxitems", attr.MaxItems)
+	if err != nil {
+		return err
+	}
+
+	if attr.Minimum != nil || attr.Maximum != nil {
+		if !IsNumeric(attr.Type) {
+			name := "minimum"
+			if attr.Minimum == nil {
+				name = "maximum"
+			}
+			return fmt.Errorf("%q is not numeric, so %q is not allowed",
+				path.UI(), name)
+		}
+		if attr.Minimum != nil && attr.Maximum != nil &&
+			*attr.Minimum > *attr.Maximum {
+			return fmt.Errorf("%q \"minimum\" must be <= \"maximum\"",
+				path.UI())
+		}
+	}
+
+	// A default value needs to follow the rules too
+	if !IsNil(attr.Default) && IsScalar(attr.Type) {
+		if err := attr.CheckScalarConstraints(attr.Default, path); err != nil {
+			return fmt.Errorf("%q \"default\" value (%v) is invalid: %s",
+				path.UI(), attr.Default, err)
+		}
+	}
+
+	return nil
+}
+
+// Check "val", which is assumed to already be of the correct type, against
+// the attribute's constraints
+func (attr *Attribute) CheckScalarConstraints(val any, path *PropPath) error {
+	if str, ok := val.(string); ok && IsString(attr.Type) {
+		if attr.MinLength != nil || attr.MaxLength != nil {
+			l := utf8.RuneCountInString(str)
+			if attr.MinLength != nil && l < *attr.MinLength {
+				return fmt.Errorf("Attribute %q must be at least %d "+
+					"characters long", path.UI(), *attr.MinLength)
+			}
+			if attr.MaxLength != nil && l > *attr.MaxLength {
+				return fmt.Errorf("Attribute %q must be at most %d "+
+					"characters long", path.UI(), *attr.MaxLength)
+			}
+		}
+
+		if attr.Pattern != "" {
+			re, err := GetPattern(attr.Pattern)
+			if err != nil {
+				return err
+			}
+			if !re.MatchString(str) {
+				return fmt.Errorf("Attribute %q must match the pattern %q",
+					path.UI(), attr.Pattern)
+			}
+		}
+
+		if attr.Format != "" {
+			if fn := FormatCheckers[attr.Format]; fn != nil && !fn(str) {
+				return fmt.Errorf("Attribute %q must be a valid %s",
+					path.UI(), attr.Format)
+			}
+		}
+	}
+
+	if IsNumeric(attr.Type) && (attr.Minimum != nil || attr.Maximum != nil) {
+		num := 0.0
+		switch v := val.(type) {
+		case int:
+			num = float64(v)
+		case float64:
+			num = v
+		default:
+			return nil
+		}
+
+		if attr.Minimum != nil && num < *attr.Minimum {
+			return fmt.Errorf("Attribute %q must be >= %v", path.UI(),
+				*attr.Minimum)
+		}
+		if attr.Maximum != nil && num > *attr.Maximum {
+			return fmt.Errorf("Attribute %q must be <= %v", path.UI(),
+				*attr.Maximum)
+		}
+	}
+
+	return nil
+}
+
+// Check the number of items in an array or map
+func (attr *Attribute) CheckItemCount(val any, path *PropPath) error {
+	if attr.MinItems == nil && attr.MaxItems == nil {
+		return nil
+	}
+
+	l := reflect.ValueOf(val).Len()
+	if attr.MinItems != nil && l < *attr.MinItems {
+		return fmt.Errorf("Attribute %q must have at least %d items",
+			path.UI(), *attr.MinItems)
+	}
+	if attr.MaxItems != nil && l > *attr.MaxItems {
+		return fmt.Errorf("Attribute %q must have at most %d items",
+			path.UI(), *attr.MaxItems)
+	}
+	return nil
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	_ "embed"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime/pprof"
@@ -15,6 +17,7 @@ import (
 
 	log "github.com/duglin/dlog"
 	_ "github.com/go-sql-driver/mysql"
+	"modernc.org/sqlite"
 )
 
 var DB *sql.DB
@@ -26,6 +29,9 @@ var DBHOST = "localhost"
 var DBPORT = "3306"
 var DBPASSWORD = "password"
 
+// When set, the DBs are SQLite files in this dir instead of MySQL ones
+var DBDIR = ""
+
 // TODO load these from a config file
 func init() {
 	if tmp := os.Getenv("DBUSER"); tmp != "" {
@@ -40,6 +46,26 @@ func init() {
 	if tmp := os.Getenv("DBPORT"); tmp != "" {
 		DBPORT = tmp
 	}
+	if tmp := os.Getenv("DBDIR"); tmp != "" {
+		DBDIR = tmp
+	}
+
+	// Our queries use MySQL's IF() and utf8mb4_0900_ai_ci collation, so give
+	// SQLite its own versions of them. MySQL's collation also ignores
+	// accents, this one only ignores case - which is all the anyCase Path
+	// lookups that use it need.
+	sqlite.MustRegisterDeterministicScalarFunction("if", 3,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			if b, ok := args[0].(int64); ok && b != 0 {
+				return args[1], nil
+			}
+			return args[2], nil
+		})
+	sqlite.MustRegisterCollationUtf8("utf8mb4_0900_ai_ci",
+		func(left, right string) int {
+			return strings.Compare(strings.ToLower(left),
+				strings.ToLower(right))
+		})
 }
 
 // Active transaction - mainly for debugging and testing
@@ -124,6 +150,17 @@ type Tx struct {
 	IgnoreEpoch                bool
 	IgnoreStickyDefaultVersion bool
 	IgnoreDefaultVersionID     bool
+	VersionBump                string // major, minor, patch for semver IDs
+	ModelRevision              int    // model revision saved by this Tx
+
+	// Read-only Resources can't be changed unless this is set. It's for
+	// the server itself (e.g. to populate them), never set it for a client.
+	WriteReadOnly bool
+
+	// When set, compatibility violations are saved in CompatIssues
+	// instead of failing the request (?checkcompat)
+	CheckCompat  bool
+	CompatIssues []string
 
 	// Cache of entities this Tx is dealing with. Things can get funky if
 	// we have more than one instance of the same entity in memory.
@@ -221,6 +258,7 @@ func (tx *Tx) Commit() error {
 	delete(TXs, tx.uuid)
 	tx.tx = nil
 	tx.CreateTime = ""
+	tx.ModelRevision = 0
 	tx.Versions = nil // force a NPE if someone tries to use it outside of a tx
 	tx.uuid = ""
 
@@ -240,6 +278,7 @@ func (tx *Tx) Rollback() error {
 	delete(TXs, tx.uuid)
 	tx.tx = nil
 	tx.CreateTime = ""
+	tx.ModelRevision = 0
 	tx.Versions = nil // force a NPE if someone tries to use it outside of a tx
 	tx.uuid = ""
 
@@ -261,11 +300,21 @@ func (tx *Tx) Prepare(query string) (*sql.Stmt, error) {
 			return nil, err
 		}
 	}
+	if DBDIR != "" {
+		query = SQLiteQuery(query)
+	}
 	ps, err := tx.tx.Prepare(query)
 
 	return ps, err
 }
 
+// Converts the MySQL-only bits of "query" into SQLite. BINARY is dropped
+// since SQLite's "=" is already case-sensitive unless a column says
+// otherwise.
+func SQLiteQuery(query string) string {
+	return strings.ReplaceAll(query, "BINARY ", "")
+}
+
 func (tx *Tx) AddVersion(v *Version) {
 	if tx.Versions == nil {
 		tx.Versions = map[string]*Version{}
@@ -276,6 +325,14 @@ func (tx *Tx) AddVersion(v *Version) {
 		v.UID] = v
 }
 
+// Deleted Versions need to be removed so they're not found again
+func (tx *Tx) RemoveVersion(v *Version) {
+	delete(tx.Versions, v.Resource.Group.Registry.DbSID+
+		v.Resource.Group.DbSID+
+		v.Resource.DbSID+
+		v.UID)
+}
+
 func (tx *Tx) GetVersion(r *Resource, vID string) *Version {
 	key := r.Group.Registry.DbSID + r.Group.DbSID + r.DbSID + vID
 	return tx.Versions[key]
@@ -584,6 +641,12 @@ func DoCount(tx *Tx, num int, cmd string, args ...interface{}) error {
 func DBExists(name string) bool {
 	log.VPrintf(3, ">Enter: DBExists %q", name)
 	defer log.VPrintf(3, "<Exit: DBExists")
+
+	if DBDIR != "" {
+		_, err := os.Stat(SQLiteFile(name))
+		return err == nil
+	}
+
 	db, err := sql.Open("mysql",
 		DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/")
 	if err != nil {
@@ -607,9 +670,31 @@ func DBExists(name string) bool {
 var initDB string
 var firstTime = true
 
+//go:embed init-sqlite.sql
+var initSQLiteDB string
+
+// Returns the file that holds the SQLite DB "name"
+func SQLiteFile(name string) string {
+	return filepath.Join(DBDIR, name+".db")
+}
+
+// Returns the data source name for the SQLite DB "name". Each Tx locks the
+// DB when it starts and waits for the others, rather than failing, if
+// there's more than one going on. LIKE is made case-sensitive, like it is
+// for MySQL's Paths.
+func SQLiteDSN(name string) string {
+	return "file:" + SQLiteFile(name) +
+		"?_pragma=busy_timeout(30000)&_pragma=journal_mode(WAL)" +
+		"&_pragma=case_sensitive_like(1)&_txlock=immediate"
+}
+
 func OpenDB(name string) error {
 	if firstTime {
-		log.VPrintf(1, "DB: %s:%s", DBHOST, DBPORT)
+		if DBDIR != "" {
+			log.VPrintf(1, "DB: %s", SQLiteFile(name))
+		} else {
+			log.VPrintf(1, "DB: %s:%s", DBHOST, DBPORT)
+		}
 		firstTime = false
 	}
 
@@ -620,8 +705,12 @@ func OpenDB(name string) error {
 	// DBUSER + ":"+DBPASSWORD+"@tcp(localhost:3306)/")
 	var err error
 
-	DB, err = sql.Open("mysql",
-		DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/"+name)
+	if DBDIR != "" {
+		DB, err = sql.Open("sqlite", SQLiteDSN(name))
+	} else {
+		DB, err = sql.Open("mysql",
+			DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/"+name)
+	}
 
 	if err != nil {
 		DB = nil
@@ -645,24 +734,42 @@ func CreateDB(name string) error {
 	log.VPrintf(3, ">Enter: CreateDB %q", name)
 	defer log.VPrintf(3, "<Exit: CreateDB")
 
-	db, err := sql.Open("mysql",
-		DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/")
-	if err != nil {
-		panic(err)
-	}
-	defer db.Close()
+	var db *sql.DB
+	var err error
+	script := initDB
 
-	if _, err = db.Exec("CREATE DATABASE " + name); err != nil {
-		panic(err)
-	}
+	if DBDIR != "" {
+		if err = os.MkdirAll(DBDIR, 0755); err != nil {
+			return err
+		}
+		if DBExists(name) {
+			return fmt.Errorf("Database %q already exists", name)
+		}
+		if db, err = sql.Open("sqlite", SQLiteDSN(name)); err != nil {
+			return err
+		}
+		defer db.Close()
+		script = initSQLiteDB
+	} else {
+		db, err = sql.Open("mysql",
+			DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/")
+		if err != nil {
+			panic(err)
+		}
+		defer db.Close()
 
-	if _, err = db.Exec("USE " + name); err != nil {
-		panic(err)
+		if _, err = db.Exec("CREATE DATABASE " + name); err != nil {
+			panic(err)
+		}
+
+		if _, err = db.Exec("USE " + name); err != nil {
+			panic(err)
+		}
 	}
 
 	log.VPrintf(3, "Creating DB")
 
-	for _, cmd := range strings.Split(initDB, ";") {
+	for _, cmd := range strings.Split(script, ";") {
 		cmd = strings.TrimSpace(cmd)
 		cmd = strings.Replace(cmd, "@", ";", -1) // Can't use ; in file
 		if cmd == "" {
@@ -681,6 +788,16 @@ func CreateDB(name string) error {
 func DeleteDB(name string) error {
 	log.VPrintf(3, "Deleting DB %q", name)
 
+	if DBDIR != "" {
+		file := SQLiteFile(name)
+		for _, f := range []string{file, file + "-wal", file + "-shm"} {
+			if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
 	db, err := sql.Open("mysql",
 		DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/")
 	if err != nil {
@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The parts of a DB script that need to be the same in init.sql and
+// init-sqlite.sql
+type DBSchema struct {
+	Columns  map[string]string // Table -> column names, in order
+	Keys     map[string]string // Table -> sorted PRIMARY/UNIQUE/INDEX(cols)
+	Triggers map[string]string // Name -> statement
+	Views    map[string]string // Name -> statement
+}
+
+var (
+	reSQLComment = regexp.MustCompile(`(?s)/\*.*?\*/|(#|--)[^\n]*`)
+	reSQLTable   = regexp.MustCompile(`(?s)^CREATE TABLE "?(\w+)"? \((.*)\)$`)
+	reSQLIndex   = regexp.MustCompile(
+		`^CREATE (UNIQUE )?INDEX \w+ ON "?(\w+)"? \((.*)\)$`)
+	reSQLOther = regexp.MustCompile(`^CREATE (TRIGGER|VIEW) (\w+)`)
+	reSQLKey   = regexp.MustCompile(`^(PRIMARY KEY|UNIQUE INDEX|UNIQUE|` +
+		`INDEX|CONSTRAINT \w+ UNIQUE) ?\((.*)\)$`)
+)
+
+func ParseDBSchema(t *testing.T, script string) *DBSchema {
+	schema := &DBSchema{
+		Columns:  map[string]string{},
+		Keys:     map[string]string{},
+		Triggers: map[string]string{},
+		Views:    map[string]string{},
+	}
+	keys := map[string][]string{}
+
+	script = reSQLComment.ReplaceAllString(script, "")
+	for _, cmd := range strings.Split(script, ";") {
+		cmd = strings.Join(strings.Fields(cmd), " ")
+
+		if m := reSQLTable.FindStringSubmatch(cmd); m != nil {
+			cols := []string{}
+			for _, def := range strings.Split(m[2], ",") {
+				def = strings.TrimSpace(def)
+				if strings.HasPrefix(def, "PRIMARY") ||
+					strings.HasPrefix(def, "UNIQUE") ||
+					strings.HasPrefix(def, "INDEX") ||
+					strings.HasPrefix(def, "CONSTRAINT") {
+					// Keys can have more than one column, so put them
+					// back together before parsing them
+					keys[m[1]] = append(keys[m[1]], def)
+					continue
+				}
+				if len(keys[m[1]]) > 0 &&
+					!strings.HasSuffix(keys[m[1]][len(keys[m[1]])-1], ")") {
+					keys[m[1]][len(keys[m[1]])-1] += "," + def
+					continue
+				}
+				name := strings.Trim(strings.Fields(def)[0], `"`)
+				cols = append(cols, name)
+				if strings.Contains(def, "PRIMARY KEY") {
+					keys[m[1]] = append(keys[m[1]], "PRIMARY KEY ("+name+")")
+				}
+			}
+			schema.Columns[m[1]] = strings.Join(cols, ",")
+		} else if m := reSQLIndex.FindStringSubmatch(cmd); m != nil {
+			keys[m[2]] = append(keys[m[2]], m[1]+"INDEX ("+m[3]+")")
+		} else if m := reSQLOther.FindStringSubmatch(cmd); m != nil {
+			if m[1] == "TRIGGER" {
+				schema.Triggers[m[2]] = cmd
+			} else {
+				schema.Views[m[2]] = cmd
+			}
+		}
+	}
+
+	for table, list := range keys {
+		res := []string{}
+		for _, key := range list {
+			m := reSQLKey.FindStringSubmatch(key)
+			if m == nil {
+				t.Fatalf("Unknown key in %q: %s", table, key)
+			}
+			kind := "INDEX"
+			if strings.HasPrefix(m[1], "PRIMARY") {
+				kind = "PRIMARY"
+			} else if strings.Contains(m[1], "UNIQUE") {
+				kind = "UNIQUE"
+			}
+			cols := strings.ReplaceAll(strings.ReplaceAll(m[2], " ", ""),
+				`"`, "")
+			res = append(res, kind+"("+cols+")")
+		}
+		sort.Strings(res)
+		schema.Keys[table] = strings.Join(res, " ")
+	}
+
+	return schema
+}
+
+func TestSQLiteSchema(t *testing.T) {
+	defer func(dir string) { DBDIR = dir }(DBDIR)
+	DBDIR = t.TempDir()
+
+	// Make sure SQLite is happy with it
+	if err := CreateDB("schematest"); err != nil {
+		t.Fatalf("Error creating SQLite DB: %s", err)
+	}
+	if err := DeleteDB("schematest"); err != nil {
+		t.Fatalf("Error deleting SQLite DB: %s", err)
+	}
+
+	mysql := ParseDBSchema(t, initDB)
+	sqlite := ParseDBSchema(t, initSQLiteDB)
+
+	// SQLite has no auto-increment for non-key columns, see the trigger
+	delete(sqlite.Triggers, "VersionsCounter")
+
+	if len(mysql.Columns) == 0 || len(mysql.Triggers) == 0 ||
+		len(mysql.Views) == 0 {
+		t.Fatalf("Didn't find the tables, triggers and views in init.sql")
+	}
+
+	for _, check := range []struct {
+		what   string
+		mysql  map[string]string
+		sqlite map[string]string
+	}{
+		{"Columns", mysql.Columns, sqlite.Columns},
+		{"Keys", mysql.Keys, sqlite.Keys},
+		{"Trigger", mysql.Triggers, sqlite.Triggers},
+		{"View", mysql.Views, sqlite.Views},
+	} {
+		for name, exp := range check.mysql {
+			if got, ok := check.sqlite[name]; !ok {
+				t.Errorf("%s %q is missing from init-sqlite.sql", check.what,
+					name)
+			} else if got != exp {
+				t.Errorf("%s %q doesn't match init.sql:\nExp: %s\nGot: %s",
+					check.what, name, exp, got)
+			}
+		}
+		for name := range check.sqlite {
+			if _, ok := check.mysql[name]; !ok {
+				t.Errorf("%s %q is missing from init.sql", check.what, name)
+			}
+		}
+	}
+}
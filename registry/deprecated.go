@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// "deprecated" can appear on Resources and Versions. Like "versiontags"
+// it's not part of the model, it's added to the entity's attributes on the
+// fly. A Resource's "deprecated" value is stored on the Resource itself,
+// not on its default Version, so each can be deprecated independently.
+var DeprecatedAttr = &Attribute{
+	Name: "deprecated",
+	Type: OBJECT,
+	Attributes: Attributes{
+		"effective": &Attribute{
+			Name: "effective",
+			Type: TIMESTAMP,
+		},
+		"removal": &Attribute{
+			Name: "removal",
+			Type: TIMESTAMP,
+		},
+		"alternative": &Attribute{
+			Name: "alternative",
+			Type: URL,
+		},
+	},
+}
+
+func IsDeprecated(e *Entity) bool {
+	return !IsNil(e.Get(DeprecatedAttr.Name))
+}
+
+func (r *Resource) IsDeprecated() bool {
+	return IsDeprecated(&r.Entity)
+}
+
+// Set (or clear, if "val" is nil) the Resource's "deprecated" attribute
+func (r *Resource) SetDeprecated(val any) error {
+	pp := NewPPP(DeprecatedAttr.Name)
+
+	// Resources skip validation so do it ourselves
+	if !IsNil(val) {
+		if err := r.Entity.ValidateAttribute(val, DeprecatedAttr, pp); err != nil {
+			return err
+		}
+	}
+
+	if err := r.Entity.JustSet(pp, val); err != nil {
+		return err
+	}
+	if err := r.Entity.ValidateAndSave(); err != nil {
+		return err
+	}
+
+	return r.touch()
+}
+
+// Returns an error if the Resource's model doesn't allow new Versions to be
+// added once the Resource has been deprecated
+func (r *Resource) CheckDeprecated() error {
+	_, rm := r.GetModels()
+	if rm.DeprecatedBlocksVersions && r.IsDeprecated() {
+		return fmt.Errorf("Can't add a new Version to Resource %q, "+
+			"it's deprecated", r.UID)
+	}
+	return nil
+}
+
+// Adds the "Deprecation" (RFC 9745) and "Sunset" (RFC 8594) headers if
+// "e" has been deprecated. If there's an "alternative" then it's added as
+// a "successor-version" link (RFC 5829).
+func AddDeprecationHeaders(info *RequestInfo, e *Entity) {
+	dep, ok := e.Get(DeprecatedAttr.Name).(map[string]any)
+	if !ok {
+		return
+	}
+
+	deprecation := "true"
+	if str, ok := dep["effective"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, str); err == nil {
+			deprecation = fmt.Sprintf("@%d", t.Unix())
+		}
+	}
+	info.AddHeader("Deprecation", deprecation)
+
+	if str, ok := dep["removal"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, str); err == nil {
+			info.AddHeader("Sunset", t.UTC().Format(http.TimeFormat))
+		}
+	}
+
+	if str, ok := dep["alternative"].(string); ok && str != "" {
+		info.AddHeader("Link", "<"+str+">; rel=\"successor-version\"")
+	}
+}
@@ -111,6 +111,10 @@ func (e *Entity) GetPP(pp *PropPath) any {
 			panic("too many results")
 		}
 
+		// SQLite gives back a string if that's what was saved
+		if str, ok := (*(row[0])).(string); ok {
+			return []byte(str)
+		}
 		return (*(row[0])).([]byte)
 	}
 
@@ -360,7 +364,9 @@ func (e *Entity) JustSet(pp *PropPath, val any) error {
 		if e.Object == nil {
 			e.NewObject = map[string]any{}
 		} else {
-			e.NewObject = maps.Clone(e.Object)
+			// Deep copy so that changes to nested values don't show up
+			// in Object, otherwise we can't tell what changed
+			e.NewObject = DeepCopy(e.Object).(map[string]any)
 		}
 	}
 
@@ -410,15 +416,114 @@ func (e *Entity) ValidateAndSave() error {
 			e.Abstract, e.UID, ToJSON(e.Object), ToJSON(e.NewObject))
 	}
 
-	if err := e.Validate(); err != nil {
+	if err := e.ValidateAndPrep(); err != nil {
 		return err
 	}
 
-	if err := PrepUpdateEntity(e); err != nil {
+	return e.Save()
+}
+
+// Same as ValidateAndSave except that the NewObject isn't saved yet, so the
+// caller can check the final (prepped) values first
+func (e *Entity) ValidateAndPrep() error {
+	if err := e.CheckReadOnly(); err != nil {
 		return err
 	}
 
-	return e.Save()
+	if err := e.CheckImmutable(); err != nil {
+		return err
+	}
+
+	// Fill in the defaults first so they're validated just like any
+	// client provided value. Resources skip this since their default
+	// Version will do it.
+	if e.Level != 2 {
+		ApplyDefaults(e.NewObject, e.GetBaseAttributes())
+	}
+
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	return PrepUpdateEntity(e)
+}
+
+// Returns an error if "e" is (or is part of) a read-only Resource, unless
+// the Tx says the server itself is changing it (Tx.WriteReadOnly)
+func (e *Entity) CheckReadOnly() error {
+	if e.tx == nil || e.tx.WriteReadOnly || e.Level < 2 {
+		return nil
+	}
+	if _, rm := e.GetModels(); rm != nil && rm.ReadOnly {
+		return fmt.Errorf("Write operations to read-only resources are not " +
+			"allowed")
+	}
+	return nil
+}
+
+// Returns an error if any "immutable" attribute that already has a value
+// is being changed or removed. Spec defined attributes have their own checks.
+func (e *Entity) CheckImmutable() error {
+	if e.Level == 2 || e.Object == nil || e.NewObject == nil {
+		// Resources skip validation, their Version will do the check
+		return nil
+	}
+
+	oldObj := map[string]any{}
+	for key, val := range e.Object {
+		if _, ok := SpecProps[key]; !ok {
+			oldObj[key] = val
+		}
+	}
+
+	attrs := e.GetAttributes(e.NewObject)
+	return CheckImmutableObject(oldObj, e.NewObject, attrs, NewPP())
+}
+
+func CheckImmutableObject(oldObj, newObj map[string]any, attrs Attributes,
+	path *PropPath) error {
+
+	for _, key := range SortedKeys(oldObj) {
+		oldVal := oldObj[key]
+		if key[0] == '#' || IsNil(oldVal) {
+			continue
+		}
+
+		attr := attrs[key]
+		if attr == nil {
+			if attr = attrs["*"]; attr == nil {
+				continue
+			}
+		}
+
+		newVal := newObj[key]
+		if attr.Immutable {
+			if ToJSON(oldVal) != ToJSON(newVal) {
+				return fmt.Errorf("Attribute %q is immutable and can't be "+
+					"changed", path.P(key).UI())
+			}
+			continue
+		}
+
+		// Immutable attributes can be nested in objects
+		if attr.Type == OBJECT {
+			oldMap, ok1 := oldVal.(map[string]any)
+			newMap, ok2 := newVal.(map[string]any)
+			if !ok1 {
+				continue
+			}
+			if !ok2 {
+				newMap = map[string]any{}
+			}
+			err := CheckImmutableObject(oldMap, newMap, attr.Attributes,
+				path.P(key))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
 // This is really just an internal Setter used for testing.
@@ -562,7 +667,8 @@ func (e *Entity) SetFromDBName(name string, val *string, propType string) error
 	}
 
 	if propType == STRING || propType == URI || propType == URI_REFERENCE ||
-		propType == URI_TEMPLATE || propType == URL || propType == TIMESTAMP {
+		propType == URI_TEMPLATE || propType == URL || propType == TIMESTAMP ||
+		propType == XID {
 		return ObjectSetProp(e.Object, pp, *val)
 	} else if propType == BOOLEAN {
 		// Technically the "1" check shouldn't be needed, but just in case
@@ -639,7 +745,7 @@ func readNextEntity(tx *Tx, results *Result) (*Entity, error) {
 			// If the next row isn't part of the current Entity then
 			// push it back into the result set so we'll grab it the next time
 			// we're called. And exit.
-			if entity.Level != level || entity.Plural != plural || entity.UID != uid {
+			if entity.DbSID != NotNilString(row[3]) {
 				results.Push()
 				break
 			}
@@ -1301,7 +1407,13 @@ func (e *Entity) GetBaseAttributes() Attributes {
 	if rm == nil {
 		return gm.GetBaseAttributes()
 	}
-	return rm.GetBaseAttributes()
+
+	attrs := rm.GetBaseAttributes()
+	if e.Level == 2 {
+		attrs[VersionTagsAttr.Name] = VersionTagsAttr
+	}
+	attrs[DeprecatedAttr.Name] = DeprecatedAttr
+	return attrs
 }
 
 // Given a PropPath and a value this will add the necessary golang data
@@ -1393,9 +1505,7 @@ func MaterializeProp(current any, pp *PropPath, val any, prev *PropPath) (any, e
 // Doesn't fully validate in the sense that it'll assume read-only fields
 // are not worth checking since the server generated them.
 // This is mainly used for validating input from a client.
-// NOTE!!! This isn't a read-only operation. Normally it would be, but to
-// avoid traversing the entity more than once, we will tweak things if needed.
-// For example, if a missing attribute has a Default value then we'll add it.
+// Any "default" values need to be added (ApplyDefaults) before calling this.
 func (e *Entity) Validate() error {
 	if e.Level == 2 {
 		// Skip Resources // TODO DUG - would prefer to not do this
@@ -1498,12 +1608,6 @@ func (e *Entity) ValidateObject(val any, origAttrs Attributes, path *PropPath) e
 
 			val, ok := newObj[key]
 
-			// A Default value is defined but there's no value, so set it
-			// and then let normal processing continue
-			if !IsNil(attr.Default) && (!ok || IsNil(val)) {
-				newObj[key] = attr.Default
-			}
-
 			// Based on the attribute's type check the incoming 'val'.
 			// This will check for adherence to the model (eg type),
 			// the next section (checkFn) will allow for more detailed
@@ -1616,9 +1720,9 @@ func (e *Entity) ValidateAttribute(val any, attr *Attribute, path *PropPath) err
 	} else if IsScalar(attr.Type) {
 		return e.ValidateScalar(val, attr, path)
 	} else if attr.Type == MAP {
-		return e.ValidateMap(val, attr.Item, path)
+		return e.ValidateMap(val, attr, path)
 	} else if attr.Type == ARRAY {
-		return e.ValidateArray(val, attr.Item, path)
+		return e.ValidateArray(val, attr, path)
 	} else if attr.Type == OBJECT {
 		/*
 			attrs := e.GetBaseAttributes()
@@ -1636,10 +1740,12 @@ func (e *Entity) ValidateAttribute(val any, attr *Attribute, path *PropPath) err
 	panic(fmt.Sprintf("Unknown type(%s): %s", path.UI(), attr.Type))
 }
 
-func (e *Entity) ValidateMap(val any, item *Item, path *PropPath) error {
+func (e *Entity) ValidateMap(val any, mapAttr *Attribute, path *PropPath) error {
 	log.VPrintf(3, ">Enter: ValidateMap(%s)", path.UI())
 	defer log.VPrintf(3, "<Exit: ValidateMap")
 
+	item := mapAttr.Item
+
 	if log.GetVerbose() > 2 {
 		log.VPrintf(3, " item: %v", ToJSON(item))
 		log.VPrintf(3, " val: %v", ToJSON(val))
@@ -1654,6 +1760,10 @@ func (e *Entity) ValidateMap(val any, item *Item, path *PropPath) error {
 		return fmt.Errorf("Attribute %q must be a map", path.UI())
 	}
 
+	if err := mapAttr.CheckItemCount(val, path); err != nil {
+		return err
+	}
+
 	// All values in the map must be of the same type
 	attr := &Attribute{
 		Type:       item.Type,
@@ -1675,10 +1785,12 @@ func (e *Entity) ValidateMap(val any, item *Item, path *PropPath) error {
 	return nil
 }
 
-func (e *Entity) ValidateArray(val any, item *Item, path *PropPath) error {
+func (e *Entity) ValidateArray(val any, arrayAttr *Attribute, path *PropPath) error {
 	log.VPrintf(3, ">Enter: ValidateArray(%s)", path.UI())
 	defer log.VPrintf(3, "<Exit: ValidateArray")
 
+	item := arrayAttr.Item
+
 	if log.GetVerbose() > 2 {
 		log.VPrintf(3, "item: %s", ToJSON(item))
 		log.VPrintf(3, "val: %s", ToJSON(val))
@@ -1693,6 +1805,10 @@ func (e *Entity) ValidateArray(val any, item *Item, path *PropPath) error {
 		return fmt.Errorf("Attribute %q must be an array", path.UI())
 	}
 
+	if err := arrayAttr.CheckItemCount(val, path); err != nil {
+		return err
+	}
+
 	// All values in the array must be of the same type
 	attr := &Attribute{
 		Type:       item.Type,
@@ -1775,6 +1891,13 @@ func (e *Entity) ValidateScalar(val any, attr *Attribute, path *PropPath) error
 		if valKind != reflect.String {
 			return fmt.Errorf("Attribute %q must be a url", path.UI())
 		}
+	case XID:
+		if valKind != reflect.String {
+			return fmt.Errorf("Attribute %q must be an xid", path.UI())
+		}
+		if err := e.CheckXID(val.(string)); err != nil {
+			return fmt.Errorf("Attribute %q %s", path.UI(), err)
+		}
 	case TIMESTAMP:
 		if valKind != reflect.String {
 			return fmt.Errorf("Attribute %q must be a timestamp", path.UI())
@@ -1787,7 +1910,12 @@ func (e *Entity) ValidateScalar(val any, attr *Attribute, path *PropPath) error
 		}
 	}
 
-	// don't "return nil" above, we may need to check enum values
+	// don't "return nil" above, we may need to check constraints and
+	// enum values
+	if err := attr.CheckScalarConstraints(val, path); err != nil {
+		return err
+	}
+
 	if len(attr.Enum) > 0 && attr.GetStrict() {
 		foundOne := false
 		valStr := fmt.Sprintf("%v", val)
@@ -1844,5 +1972,87 @@ func PrepUpdateEntity(e *Entity) error {
 		}
 	}
 
+	// Resources skip this since their default Version will do it
+	if e.Level == 2 {
+		return nil
+	}
+
+	return CheckServerRequired(e.NewObject, e.GetAttributes(e.NewObject),
+		NewPP())
+}
+
+// Fill in any missing attributes that have a "default" value. Nested
+// objects are only filled in if they're present, or are "serverrequired".
+// "attrs" should not include the "ifvalues" sibling attributes since they
+// depend on the values in "obj", which can change as defaults are added.
+func ApplyDefaults(obj map[string]any, baseAttrs Attributes) {
+	attrs := Attributes(nil)
+
+	// A default value can turn on some "ifvalues" sibling attributes,
+	// which can have defaults of their own, so loop until nothing changes
+	for changed := true; changed; {
+		changed = false
+		attrs = maps.Clone(baseAttrs)
+		attrs.AddIfValuesAttributes(obj)
+
+		for _, key := range SortedKeys(attrs) {
+			attr := attrs[key]
+			if key == "*" || attr.ReadOnly {
+				continue
+			}
+			if val, ok := obj[key]; ok && !IsNil(val) {
+				continue
+			}
+
+			if !IsNil(attr.Default) {
+				obj[key] = attr.Default
+				changed = true
+			} else if attr.Type == OBJECT && attr.ServerRequired {
+				obj[key] = map[string]any{}
+				changed = true
+			}
+		}
+	}
+
+	for _, key := range SortedKeys(attrs) {
+		attr := attrs[key]
+		if attr.Type != OBJECT || key == "*" {
+			continue
+		}
+		if nested, ok := obj[key].(map[string]any); ok {
+			ApplyDefaults(nested, attr.Attributes)
+		}
+	}
+}
+
+// Make sure all "serverrequired" attributes have a value. Spec defined
+// attributes are skipped since the server will always set them.
+func CheckServerRequired(obj map[string]any, attrs Attributes,
+	path *PropPath) error {
+
+	for _, key := range SortedKeys(attrs) {
+		attr := attrs[key]
+		if key == "*" || attr.ReadOnly {
+			continue
+		}
+		if _, ok := SpecProps[key]; ok && path.Len() == 0 {
+			continue
+		}
+
+		val, ok := obj[key]
+		if attr.ServerRequired && (!ok || IsNil(val)) {
+			return fmt.Errorf("Required property %q is missing",
+				path.P(key).UI())
+		}
+
+		if nested, ok := val.(map[string]any); ok && attr.Type == OBJECT {
+			nestedAttrs := maps.Clone(attr.Attributes)
+			nestedAttrs.AddIfValuesAttributes(nested)
+			err := CheckServerRequired(nested, nestedAttrs, path.P(key))
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
@@ -449,3 +449,69 @@ func TestGetPropUpdate(t *testing.T) {
 	}
 
 }
+
+func TestApplyDefaults(t *testing.T) {
+	type Obj = map[string]any
+
+	attrs := Attributes{
+		"str": {Name: "str", Type: STRING, ServerRequired: true,
+			Default: "hello"},
+		"format": {Name: "format", Type: STRING, ServerRequired: true,
+			Default: "json",
+			IfValues: IfValues{
+				"json": &IfValue{SiblingAttributes: Attributes{
+					"indent": {Name: "indent", Type: INTEGER,
+						ServerRequired: true, Default: 2},
+				}},
+			}},
+		"opt": {Name: "opt", Type: OBJECT,
+			Attributes: Attributes{
+				"num": {Name: "num", Type: INTEGER, ServerRequired: true,
+					Default: 5},
+			}},
+		"req": {Name: "req", Type: OBJECT, ServerRequired: true,
+			Attributes: Attributes{
+				"flag": {Name: "flag", Type: BOOLEAN, ServerRequired: true,
+					Default: true},
+				"nodef": {Name: "nodef", Type: STRING},
+			}},
+	}
+
+	type Test struct {
+		Start  Obj
+		Result Obj
+	}
+
+	tests := []Test{
+		{Obj{}, Obj{"str": "hello", "format": "json", "indent": 2,
+			"req": Obj{"flag": true}}},
+		{Obj{"str": "bye", "format": "xml"}, Obj{"str": "bye",
+			"format": "xml", "req": Obj{"flag": true}}},
+		{Obj{"str": nil, "opt": Obj{}}, Obj{"str": "hello", "format": "json",
+			"indent": 2, "opt": Obj{"num": 5}, "req": Obj{"flag": true}}},
+		{Obj{"indent": 4, "req": Obj{"flag": false, "nodef": "x"}},
+			Obj{"str": "hello", "format": "json", "indent": 4,
+				"req": Obj{"flag": false, "nodef": "x"}}},
+	}
+
+	for i, test := range tests {
+		obj := DeepCopy(test.Start).(Obj)
+		ApplyDefaults(obj, attrs)
+
+		exp := ToJSON(test.Result)
+		got := ToJSON(obj)
+		if got != exp {
+			t.Fatalf("Test %d:\nExp: %s\nGot: %s\n", i, exp, got)
+		}
+
+		if err := CheckServerRequired(obj, attrs, NewPP()); err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+	}
+
+	err := CheckServerRequired(map[string]any{"req": map[string]any{}},
+		Attributes{"req": attrs["req"], "str": attrs["str"]}, NewPP())
+	if err == nil || err.Error() != `Required property "req.flag" is missing` {
+		t.Fatalf("Bad error: %v", err)
+	}
+}
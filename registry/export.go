@@ -0,0 +1,442 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// An export directory holds a copy of a Registry:
+//
+//	model.json                                     - the model
+//	registry.json                                  - the Registry's attributes
+//	GROUPS/gID/meta.json                           - a Group's attributes
+//	GROUPS/gID/RESOURCES/rID/meta.json             - a Resource's attributes
+//	GROUPS/gID/RESOURCES/rID/versions/vID/meta.json - a Version's attributes
+//	GROUPS/gID/RESOURCES/rID/versions/vID/SINGULAR.EXT - its document
+//
+// A Resource's meta.json only has the attributes that belong to the
+// Resource rather than its default Version (e.g. "stickydefaultversion"),
+// plus the IDs of its Versions, oldest first, in "versionsorder".
+const (
+	ExportModelFile    = "model.json"
+	ExportRegistryFile = "registry.json"
+	ExportMetaFile     = "meta.json"
+	ExportVersionOrder = "versionsorder"
+)
+
+// Attributes that the server generates so they're never exported
+var exportSkipAttrs = []string{"specversion", "self", "epoch", "isdefault",
+	"defaultversionurl", "model"}
+
+// File extensions of the common document content types. Others come
+// from the "mime" package, if it knows about them.
+var docExtensions = map[string]string{
+	"application/json":         ".json",
+	"application/octet-stream": ".bin",
+	"application/xml":          ".xml",
+	"application/x-yaml":       ".yaml",
+	"application/yaml":         ".yaml",
+	"text/html":                ".html",
+	"text/plain":               ".txt",
+	"text/xml":                 ".xml",
+	"text/yaml":                ".yaml",
+}
+
+// An entity read from, or to be written to, an export directory
+type ExportEntity struct {
+	Level      int            // 0=Registry, 1=Group, 2=Resource, 3=Version
+	Path       string         // e.g. dirs/d1/files/f1, "" for the Registry
+	Object     map[string]any // The exported attributes, see ExportObject
+	Document   []byte         // Versions only, nil if there isn't one
+	VersionIDs []string       // Resources only, oldest first
+}
+
+// Returns the name of the file for a Version's document based on its
+// content type, e.g. "schema.json"
+func DocFileName(singular string, contentType string) string {
+	ct, _, _ := strings.Cut(contentType, ";")
+	ct = strings.ToLower(strings.TrimSpace(ct))
+
+	ext := docExtensions[ct]
+	if ext == "" {
+		switch {
+		case strings.HasSuffix(ct, "+json"):
+			ext = ".json"
+		case strings.HasSuffix(ct, "+xml"):
+			ext = ".xml"
+		case strings.HasSuffix(ct, "+yaml"):
+			ext = ".yaml"
+		default:
+			if exts, _ := mime.ExtensionsByType(ct); len(exts) > 0 {
+				ext = exts[0]
+			}
+		}
+	}
+
+	if singular+ext == ExportMetaFile {
+		return "document" + ext
+	}
+	return singular + ext
+}
+
+// Returns a copy of "obj", the serialization of an entity at "level", with
+// just the attributes that should be exported. The ones generated by the
+// server (e.g. "self"), nested collections ("colls", e.g. "files", and
+// their "url" and "count" attributes) and the Registry's "id" are removed.
+// Resources only keep the attributes that aren't on their Versions, and
+// Versions lose those along with any inlined document.
+func ExportObject(obj map[string]any, level int, singular string,
+	colls []string) map[string]any {
+
+	res := map[string]any{}
+	for key, val := range obj {
+		coll := strings.TrimSuffix(strings.TrimSuffix(key, "url"), "count")
+		switch {
+		case slices.Contains(exportSkipAttrs, key):
+		case slices.Contains(colls, key) || slices.Contains(colls, coll):
+		case level == 0 && key == "id":
+		case level == 2 && !specialResourceAttrs[key]:
+		case level == 3 && key != "id" && specialResourceAttrs[key]:
+		case level == 3 && (key == singular || key == singular+"base64"):
+		default:
+			res[key] = val
+		}
+	}
+	return res
+}
+
+// Returns the model in the export directory "dir"
+func ReadExportModel(dir string) (*Model, error) {
+	fileName := filepath.Join(dir, ExportModelFile)
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %q: %s", fileName, err)
+	}
+
+	model := &Model{}
+	if err = Unmarshal(buf, model); err != nil {
+		return nil, fmt.Errorf("Error parsing %q: %s", fileName, err)
+	}
+	return model, nil
+}
+
+// Writes "ee" into the export directory "dir". "singular" is the name of
+// the Resource type's document, if it has one.
+func WriteExportEntity(dir string, ee *ExportEntity, singular string) error {
+	entDir := filepath.Join(dir, filepath.FromSlash(ee.Path))
+	fileName := filepath.Join(entDir, ExportMetaFile)
+	if ee.Level == 0 {
+		fileName = filepath.Join(dir, ExportRegistryFile)
+	}
+
+	obj := ee.Object
+	if ee.Level == 2 {
+		obj = maps.Clone(obj)
+		obj[ExportVersionOrder] = ee.VersionIDs
+	}
+
+	if err := writeExportJSON(fileName, obj); err != nil {
+		return err
+	}
+
+	if ee.Document != nil {
+		ct, _ := ee.Object["contenttype"].(string)
+		docFile := filepath.Join(entDir, DocFileName(singular, ct))
+		if err := os.WriteFile(docFile, ee.Document, 0644); err != nil {
+			return fmt.Errorf("Error writing %q: %s", docFile, err)
+		}
+	}
+	return nil
+}
+
+func writeExportJSON(fileName string, obj any) error {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return fmt.Errorf("Error creating directory for %q: %s", fileName,
+			err)
+	}
+
+	buf, err := json.MarshalIndent(obj, "", "  ")
+	if err == nil {
+		err = os.WriteFile(fileName, append(buf, '\n'), 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing %q: %s", fileName, err)
+	}
+	return nil
+}
+
+// Calls "fn" for each entity in the export directory "dir", whose model is
+// "model". Parents are visited before their children, except that a
+// Resource is visited after its Versions since creating a Resource means
+// creating its first Version. Versions are visited oldest first.
+func WalkExportDir(dir string, model *Model,
+	fn func(ee *ExportEntity) error) error {
+
+	obj, err := readExportJSON(filepath.Join(dir, ExportRegistryFile))
+	if err != nil {
+		return err
+	}
+	if err = fn(&ExportEntity{Level: 0, Object: obj}); err != nil {
+		return err
+	}
+
+	for _, gPlural := range SortedKeys(model.Groups) {
+		gm := model.Groups[gPlural]
+
+		gIDs, err := exportDirIDs(filepath.Join(dir, gPlural))
+		if err != nil {
+			return err
+		}
+		for _, gID := range gIDs {
+			gPath := gPlural + "/" + gID
+			if err = walkExportGroup(dir, gm, gPath, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func walkExportGroup(dir string, gm *GroupModel, gPath string,
+	fn func(ee *ExportEntity) error) error {
+
+	obj, err := readExportJSON(filepath.Join(dir, gPath, ExportMetaFile))
+	if err != nil {
+		return err
+	}
+	if err = fn(&ExportEntity{Level: 1, Path: gPath, Object: obj}); err != nil {
+		return err
+	}
+
+	for _, rPlural := range SortedKeys(gm.Resources) {
+		rIDs, err := exportDirIDs(filepath.Join(dir, gPath, rPlural))
+		if err != nil {
+			return err
+		}
+		for _, rID := range rIDs {
+			rPath := gPath + "/" + rPlural + "/" + rID
+			if err = walkExportResource(dir, rPath, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func walkExportResource(dir string, rPath string,
+	fn func(ee *ExportEntity) error) error {
+
+	fileName := filepath.Join(dir, rPath, ExportMetaFile)
+	obj, err := readExportJSON(fileName)
+	if err != nil {
+		return err
+	}
+
+	// Versions listed in "versionsorder" go first, any others are
+	// assumed to be newer
+	vIDs := []string{}
+	if order, ok := obj[ExportVersionOrder]; ok {
+		list, _ := order.([]any)
+		for _, vID := range list {
+			str, ok := vID.(string)
+			if !ok || str == "" || slices.Contains(vIDs, str) {
+				return fmt.Errorf("%q in %q must be a list of unique "+
+					"Version IDs", ExportVersionOrder, fileName)
+			}
+			vIDs = append(vIDs, str)
+		}
+		delete(obj, ExportVersionOrder)
+	}
+
+	dirIDs, err := exportDirIDs(filepath.Join(dir, rPath, "versions"))
+	if err != nil {
+		return err
+	}
+	for _, vID := range vIDs {
+		if !slices.Contains(dirIDs, vID) {
+			return fmt.Errorf("Version %q in %q doesn't exist", vID, fileName)
+		}
+	}
+	for _, vID := range dirIDs {
+		if !slices.Contains(vIDs, vID) {
+			vIDs = append(vIDs, vID)
+		}
+	}
+	if len(vIDs) == 0 {
+		return fmt.Errorf("Resource %q has no Versions", rPath)
+	}
+
+	for _, vID := range vIDs {
+		vPath := rPath + "/versions/" + vID
+		ee, err := readExportVersion(dir, vPath)
+		if err != nil {
+			return err
+		}
+		if err = fn(ee); err != nil {
+			return err
+		}
+	}
+
+	return fn(&ExportEntity{Level: 2, Path: rPath, Object: obj,
+		VersionIDs: vIDs})
+}
+
+func readExportVersion(dir string, vPath string) (*ExportEntity, error) {
+	vDir := filepath.Join(dir, filepath.FromSlash(vPath))
+
+	obj, err := readExportJSON(filepath.Join(vDir, ExportMetaFile))
+	if err != nil {
+		return nil, err
+	}
+	ee := &ExportEntity{Level: 3, Path: vPath, Object: obj}
+
+	// Any other file is the document
+	entries, err := os.ReadDir(vDir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %q: %s", vDir, err)
+	}
+	docFile := ""
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == ExportMetaFile {
+			continue
+		}
+		if docFile != "" {
+			return nil, fmt.Errorf("Version %q has more than one document "+
+				"file (%q and %q)", vPath, docFile, entry.Name())
+		}
+		docFile = entry.Name()
+	}
+
+	if docFile != "" {
+		docFile = filepath.Join(vDir, docFile)
+		if ee.Document, err = os.ReadFile(docFile); err != nil {
+			return nil, fmt.Errorf("Error reading %q: %s", docFile, err)
+		}
+	}
+	return ee, nil
+}
+
+func readExportJSON(fileName string) (map[string]any, error) {
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %q: %s", fileName, err)
+	}
+
+	obj := map[string]any{}
+	if err = Unmarshal(buf, &obj); err != nil {
+		return nil, fmt.Errorf("Error parsing %q: %s", fileName, err)
+	}
+	return obj, nil
+}
+
+// Returns the (sorted) names of the sub-directories of "dir", which are
+// entity IDs. It's ok if "dir" doesn't exist.
+func exportDirIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Error reading %q: %s", dir, err)
+	}
+
+	ids := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids, nil
+}
+
+// Loads the export directory "dir" (see WalkExportDir) into "reg", which
+// must not have any Groups yet. Its model is replaced by the exported one.
+// IDs, the order of Versions, default Versions and version tags are kept.
+// Since it's the server loading the data, read-only Resources are loaded too.
+func (reg *Registry) ImportDir(dir string) error {
+	model, err := ReadExportModel(dir)
+	if err != nil {
+		return err
+	}
+
+	groups, err := RawEntitiesFromQuery(reg.tx, reg.DbSID, `Level=1`)
+	if err != nil {
+		return err
+	}
+	if len(groups) > 0 {
+		return fmt.Errorf("Registry %q isn't empty", reg.UID)
+	}
+
+	if err = reg.Model.ApplyNewModel(model); err != nil {
+		return err
+	}
+
+	defer func(old bool) { reg.tx.WriteReadOnly = old }(reg.tx.WriteReadOnly)
+	reg.tx.WriteReadOnly = true
+
+	return WalkExportDir(dir, reg.Model, func(ee *ExportEntity) error {
+		parts := strings.Split(ee.Path, "/")
+
+		switch ee.Level {
+		case 0:
+			return reg.Update(ee.Object, ADD_PATCH, false)
+		case 1:
+			_, err := reg.AddGroupWithObject(parts[0], parts[1], ee.Object,
+				false)
+			return err
+		}
+
+		group, err := reg.FindGroup(parts[0], parts[1], false)
+		if err != nil {
+			return err
+		}
+		resource, err := group.FindResource(parts[2], parts[3], false)
+		if err != nil {
+			return err
+		}
+
+		if ee.Level == 3 {
+			if ee.Document != nil {
+				ee.Object["#resource"] = ee.Document
+			}
+			if resource == nil {
+				_, err = group.AddResourceWithObject(parts[2], parts[3],
+					parts[5], ee.Object, false, true)
+			} else {
+				_, _, err = resource.UpsertVersionWithObject(parts[5],
+					ee.Object, ADD_ADD)
+			}
+			if err != nil {
+				return fmt.Errorf("Error importing %q: %s", ee.Path, err)
+			}
+			return nil
+		}
+
+		// The Resource's own attributes, now that its Versions are there
+		if ee.Object["stickydefaultversion"] == true {
+			vID, _ := ee.Object["defaultversionid"].(string)
+			if err = resource.SetDefaultID(vID); err != nil {
+				return err
+			}
+		}
+		if tags, ok := ee.Object[VersionTagsAttr.Name]; ok {
+			if err = resource.SetVersionTags(tags, false); err != nil {
+				return err
+			}
+		}
+		if val, ok := ee.Object[DeprecatedAttr.Name]; ok {
+			if err = resource.SetDeprecated(val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocFileName(t *testing.T) {
+	for _, test := range [][3]string{
+		{"file", "application/json", "file.json"},
+		{"file", "Application/JSON; charset=utf-8", "file.json"},
+		{"schema", "application/vnd.apache.avro+json", "schema.json"},
+		{"file", "text/plain; charset=utf-8", "file.txt"},
+		{"file", "", "file"},
+		{"file", "foo/bar", "file"},
+		{"meta", "application/json", "document.json"},
+	} {
+		if got := DocFileName(test[0], test[1]); got != test[2] {
+			t.Fatalf("%s/%s: Exp: %q Got: %q", test[0], test[1], test[2], got)
+		}
+	}
+}
+
+func TestExportObject(t *testing.T) {
+	obj := map[string]any{
+		"specversion": "0.5", "id": "x", "self": "http://x", "epoch": 2,
+		"name": "n", "filesurl": "http://x/files", "filescount": 1,
+		"files": map[string]any{}, "defaultversionid": "1",
+		"stickydefaultversion": true, "isdefault": true,
+		"defaultversionurl": "http://x/v/1", "versiontags": map[string]any{},
+		"file": "doc", "filebase64": "ZG9j", "fileurl": "http://doc",
+	}
+
+	tests := []struct {
+		level int
+		colls []string
+		exp   []string
+	}{
+		{0, []string{"files"}, []string{"defaultversionid", "file",
+			"filebase64", "fileurl", "name", "stickydefaultversion",
+			"versiontags"}},
+		{2, []string{"versions"}, []string{"defaultversionid", "id",
+			"stickydefaultversion", "versiontags"}},
+		{3, nil, []string{"files", "filescount", "filesurl", "fileurl", "id",
+			"name"}},
+	}
+
+	for _, test := range tests {
+		got := SortedKeys(ExportObject(obj, test.level, "file", test.colls))
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf("Level %d:\nExp: %v\nGot: %v", test.level, test.exp, got)
+		}
+	}
+}
+
+func TestWalkExportDir(t *testing.T) {
+	dir := t.TempDir()
+	model := &Model{
+		Groups: map[string]*GroupModel{
+			"dirs": {Plural: "dirs", Singular: "dir",
+				Resources: map[string]*ResourceModel{
+					"files": {Plural: "files", Singular: "file"},
+				}},
+		},
+	}
+
+	entities := []*ExportEntity{
+		{Level: 0, Object: map[string]any{"name": "reg"}},
+		{Level: 1, Path: "dirs/d1", Object: map[string]any{"id": "d1"}},
+		{Level: 3, Path: "dirs/d1/files/f1/versions/v2",
+			Object: map[string]any{"id": "v2",
+				"contenttype": "application/json"},
+			Document: []byte(`{"v":2}`)},
+		{Level: 3, Path: "dirs/d1/files/f1/versions/v10",
+			Object: map[string]any{"id": "v10"}},
+		{Level: 2, Path: "dirs/d1/files/f1",
+			Object: map[string]any{"id": "f1", "defaultversionid": "v2",
+				"stickydefaultversion": true},
+			VersionIDs: []string{"v2", "v10"}},
+	}
+	for _, ee := range entities {
+		if err := WriteExportEntity(dir, ee, "file"); err != nil {
+			t.Fatalf("Error writing %q: %s", ee.Path, err)
+		}
+	}
+
+	_, err := os.Stat(filepath.Join(dir,
+		"dirs/d1/files/f1/versions/v2/file.json"))
+	if err != nil {
+		t.Fatalf("Missing doc file: %s", err)
+	}
+
+	check := func() {
+		t.Helper()
+		i := 0
+		err := WalkExportDir(dir, model, func(ee *ExportEntity) error {
+			exp := entities[i]
+			i++
+			if ee.Level != exp.Level || ee.Path != exp.Path ||
+				!reflect.DeepEqual(ee.Object, exp.Object) ||
+				!reflect.DeepEqual(ee.Document, exp.Document) ||
+				!reflect.DeepEqual(ee.VersionIDs, exp.VersionIDs) {
+				t.Fatalf("Entity %d:\nExp: %#v\nGot: %#v", i-1, exp, ee)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if i != len(entities) {
+			t.Fatalf("Only saw %d entities", i)
+		}
+	}
+	check()
+
+	// Versions that aren't in "versionsorder" are the newest ones
+	metaFile := filepath.Join(dir, "dirs/d1/files/f1/meta.json")
+	os.WriteFile(metaFile, []byte(`{"id":"f1","defaultversionid":"v2",`+
+		`"stickydefaultversion":true,"versionsorder":["v2"]}`), 0644)
+	check()
+
+	os.WriteFile(metaFile, []byte(`{"id":"f1","versionsorder":["v3"]}`),
+		0644)
+	err = WalkExportDir(dir, model, func(ee *ExportEntity) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), `Version "v3" in`) {
+		t.Fatalf("Bad error: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "dirs/d1/files/f1/versions/v2/x.txt"),
+		[]byte("x"), 0644)
+	os.WriteFile(metaFile, []byte(`{"id":"f1"}`), 0644)
+	err = WalkExportDir(dir, model, func(ee *ExportEntity) error {
+		return nil
+	})
+	if err == nil || err.Error() != `Version "dirs/d1/files/f1/versions/v2" `+
+		`has more than one document file ("file.json" and "x.txt")` {
+		t.Fatalf("Bad error: %v", err)
+	}
+}
@@ -100,6 +100,8 @@ func (g *Group) UpsertResourceWithObject(rType string, id string, vID string, ob
 
 	// List of versions in the incoming request
 	versions := map[string]any(nil)
+	tags, hasTags := any(nil), false
+	deprecated, hasDeprecated := any(nil), false
 
 	if !objIsVer {
 		// If obj is for the resource then save and delete the versions
@@ -120,6 +122,14 @@ func (g *Group) UpsertResourceWithObject(rType string, id string, vID string, ob
 		delete(obj, "versions")
 		delete(obj, "versionscount")
 		delete(obj, "versionsurl")
+
+		// Version tags are on the Resource, so save them for later
+		tags, hasTags = obj[VersionTagsAttr.Name]
+		delete(obj, VersionTagsAttr.Name)
+
+		// Same for "deprecated", the Version has its own
+		deprecated, hasDeprecated = obj[DeprecatedAttr.Name]
+		delete(obj, DeprecatedAttr.Name)
 	}
 
 	isNew := (r == nil)
@@ -301,6 +311,18 @@ func (g *Group) UpsertResourceWithObject(rType string, id string, vID string, ob
 		}
 	}
 
+	if hasTags {
+		if err := r.SetVersionTags(tags, addType == ADD_PATCH); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if hasDeprecated {
+		if err := r.SetDeprecated(deprecated); err != nil {
+			return nil, false, err
+		}
+	}
+
 	return r, isNew, err
 }
 
@@ -502,5 +524,55 @@ func (g *Group) Delete() error {
 	log.VPrintf(3, ">Enter: Group.Delete(%s)", g.UID)
 	defer log.VPrintf(3, "<Exit: Group.Delete")
 
-	return DoOne(g.tx, `DELETE FROM "Groups" WHERE SID=?`, g.DbSID)
+	if err := g.CheckReadOnlyResources(); err != nil {
+		return err
+	}
+
+	refs, err := g.Registry.GetDeleteRefs(g.Path)
+	if err != nil {
+		return err
+	}
+
+	if err = DoOne(g.tx, `DELETE FROM "Groups" WHERE SID=?`, g.DbSID); err != nil {
+		return err
+	}
+
+	return g.Registry.CascadeDelete(refs)
+}
+
+// Returns an error if the Group has any Resources of a read-only type,
+// since deleting the Group would delete them too. This is allowed if the
+// Tx says the server itself is changing them (Tx.WriteReadOnly).
+func (g *Group) CheckReadOnlyResources() error {
+	if g.tx == nil || g.tx.WriteReadOnly {
+		return nil
+	}
+
+	gm, _ := g.GetModels()
+	if gm == nil {
+		return nil
+	}
+
+	for _, plural := range SortedKeys(gm.Resources) {
+		rm := gm.Resources[plural]
+		if !rm.ReadOnly {
+			continue
+		}
+
+		results, err := Query(g.tx, `
+			SELECT SID FROM Resources WHERE GroupSID=? AND ModelSID=? LIMIT 1`,
+			g.DbSID, rm.SID)
+		if err != nil {
+			results.Close()
+			return err
+		}
+		row := results.NextRow()
+		results.Close()
+
+		if row != nil {
+			return fmt.Errorf("Write operations to read-only resources are "+
+				"not allowed, Group %q has %q Resources", g.UID, plural)
+		}
+	}
+	return nil
 }
@@ -622,17 +622,34 @@ func GetVersionModelInlines(rm *ResourceModel) []string {
 }
 
 func HTTPGETModel(info *RequestInfo) error {
+	if len(info.Parts) == 2 && info.Parts[1] == "history" {
+		return HTTPGETModelHistory(info)
+	}
+
 	if len(info.Parts) > 1 {
 		info.StatusCode = http.StatusNotFound
 		return fmt.Errorf("Not found")
 	}
 
+	model := info.Registry.Model
+	if info.OriginalRequest.URL.Query().Has("revision") {
+		rev, err := GetModelRevisionFlag(info)
+		if err != nil {
+			return err
+		}
+		model = rev.Model
+	}
+
+	return HTTPWriteModel(info, model)
+}
+
+// Serialize "model" based on the ?schema flag
+func HTTPWriteModel(info *RequestInfo, model *Model) error {
 	format := info.OriginalRequest.URL.Query().Get("schema")
 	if format == "" {
 		format = "xRegistry-json"
 	}
 
-	model := info.Registry.Model
 	if model == nil {
 		model = &Model{}
 	}
@@ -653,6 +670,68 @@ func HTTPGETModel(info *RequestInfo) error {
 	return nil
 }
 
+// GET /model/history - the list of model revisions, without the models
+func HTTPGETModelHistory(info *RequestInfo) error {
+	revs, err := info.Registry.GetModelRevisions()
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	buf, err := json.MarshalIndent(revs, "", "  ")
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	info.AddHeader("Content-Type", "application/json")
+	info.Write(buf)
+	info.Write([]byte("\n"))
+	return nil
+}
+
+// Returns the model revision specified by the ?revision flag
+func GetModelRevisionFlag(info *RequestInfo) (*ModelRevision, error) {
+	revStr := info.OriginalRequest.URL.Query().Get("revision")
+	epoch, err := strconv.Atoi(revStr)
+	if err != nil || epoch < 1 {
+		info.StatusCode = http.StatusBadRequest
+		return nil, fmt.Errorf("Invalid \"revision\" value: %q", revStr)
+	}
+
+	rev, err := info.Registry.GetModelRevision(epoch)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return nil, err
+	}
+	if rev == nil {
+		info.StatusCode = http.StatusNotFound
+		return nil, fmt.Errorf("Model revision %d not found", epoch)
+	}
+	return rev, nil
+}
+
+// Dry-run of the retention sweeper. Returns a map of the paths of the
+// Versions that would be deleted and why
+func HTTPGETRetention(info *RequestInfo) error {
+	list, _, err := info.Registry.ApplyRetention(true)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	buf, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	info.AddHeader("Content-Type", "application/json")
+	info.Write(buf)
+	info.Write([]byte("\n"))
+	return nil
+}
+
 func HTTPGETContent(info *RequestInfo) error {
 	log.VPrintf(3, ">Enter: HTTPGetContent")
 	defer log.VPrintf(3, "<Exit: HTTPGetContent")
@@ -756,6 +835,7 @@ FROM FullTree WHERE RegSID=? AND `
 	if err != nil {
 		panic(err)
 	}
+	AddDeprecationHeaders(info, entity)
 
 	if info.VersionUID == "" {
 		info.AddHeader("xRegistry-versionscount",
@@ -842,6 +922,10 @@ func HTTPGet(info *RequestInfo) error {
 		return HTTPGETModel(info)
 	}
 
+	if len(info.Parts) > 0 && info.Parts[0] == "retention" {
+		return HTTPGETRetention(info)
+	}
+
 	metaInBody := (info.ResourceModel == nil) ||
 		(info.ResourceModel.GetHasDocument() == false || info.ShowMeta)
 
@@ -913,6 +997,7 @@ func SerializeQuery(info *RequestInfo, paths []string, what string,
 	if what == "Coll" {
 		_, err = jw.WriteCollection()
 	} else {
+		AddDeprecationHeaders(info, jw.Entity)
 		err = jw.WriteEntity()
 	}
 
@@ -957,6 +1042,10 @@ func HTTPPutPost(info *RequestInfo) error {
 		return HTTPPUTModel(info)
 	}
 
+	if info.CheckCompat {
+		return HTTPCheckCompat(info)
+	}
+
 	// Load-up the body
 	// //////////////////////////////////////////////////////
 	body, err := io.ReadAll(info.OriginalRequest.Body)
@@ -1008,13 +1097,10 @@ func HTTPPutPost(info *RequestInfo) error {
 		return fmt.Errorf("PATCH is not allowed on Resource documents")
 	}
 
-	// PUT/POST /GROUPs/gID/RESOURCEs... + ReadOnly Resource
-	if info.ResourceModel != nil && info.ResourceModel.ReadOnly &&
-		(method == "PUT" || method == "POST") {
-		// Note that we only block it for end-user interactions, like via
-		// HTTP. If people try to change it via the internal APIs, then
-		// we don't stop it yet. Not sure if we should. TODO
-
+	// PUT/POST/PATCH /GROUPs/gID/RESOURCEs... + ReadOnly Resource
+	// Note that Entity.CheckReadOnly() will also catch these, and any
+	// nested ones, but this lets us return a nicer status code
+	if info.ResourceModel != nil && info.ResourceModel.ReadOnly {
 		info.StatusCode = http.StatusMethodNotAllowed
 		return fmt.Errorf("Write operations to read-only resources are not " +
 			"allowed")
@@ -1469,38 +1555,180 @@ func HTTPPutPost(info *RequestInfo) error {
 	return SerializeQuery(info, paths, what, nil)
 }
 
+// ?checkcompat is a dry-run of a write operation. Process the request as
+// normal but collect any compatibility violations rather than failing,
+// then throw away all changes and just return the list of violations.
+func HTTPCheckCompat(info *RequestInfo) error {
+	info.CheckCompat = false
+	info.tx.CheckCompat = true
+	info.tx.CompatIssues = []string{}
+
+	writer := info.HTTPWriter
+	info.HTTPWriter = DefaultDiscardWriter
+
+	err := HTTPPutPost(info)
+
+	info.HTTPWriter = writer
+	issues := info.tx.CompatIssues
+	info.tx.CheckCompat = false
+	info.tx.CompatIssues = nil
+
+	// Never keep any of the changes
+	if rbErr := info.tx.Rollback(); rbErr != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return rbErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.MarshalIndent(map[string]any{
+		"compatible": len(issues) == 0,
+		"issues":     issues,
+	}, "", "  ")
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	info.StatusCode = http.StatusOK
+	info.AddHeader("Content-Type", "application/json")
+	info.Write(buf)
+	info.Write([]byte("\n"))
+	return nil
+}
+
 func HTTPPUTModel(info *RequestInfo) error {
-	if len(info.Parts) > 1 {
+	model := &Model{}
+
+	if err := CheckModelEpochFlag(info); err != nil {
+		return err
+	}
+
+	if len(info.Parts) == 2 && info.Parts[1] == "rollback" &&
+		info.OriginalRequest.Method == "POST" {
+
+		// POST /model/rollback?revision=N - re-apply an older model
+		rev, err := GetModelRevisionFlag(info)
+		if err != nil {
+			return err
+		}
+
+		if !info.OriginalRequest.URL.Query().Has("dryrun") {
+			if err = info.Registry.RollbackModel(rev.Epoch); err != nil {
+				info.StatusCode = http.StatusBadRequest
+				return err
+			}
+			return HTTPWriteModel(info, info.Registry.Model)
+		}
+		model = rev.Model
+	} else if len(info.Parts) > 1 {
 		info.StatusCode = http.StatusNotFound
 		return fmt.Errorf("Not found")
+	} else {
+		reqBody, err := io.ReadAll(info.OriginalRequest.Body)
+		if err != nil {
+			info.StatusCode = http.StatusInternalServerError
+			return err
+		}
+
+		// err = json.Unmarshal(reqBody, model)
+		err = Unmarshal(reqBody, model)
+		if err != nil {
+			info.StatusCode = http.StatusInternalServerError
+			return err
+		}
+	}
+
+	if info.OriginalRequest.URL.Query().Has("dryrun") {
+		return HTTPModelDryRun(info, model)
 	}
 
-	reqBody, err := io.ReadAll(info.OriginalRequest.Body)
+	err := info.Registry.Model.ApplyNewModel(model)
 	if err != nil {
-		info.StatusCode = http.StatusInternalServerError
+		info.StatusCode = http.StatusBadRequest
 		return err
 	}
 
-	model := Model{}
-	// err = json.Unmarshal(reqBody, &model)
-	err = Unmarshal(reqBody, &model)
+	return HTTPWriteModel(info, info.Registry.Model)
+}
+
+// Process the ?epoch flag on PUT /model. If present then it must match the
+// epoch of the latest model revision, so that clients don't overwrite a
+// model they haven't seen.
+func CheckModelEpochFlag(info *RequestInfo) error {
+	epochStr := info.OriginalRequest.URL.Query().Get("epoch")
+	if epochStr == "" {
+		return nil
+	}
+
+	epochInt, err := strconv.Atoi(epochStr)
+	if err != nil || epochInt < 0 {
+		info.StatusCode = http.StatusBadRequest
+		return fmt.Errorf("Epoch value %q must be an UINTEGER", epochStr)
+	}
+
+	epoch, err := info.Registry.GetModelEpoch()
 	if err != nil {
 		info.StatusCode = http.StatusInternalServerError
 		return err
 	}
+	if epoch != epochInt {
+		info.StatusCode = http.StatusBadRequest
+		return fmt.Errorf("Epoch value for the model must be %d", epoch)
+	}
+	return nil
+}
 
-	if err != nil {
-		info.StatusCode = http.StatusInternalServerError
+// PUT /model?dryrun - apply the new model (and its migrations) as normal
+// and then check all of the existing data against it. Throw away all of the
+// changes and just return the list of model changes and invalid entities.
+func HTTPModelDryRun(info *RequestInfo, model *Model) error {
+	// Need to diff before the current model is replaced
+	model.Registry = info.Registry
+	if err := model.Verify(); err != nil {
+		info.StatusCode = http.StatusBadRequest
 		return err
 	}
+	changes := DiffModels(info.Registry.Model, model)
+
+	err := info.Registry.Model.ApplyNewModel(model)
+	violations := []string{}
+	if err == nil {
+		errs := []error(nil)
+		errs, err = info.Registry.ValidateEntities()
+		for _, vErr := range errs {
+			violations = append(violations, vErr.Error())
+		}
+	}
+
+	// Never keep any of the changes
+	if rbErr := info.tx.Rollback(); rbErr != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return rbErr
+	}
 
-	err = info.Registry.Model.ApplyNewModel(&model)
 	if err != nil {
 		info.StatusCode = http.StatusBadRequest
 		return err
 	}
 
-	return HTTPGETModel(info)
+	buf, err := json.MarshalIndent(map[string]any{
+		"valid":      len(violations) == 0,
+		"changes":    changes,
+		"violations": violations,
+	}, "", "  ")
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	info.StatusCode = http.StatusOK
+	info.AddHeader("Content-Type", "application/json")
+	info.Write(buf)
+	info.Write([]byte("\n"))
+	return nil
 }
 
 // Process the ?setdefaultversionid query parameter
@@ -1638,6 +1866,14 @@ func HTTPDelete(info *RequestInfo) error {
 				return fmt.Errorf(`Epoch value for %q must be %d`, group.UID, e)
 			}
 		}
+		if _, err = info.Registry.GetDeleteRefs(group.Path); err != nil {
+			info.StatusCode = http.StatusConflict
+			return err
+		}
+		if err = group.CheckReadOnlyResources(); err != nil {
+			info.StatusCode = http.StatusMethodNotAllowed
+			return err
+		}
 		if err = group.Delete(); err != nil {
 			info.StatusCode = http.StatusInternalServerError
 			return fmt.Errorf(`Error deleting Group %q: %s`, info.GroupUID, err)
@@ -1654,6 +1890,12 @@ func HTTPDelete(info *RequestInfo) error {
 		return fmt.Errorf(`Resource type %q not found`, info.ResourceType)
 	}
 
+	if rm.ReadOnly {
+		info.StatusCode = http.StatusMethodNotAllowed
+		return fmt.Errorf("Write operations to read-only resources are not " +
+			"allowed")
+	}
+
 	if len(info.Parts) == 3 {
 		// DELETE /GROUPs/gID/RESOURCEs
 		return HTTPDeleteResources(info)
@@ -1678,6 +1920,10 @@ func HTTPDelete(info *RequestInfo) error {
 					resource.UID, e)
 			}
 		}
+		if _, err = info.Registry.GetDeleteRefs(resource.Path); err != nil {
+			info.StatusCode = http.StatusConflict
+			return err
+		}
 		err = resource.Delete()
 
 		if err != nil {
@@ -1807,6 +2053,11 @@ func HTTPDeleteGroups(info *RequestInfo) error {
 			}
 		}
 
+		if err = group.CheckReadOnlyResources(); err != nil {
+			info.StatusCode = http.StatusMethodNotAllowed
+			return err
+		}
+
 		err = group.Delete()
 		if err != nil {
 			info.StatusCode = http.StatusInternalServerError
@@ -3,6 +3,7 @@ package registry
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	log "github.com/duglin/dlog"
@@ -31,6 +32,7 @@ type RequestInfo struct {
 	Filters          [][]*FilterExpr // [OR][AND] filter=e,e(and) &(or) filter=e
 	ShowModel        bool
 	ShowMeta         bool //	was $meta present
+	CheckCompat      bool // ?checkcompat - dry-run compatibility check
 
 	StatusCode int
 	SentStatus bool
@@ -63,6 +65,13 @@ func (info *RequestInfo) AddInline(path string) error {
 		}
 	}
 
+	// xid attributes can be inlined too, which will show the entity
+	// they point to instead of the xid
+	if info.Registry.Model.IsXIDAttribute(pp) {
+		info.Inlines = append(info.Inlines, pp.DB())
+		return nil
+	}
+
 	// Convert back to UI version for the error message
 	path = pp.UI()
 
@@ -110,6 +119,10 @@ type FilterExpr struct {
 	Path     string // endpoints.id  TODO store a PropPath?
 	Value    string // myEndpoint
 	HasEqual bool
+
+	// Match the entities (of type Abstract) that do NOT match the expr
+	Negate   bool
+	Abstract string
 }
 
 func ParseRequest(tx *Tx, w http.ResponseWriter, r *http.Request) (*RequestInfo, error) {
@@ -128,6 +141,7 @@ func ParseRequest(tx *Tx, w http.ResponseWriter, r *http.Request) (*RequestInfo,
 	tx.IgnoreEpoch = r.URL.Query().Has("noepoch")
 	tx.IgnoreStickyDefaultVersion = r.URL.Query().Has("nostickydefaultversion")
 	tx.IgnoreDefaultVersionID = r.URL.Query().Has("nodefaultversionid")
+	tx.VersionBump = r.URL.Query().Get("versionbump")
 
 	if info.Registry != nil && tx.Registry == nil {
 		tx.Registry = info.Registry
@@ -150,6 +164,7 @@ func ParseRequest(tx *Tx, w http.ResponseWriter, r *http.Request) (*RequestInfo,
 	}
 
 	info.HasNested = r.URL.Query().Has("nested")
+	info.CheckCompat = r.URL.Query().Has("checkcompat")
 
 	if r.URL.Query().Has("inline") {
 		// Only pick up inlining values if we're doing a GET, not write ops
@@ -216,7 +231,6 @@ func (info *RequestInfo) ParseFilters() error {
 			if err != nil {
 				return err
 			}
-			path = pp.DB()
 
 			/*
 				if info.What != "Coll" && strings.Index(path, "/") < 0 {
@@ -226,15 +240,8 @@ func (info *RequestInfo) ParseFilters() error {
 				}
 			*/
 
-			if info.Abstract != "" {
-				// Want: path = abs + "," + path in DB format
-				absPP, _ := PropPathFromPath(info.Abstract)
-				absPP = absPP.Append(pp)
-				path = absPP.DB()
-			}
-
 			filter := &FilterExpr{
-				Path:     path,
+				Path:     info.FilterPath(pp),
 				Value:    value,
 				HasEqual: found,
 			}
@@ -252,9 +259,103 @@ func (info *RequestInfo) ParseFilters() error {
 			info.Filters = append(info.Filters, AndFilters)
 		}
 	}
+
+	// ?labels=selector - each one is OR'd like ?filter, and then the lot
+	// of them are AND'd with the filters
+	labelFilters := [][]*FilterExpr{}
+	for _, sel := range info.OriginalRequest.URL.Query()["labels"] {
+		orFilters, err := info.LabelFilters(sel)
+		if err != nil {
+			return err
+		}
+		labelFilters = append(labelFilters, orFilters...)
+	}
+	if len(labelFilters) > 0 {
+		info.AndFilters(labelFilters)
+	}
+
+	if info.OriginalRequest.URL.Query().Has("deprecated") {
+		info.AddDeprecatedFilter()
+	}
 	return nil
 }
 
+// Convert an attribute's PropPath into the DB format used by filters,
+// which includes the abstract path of the entities being queried
+func (info *RequestInfo) FilterPath(pp *PropPath) string {
+	if info.Abstract != "" {
+		// Want: path = abs + "," + path in DB format
+		absPP, _ := PropPathFromPath(info.Abstract)
+		pp = absPP.Append(pp)
+	}
+	return pp.DB()
+}
+
+// ?deprecated is a shortcut for a filter that only matches entities that
+// have a "deprecated" attribute. It's AND'd with any other filters.
+func (info *RequestInfo) AddDeprecatedFilter() {
+	// An empty "deprecated" object is saved as its own prop, otherwise only
+	// its sub-attributes are, so look for any of them
+	exprs := []*FilterExpr{
+		&FilterExpr{Path: info.FilterPath(NewPPP(DeprecatedAttr.Name))},
+	}
+	for _, name := range SortedKeys(DeprecatedAttr.Attributes) {
+		pp := NewPPP(DeprecatedAttr.Name).P(name)
+		exprs = append(exprs, &FilterExpr{Path: info.FilterPath(pp)})
+	}
+
+	filters := [][]*FilterExpr{}
+	for _, expr := range exprs {
+		filters = append(filters, []*FilterExpr{expr})
+	}
+	info.AndFilters(filters)
+}
+
+// AND "filters" (a list of OR'd groups) with the existing filters
+func (info *RequestInfo) AndFilters(filters [][]*FilterExpr) {
+	orFilters := info.Filters
+	if len(orFilters) == 0 {
+		orFilters = [][]*FilterExpr{[]*FilterExpr{}}
+	}
+
+	info.Filters = [][]*FilterExpr{}
+	for _, andFilters := range orFilters {
+		for _, exprs := range filters {
+			info.Filters = append(info.Filters,
+				append(slices.Clone(andFilters), exprs...))
+		}
+	}
+}
+
+// Returns the ID of the Version that "tag" points to in the Resource
+// referenced by the request
+func (info *RequestInfo) ResolveVersionTag(tag string) (string, error) {
+	group, err := info.Registry.FindGroup(info.GroupType, info.GroupUID, false)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return "", err
+	}
+	resource := (*Resource)(nil)
+	if group != nil {
+		resource, err = group.FindResource(info.ResourceType,
+			info.ResourceUID, false)
+		if err != nil {
+			info.StatusCode = http.StatusInternalServerError
+			return "", err
+		}
+	}
+
+	vID := ""
+	if resource != nil {
+		vID = resource.GetVersionTag(tag)
+	}
+	if vID == "" {
+		info.StatusCode = http.StatusNotFound
+		return "", fmt.Errorf("Version tag %q not found", tag)
+	}
+	return vID, nil
+}
+
 func (info *RequestInfo) ParseRequestURL() error {
 	path := strings.Trim(info.OriginalPath, " /")
 	info.Parts = strings.Split(path, "/")
@@ -291,6 +392,20 @@ func (info *RequestInfo) ParseRequestURL() error {
 		return nil
 	}
 
+	// /retention is a read-only report of what the sweeper would delete
+	if len(info.Parts) > 0 && info.Parts[0] == "retention" {
+		if len(info.Parts) > 1 {
+			info.StatusCode = http.StatusNotFound
+			return fmt.Errorf("Not found")
+		}
+		if info.OriginalRequest.Method != "GET" {
+			info.StatusCode = http.StatusMethodNotAllowed
+			return fmt.Errorf("%s not allowed on /retention",
+				info.OriginalRequest.Method)
+		}
+		return nil
+	}
+
 	// /GROUPs
 	if strings.HasSuffix(info.Parts[0], "$meta") {
 		info.StatusCode = http.StatusBadRequest
@@ -413,6 +528,19 @@ func (info *RequestInfo) ParseRequestURL() error {
 			return fmt.Errorf("Version id in URL can't be blank")
 		}
 
+		// versions/@TAG is an alias for the Version that TAG points to
+		if strings.HasPrefix(info.VersionUID, "@") {
+			vID, err := info.ResolveVersionTag(info.VersionUID[1:])
+			if err != nil {
+				return err
+			}
+			info.Root = strings.TrimSuffix(info.Root, info.Parts[5]) + vID
+			if info.ShowMeta {
+				info.Root += "$meta"
+			}
+			info.VersionUID = vID
+		}
+
 		info.Parts[5] = info.VersionUID
 		info.What = "Entity"
 		return nil
@@ -0,0 +1,356 @@
+-- The SQLite version of init.sql, used when DBDIR is set. The tables,
+-- triggers and views need to stay in sync with that file, TestSQLiteSchema
+-- checks that they do.
+-- Columns that MySQL compares case-insensitively (its default collation)
+-- are NOCASE here, Path and Abstract (utf8mb4_bin there) are BINARY.
+-- NOCASE only folds ASCII case, and unlike MySQL it doesn't ignore accents.
+
+PRAGMA journal_mode = WAL ;
+
+CREATE TABLE Registries (
+    SID     VARCHAR(255) NOT NULL,              -- System ID
+    UID     VARCHAR(255) NOT NULL COLLATE NOCASE, -- User defined
+    Attributes  JSON,               -- Until we use the Attributes table
+
+    PRIMARY KEY (SID),
+    UNIQUE (UID)
+);
+
+CREATE TRIGGER RegistryTrigger BEFORE DELETE ON Registries
+FOR EACH ROW
+BEGIN
+    DELETE FROM Props    WHERE EntitySID=OLD.SID @
+    DELETE FROM "Groups" WHERE RegistrySID=OLD.SID @
+    DELETE FROM Models   WHERE RegistrySID=OLD.SID @
+END ;
+
+CREATE TABLE Models (
+    RegistrySID VARCHAR(64) NOT NULL,
+
+    PRIMARY KEY (RegistrySID)
+);
+
+CREATE TRIGGER ModelsTrigger BEFORE DELETE ON Models
+FOR EACH ROW
+BEGIN
+    DELETE FROM ModelEntities  WHERE RegistrySID=OLD.RegistrySID @
+    DELETE FROM "Schemas"      WHERE RegistrySID=OLD.RegistrySID @
+    DELETE FROM ModelRevisions WHERE RegistrySID=OLD.RegistrySID @
+END ;
+
+CREATE TABLE ModelRevisions (       -- Immutable history of the model
+    RegistrySID  VARCHAR(64) NOT NULL,
+    Revision     INT NOT NULL,      -- The model's epoch
+    CreatedBy    VARCHAR(255),      -- Tx.User
+    CreatedAt    VARCHAR(255),
+    Model        JSON,
+
+    PRIMARY KEY(RegistrySID, Revision)
+);
+
+CREATE TABLE "Schemas" (
+    RegistrySID  VARCHAR(64) NOT NULL,
+    "Schema"     VARCHAR(255) NOT NULL COLLATE NOCASE,
+
+    PRIMARY KEY(RegistrySID, "Schema")
+);
+
+CREATE INDEX SchemasReg ON "Schemas" (RegistrySID) ;
+
+CREATE TABLE ModelEntities (        -- Group or Resource (no parent=Group)
+    SID               VARCHAR(64),        -- my System ID
+    RegistrySID       VARCHAR(64),
+    ParentSID         VARCHAR(64),        -- ID of parent ModelEntity
+
+    Singular          VARCHAR(64) COLLATE NOCASE,
+    Plural            VARCHAR(64) COLLATE NOCASE,
+    Attributes        JSON,               -- Until we use the Attributes table
+
+    MaxVersions       INT,      -- For Resources
+    SetVersionId      BOOL,     -- For Resources
+    SetStickyDefault  BOOL,     -- For Resources
+    HasDocument       BOOL,     -- For Resources
+    ReadOnly          BOOL,     -- For Resources
+    TypeMap           JSON,
+    Compatibility     VARCHAR(64),   -- For Resources
+    VersionIdStrategy VARCHAR(64),   -- For Resources
+    Retention         JSON,          -- For Resources
+    DeprecatedBlocksVersions BOOL,   -- For Resources
+
+    PRIMARY KEY(SID),
+    UNIQUE (RegistrySID, ParentSID, Plural),
+    CONSTRAINT UC_Singular UNIQUE (RegistrySID, ParentSID, Singular)
+);
+
+CREATE TRIGGER ModelTrigger BEFORE DELETE ON ModelEntities
+FOR EACH ROW
+BEGIN
+    DELETE FROM "Groups"        WHERE ModelSID=OLD.SID @
+    DELETE FROM Resources       WHERE ModelSID=OLD.SID @
+    DELETE FROM ModelAttributes WHERE ParentSID=OLD.SID @
+END ;
+
+-- Not used yet
+CREATE TABLE ModelAttributes (
+    SID           VARCHAR(64) NOT NULL,   -- my System ID
+    RegistrySID   VARCHAR(64) NOT NULL,
+    ParentSID     VARCHAR(64),            -- NULL=Root. Model or IfValue SID
+    Name          VARCHAR(64) NOT NULL COLLATE NOCASE,
+    Type          VARCHAR(64) NOT NULL,
+    Description   VARCHAR(255),
+    Strict        BOOL NOT NULL,
+    Required      BOOL NOT NULL,
+    ItemType      VARCHAR(64),
+
+    PRIMARY KEY(RegistrySID, ParentSID, SID),
+    UNIQUE (SID),
+    CONSTRAINT UC_Name UNIQUE (RegistrySID, ParentSID, Name)
+);
+
+CREATE TRIGGER ModelAttributeTrigger BEFORE DELETE ON ModelAttributes
+FOR EACH ROW
+BEGIN
+    DELETE FROM ModelEnums    WHERE AttributeSID=OLD.SID @
+    DELETE FROM ModelIfValues WHERE AttributeSID=OLD.SID @
+END ;
+
+CREATE TABLE ModelEnums (
+    RegistrySID   VARCHAR(64) NOT NULL,
+    AttributeSID  VARCHAR(64) NOT NULL,
+    Value         VARCHAR(255) NOT NULL COLLATE NOCASE,
+
+    PRIMARY KEY(RegistrySID, AttributeSID),
+    CONSTRAINT UC_Value UNIQUE (RegistrySID, AttributeSID, Value)
+);
+
+CREATE INDEX ModelEnumsAttr ON ModelEnums (AttributeSID) ;
+
+CREATE TABLE ModelIfValues (
+    SID           VARCHAR(64) NOT NULL,
+    RegistrySID   VARCHAR(64) NOT NULL,
+    AttributeSID  VARCHAR(64) NOT NULL,
+    Value         VARCHAR(255) NOT NULL COLLATE NOCASE,
+
+    PRIMARY KEY(RegistrySID, AttributeSID),
+    UNIQUE (SID),
+    CONSTRAINT UC_Value UNIQUE (RegistrySID, AttributeSID, Value)
+);
+
+CREATE INDEX ModelIfValuesAttr ON ModelIfValues (AttributeSID) ;
+
+CREATE TRIGGER ModelIfValuesTrigger BEFORE DELETE ON ModelIfValues
+FOR EACH ROW
+BEGIN
+    DELETE FROM ModelAttributes    WHERE ParentSID=OLD.SID @
+END ;
+
+
+CREATE TABLE "Groups" (
+    SID             VARCHAR(64) NOT NULL,   -- System ID
+    UID             VARCHAR(64) NOT NULL COLLATE NOCASE, -- User defined
+    RegistrySID     VARCHAR(64) NOT NULL,
+    ModelSID        VARCHAR(64) NOT NULL,
+    Path            VARCHAR(255) NOT NULL COLLATE BINARY,
+    Abstract        VARCHAR(255) NOT NULL COLLATE BINARY,
+
+    PRIMARY KEY (SID),
+    UNIQUE (RegistrySID, ModelSID, UID)
+);
+
+CREATE INDEX GroupsUID ON "Groups" (RegistrySID, UID) ;
+
+CREATE TRIGGER GroupTrigger BEFORE DELETE ON "Groups"
+FOR EACH ROW
+BEGIN
+    DELETE FROM Props WHERE EntitySID=OLD.SID @
+    DELETE FROM Resources WHERE GroupSID=OLD.SID @
+END ;
+
+CREATE TABLE Resources (
+    SID             VARCHAR(64) NOT NULL,   -- System ID
+    UID             VARCHAR(64) NOT NULL COLLATE NOCASE, -- User defined
+    GroupSID        VARCHAR(64) NOT NULL,   -- System ID
+    ModelSID        VARCHAR(64) NOT NULL,
+    Path            VARCHAR(255) NOT NULL COLLATE BINARY,
+    Abstract        VARCHAR(255) NOT NULL COLLATE BINARY,
+
+    PRIMARY KEY (SID),
+    UNIQUE (GroupSID, ModelSID, UID)
+);
+
+CREATE INDEX ResourcesUID ON Resources (GroupSID, UID) ;
+
+CREATE TRIGGER ResourcesTrigger BEFORE DELETE ON Resources
+FOR EACH ROW
+BEGIN
+    DELETE FROM Props WHERE EntitySID=OLD.SID @
+    DELETE FROM Versions WHERE ResourceSID=OLD.SID @
+    DELETE FROM VersionTagMoves WHERE ResourceSID=OLD.SID @
+END ;
+
+CREATE TABLE VersionTagMoves (      -- History of the Resources' version tags
+    Counter      INTEGER PRIMARY KEY AUTOINCREMENT,
+    ResourceSID  VARCHAR(64) NOT NULL,
+    Tag          VARCHAR(64) NOT NULL,
+    FromVersion  VARCHAR(255),      -- NULL if the tag is new
+    ToVersion    VARCHAR(255),      -- NULL if the tag was removed
+    Epoch        INT,               -- The Resource's epoch after the move
+    MovedBy      VARCHAR(255),      -- Tx.User
+    MovedAt      VARCHAR(255)
+);
+
+CREATE INDEX VersionTagMovesRes ON VersionTagMoves (ResourceSID) ;
+
+CREATE TABLE Versions (
+    SID                 VARCHAR(64) NOT NULL,   -- System ID
+    UID                 VARCHAR(64) NOT NULL COLLATE NOCASE, -- User defined
+    ResourceSID         VARCHAR(64) NOT NULL,   -- System ID
+    Path                VARCHAR(255) NOT NULL COLLATE BINARY,
+    Abstract            VARCHAR(255) NOT NULL COLLATE BINARY,
+    Counter             INTEGER,                -- Set by VersionsCounter
+
+    ResourceURL         VARCHAR(255),
+    ResourceProxyURL    VARCHAR(255),
+    ResourceContentSID  VARCHAR(64),
+
+    PRIMARY KEY (SID),
+    UNIQUE (ResourceSID, UID)
+);
+
+-- SQLite only auto-increments the primary key so do it ourselves
+CREATE TRIGGER VersionsCounter AFTER INSERT ON Versions
+FOR EACH ROW
+BEGIN
+    UPDATE Versions SET Counter=(SELECT IFNULL(MAX(Counter),0)+1 FROM Versions)
+    WHERE SID=NEW.SID @
+END ;
+
+CREATE TRIGGER VersionsTrigger BEFORE DELETE ON Versions
+FOR EACH ROW
+BEGIN
+    DELETE FROM Props WHERE EntitySID=OLD.SID @
+    DELETE FROM ResourceContents WHERE VersionSID=OLD.SID @
+END ;
+
+CREATE TABLE Props (
+    RegistrySID VARCHAR(64) NOT NULL,
+    EntitySID   VARCHAR(64) NOT NULL,       -- Reg,Group,Res,Ver System ID
+    PropName    VARCHAR(64) NOT NULL COLLATE NOCASE,
+    PropValue   VARCHAR(255) COLLATE NOCASE,
+    PropType    CHAR(64) NOT NULL,          -- string, boolean, int, ...
+
+    PRIMARY KEY (EntitySID, PropName)
+);
+
+CREATE INDEX PropsEntity ON Props (EntitySID) ;
+
+CREATE TABLE ResourceContents (
+    VersionSID      VARCHAR(255),
+    Content         BLOB,
+
+    PRIMARY KEY (VersionSID)
+);
+
+CREATE VIEW DefaultProps AS
+SELECT
+    p.RegistrySID,
+    r.SID AS EntitySID,
+    p.PropName,
+    p.PropValue,
+    p.PropType
+FROM Props AS p
+JOIN Versions AS v ON (p.EntitySID=v.SID)
+JOIN Resources AS r ON (r.SID=v.ResourceSID)
+JOIN Props AS p1 ON (p1.EntitySID=r.SID)
+WHERE p1.PropName='defaultVersionId,' AND v.UID=p1.PropValue AND
+      p.PropName<>'id,' AND     -- Don't overwrite this
+      p.PropName NOT LIKE 'deprecated,%' ; -- Resource has its own
+-- NOTE!!! if DB_IN changes then the above 3 lines MUST change
+
+CREATE VIEW Entities AS
+SELECT                          -- Gather Registries
+    r.SID AS RegSID,
+    0 AS Level,
+    'registries' AS Plural,
+    NULL AS ParentSID,
+    r.SID AS eSID,
+    r.UID AS UID,
+    '' AS Abstract,
+    '' AS Path
+FROM Registries AS r
+
+UNION SELECT                    -- Gather Groups
+    g.RegistrySID AS RegSID,
+    1 AS Level,
+    m.Plural AS Plural,
+    g.RegistrySID AS ParentSID,
+    g.SID AS eSID,
+    g.UID AS UID,
+    g.Abstract,
+    g.Path
+FROM "Groups" AS g
+JOIN ModelEntities AS m ON (m.SID=g.ModelSID)
+
+UNION SELECT                    -- Add Resources
+    m.RegistrySID AS RegSID,
+    2 AS Level,
+    m.Plural AS Plural,
+    r.GroupSID AS ParentSID,
+    r.SID AS eSID,
+    r.UID AS UID,
+    r.Abstract,
+    r.Path
+FROM Resources AS r
+JOIN ModelEntities AS m ON (m.SID=r.ModelSID)
+
+UNION SELECT                    -- Add Versions
+    rm.RegistrySID AS RegSID,
+    3 AS Level,
+    'versions' AS Plural,
+    r.SID AS ParentSID,
+    v.SID AS eSID,
+    v.UID AS UID,
+    v.Abstract,
+    v.Path
+FROM Versions AS v
+JOIN Resources AS r ON (r.SID=v.ResourceSID)
+JOIN ModelEntities AS rm ON (rm.SID=r.ModelSID) ;
+
+CREATE VIEW AllProps AS
+SELECT * FROM Props
+UNION SELECT * FROM DefaultProps
+UNION SELECT                    -- Add in "isdefault", which is calculated
+  v.RegSID,
+  v.eSID,
+  'isdefault,',
+  'true',
+  'boolean'
+FROM Entities AS v
+JOIN Props AS p ON (
+  p.EntitySID=v.ParentSID AND
+  p.PropName='defaultversionid,'
+  AND p.PropValue=v.UID );
+
+
+CREATE VIEW FullTree AS
+SELECT
+    RegSID,
+    Level,
+    Plural,
+    ParentSID,
+    eSID,
+    UID,
+    Path,
+    PropName,
+    PropValue,
+    PropType,
+    Abstract
+FROM Entities
+LEFT JOIN AllProps ON (AllProps.EntitySID=Entities.eSID)
+ORDER by Path, PropName;
+
+CREATE VIEW Leaves AS
+SELECT eSID FROM Entities
+WHERE eSID NOT IN (
+    SELECT DISTINCT ParentSID FROM Entities WHERE ParentSID IS NOT NULL
+);
@@ -3,6 +3,8 @@
 -- USE registry ;
 -- ^^ OLD STUF
 
+-- init-sqlite.sql has the SQLite version of this, keep the two in sync.
+
 -- MySQL config requirements:
 -- sql_mode:
 --   ANSI_QUOTES        <- enabled
@@ -58,10 +60,21 @@ CREATE TABLE Models (
 CREATE TRIGGER ModelsTrigger BEFORE DELETE ON Models
 FOR EACH ROW
 BEGIN
-    DELETE FROM ModelEntities WHERE RegistrySID=OLD.RegistrySID @
-    DELETE FROM "Schemas"     WHERE RegistrySID=OLD.RegistrySID @
+    DELETE FROM ModelEntities  WHERE RegistrySID=OLD.RegistrySID @
+    DELETE FROM "Schemas"      WHERE RegistrySID=OLD.RegistrySID @
+    DELETE FROM ModelRevisions WHERE RegistrySID=OLD.RegistrySID @
 END ;
 
+CREATE TABLE ModelRevisions (       # Immutable history of the model
+    RegistrySID  VARCHAR(64) NOT NULL,
+    Revision     INT NOT NULL,      # The model's epoch
+    CreatedBy    VARCHAR(255),      # Tx.User
+    CreatedAt    VARCHAR(255),
+    Model        JSON,
+
+    PRIMARY KEY(RegistrySID, Revision)
+);
+
 CREATE TABLE "Schemas" (
     RegistrySID  VARCHAR(64) NOT NULL,
     "Schema"     VARCHAR(255) NOT NULL,
@@ -85,6 +98,10 @@ CREATE TABLE ModelEntities (        # Group or Resource (no parent=Group)
     HasDocument       BOOL,     # For Resources
     ReadOnly          BOOL,     # For Resources
     TypeMap           JSON,
+    Compatibility     VARCHAR(64),   # For Resources
+    VersionIdStrategy VARCHAR(64),   # For Resources
+    Retention         JSON,          # For Resources
+    DeprecatedBlocksVersions BOOL,   # For Resources
 
     PRIMARY KEY(SID),
     UNIQUE INDEX (RegistrySID, ParentSID, Plural),
@@ -190,8 +207,23 @@ FOR EACH ROW
 BEGIN
     DELETE FROM Props WHERE EntitySID=OLD.SID @
     DELETE FROM Versions WHERE ResourceSID=OLD.SID @
+    DELETE FROM VersionTagMoves WHERE ResourceSID=OLD.SID @
 END ;
 
+CREATE TABLE VersionTagMoves (      # History of the Resources' version tags
+    Counter      SERIAL,            # Counter, auto-increments
+    ResourceSID  VARCHAR(64) NOT NULL,
+    Tag          VARCHAR(64) NOT NULL,
+    FromVersion  VARCHAR(255),      # NULL if the tag is new
+    ToVersion    VARCHAR(255),      # NULL if the tag was removed
+    Epoch        INT,               # The Resource's epoch after the move
+    MovedBy      VARCHAR(255),      # Tx.User
+    MovedAt      VARCHAR(255),
+
+    PRIMARY KEY (Counter),
+    INDEX (ResourceSID)
+);
+
 CREATE TABLE Versions (
     SID                 VARCHAR(64) NOT NULL,   # System ID
     UID                 VARCHAR(64) NOT NULL,   # User defined
@@ -245,8 +277,9 @@ JOIN Versions AS v ON (p.EntitySID=v.SID)
 JOIN Resources AS r ON (r.SID=v.ResourceSID)
 JOIN Props AS p1 ON (p1.EntitySID=r.SID)
 WHERE p1.PropName='defaultVersionId,' AND v.UID=p1.PropValue AND
-      p.PropName<>'id,' ;     # Don't overwrite this
-# NOTE!!! if DB_IN changes then the above 2 lines MUST change
+      p.PropName<>'id,' AND     # Don't overwrite this
+      p.PropName NOT LIKE 'deprecated,%' ; # Resource has its own
+# NOTE!!! if DB_IN changes then the above 3 lines MUST change
 # TODO move the creation of this into the code then we can dynamically
 # use DB_IN instead of hard-coding the "," in here
 
@@ -174,6 +174,17 @@ func (jw *JsonWriter) WriteEntity() error {
 		if key[0] == '#' {
 			return nil
 		}
+		if attr != nil && attr.Type == XID {
+			p := NewPPP(key)
+			if e.Abstract != "" {
+				p = MustPropPathFromDB(e.Abstract).P(key)
+			}
+			if jw.info.IsInlineSet(p.DB()) {
+				jw.Printf("%s\n%s%q: ", extra, jw.indent, key)
+				extra = ","
+				return jw.WriteXIDEntity(fmt.Sprintf("%v", val))
+			}
+		}
 		buf, _ := json.MarshalIndent(val, jw.indent, "  ")
 		jw.Printf("%s\n%s%q: %s", extra, jw.indent, key, string(buf))
 		extra = ","
@@ -288,6 +299,40 @@ func (jw *JsonWriter) WriteEntity() error {
 	return nil
 }
 
+// Write the entity that "xid" points to, in place of the xid itself. Just
+// its attributes are included, not its nested collections.
+func (jw *JsonWriter) WriteXIDEntity(xid string) error {
+	e, err := EntityFromXID(jw.info.tx, jw.info.Registry, xid)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		// Shouldn't happen, but if it's dangling just show the xid
+		jw.Printf("%q", xid)
+		return nil
+	}
+
+	jw.Printf("{")
+	jw.Indent()
+
+	extra := ""
+	err = e.SerializeProps(jw.info, func(e *Entity, info *RequestInfo,
+		key string, val any, attr *Attribute) error {
+
+		if key[0] == '#' {
+			return nil
+		}
+		buf, _ := json.MarshalIndent(val, jw.indent, "  ")
+		jw.Printf("%s\n%s%q: %s", extra, jw.indent, key, string(buf))
+		extra = ","
+		return nil
+	})
+
+	jw.Outdent()
+	jw.Printf("\n%s}", jw.indent)
+	return err
+}
+
 func (jw *JsonWriter) LoadCollections(level int) {
 	names := []string{}
 	if level == 0 {
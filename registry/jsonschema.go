@@ -0,0 +1,312 @@
+package registry
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+)
+
+// The model serializer for the "jsonschema" format. It generates a JSON
+// Schema (draft 2020-12) document with one definition per level (see
+// ModelSchemas).
+func Model2JSONSchema(m *Model, format string) ([]byte, error) {
+	schema := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$ref":    "#/$defs/registry",
+		"$defs":   ModelSchemas(m, "#/$defs/"),
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// Returns the schemas of each level of the model, keyed by:
+//
+//	registry                    - the Registry itself
+//	group-GROUPS                - each Group type
+//	resource-GROUPS-RESOURCES   - each Resource type
+//	version-GROUPS-RESOURCES    - the Versions of each Resource type
+//
+// "-" can't appear in a Group or Resource name so the names are unique, and
+// it's allowed in OpenAPI component names (":" isn't) so the OpenAPI
+// serializer uses the same keys. Treat these names as fixed since users of
+// the generated schemas will $ref them.
+// References between them are prefixed with "refPrefix".
+// Only "clientrequired" attributes are listed as "required" since the
+// "serverrequired" ones are filled in by the server.
+func ModelSchemas(m *Model, refPrefix string) map[string]any {
+	defs := map[string]any{}
+
+	regSchema := ObjectSchema(LevelAttributes(m.Attributes, 0))
+	for _, gmName := range SortedKeys(m.Groups) {
+		gm := m.Groups[gmName]
+		gmDef := GroupSchemaName(gm)
+		AddCollectionSchema(regSchema, gm.Plural, refPrefix+gmDef)
+
+		gmSchema := ObjectSchema(LevelAttributes(gm.GetBaseAttributes(), 1))
+		for _, rmName := range SortedKeys(gm.Resources) {
+			rm := gm.Resources[rmName]
+			rmDef := ResourceSchemaName(gm, rm)
+			vDef := VersionSchemaName(gm, rm)
+			AddCollectionSchema(gmSchema, rm.Plural, refPrefix+rmDef)
+
+			defs[vDef] = ObjectSchema(rm.GetVersionAttributes())
+
+			rmSchema := ObjectSchema(rm.GetResourceAttributes())
+			AddCollectionSchema(rmSchema, "versions", refPrefix+vDef)
+			defs[rmDef] = rmSchema
+		}
+		defs[gmDef] = gmSchema
+	}
+	defs["registry"] = regSchema
+
+	return defs
+}
+
+func GroupSchemaName(gm *GroupModel) string {
+	return "group-" + gm.Plural
+}
+
+func ResourceSchemaName(gm *GroupModel, rm *ResourceModel) string {
+	return "resource-" + gm.Plural + "-" + rm.Plural
+}
+
+func VersionSchemaName(gm *GroupModel, rm *ResourceModel) string {
+	return "version-" + gm.Plural + "-" + rm.Plural
+}
+
+// Adds the PLURAL, PLURALurl and PLURALcount attributes of a collection to
+// the schema of its parent
+func AddCollectionSchema(schema map[string]any, plural string, ref string) {
+	props := schema["properties"].(map[string]any)
+	props[plural] = map[string]any{
+		"type":                 "object",
+		"additionalProperties": map[string]any{"$ref": ref},
+	}
+	props[plural+"url"] = map[string]any{"type": "string", "format": "uri"}
+	props[plural+"count"] = map[string]any{"type": "integer", "minimum": 0}
+}
+
+// Returns the attributes of an entity that appears at "levels". The spec
+// defined attributes for those levels are added if the model doesn't
+// already have them (e.g. it hasn't been verified yet), and any that don't
+// belong are skipped.
+func LevelAttributes(attrs Attributes, levels ...int) Attributes {
+	inLevels := func(attr *Attribute) bool {
+		return slices.ContainsFunc(levels, attr.InLevel)
+	}
+
+	levelAttrs := Attributes{}
+	for name, attr := range attrs {
+		if specProp := SpecProps[name]; specProp != nil && !inLevels(specProp) {
+			continue
+		}
+		levelAttrs[name] = attr
+	}
+	for _, specProp := range OrderedSpecProps {
+		if inLevels(specProp) && levelAttrs[specProp.Name] == nil {
+			levelAttrs[specProp.Name] = specProp
+		}
+	}
+
+	return levelAttrs
+}
+
+// Returns the attributes of the Versions of "rm" as they appear in their
+// serialization
+func (rm *ResourceModel) GetVersionAttributes() Attributes {
+	attrs := rm.GetBaseAttributes()
+	delete(attrs, rm.Singular+"proxyurl") // never shown to users
+	attrs[DeprecatedAttr.Name] = DeprecatedAttr
+
+	return LevelAttributes(attrs, 3)
+}
+
+// Returns the attributes of the Resources of "rm" as they appear in their
+// serialization. This includes the attributes of their default Version.
+func (rm *ResourceModel) GetResourceAttributes() Attributes {
+	attrs := rm.GetBaseAttributes()
+	delete(attrs, rm.Singular+"proxyurl") // never shown to users
+	attrs[DeprecatedAttr.Name] = DeprecatedAttr
+	attrs[VersionTagsAttr.Name] = VersionTagsAttr
+
+	return LevelAttributes(attrs, 2, 3)
+}
+
+func ObjectSchema(attrs Attributes) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	allOf := []any{}
+	schema := map[string]any{
+		"type":       "object",
+		"properties": props,
+	}
+
+	for _, name := range SortedKeys(attrs) {
+		attr := attrs[name]
+		if name == "*" {
+			continue
+		}
+
+		props[name] = AttributeSchema(attr)
+		if attr.ClientRequired {
+			required = append(required, name)
+		}
+
+		for _, ifValue := range SortedKeys(attr.IfValues) {
+			siblings := ObjectSchema(attr.IfValues[ifValue].SiblingAttributes)
+			delete(siblings, "type")
+			delete(siblings, "unevaluatedProperties")
+			allOf = append(allOf, map[string]any{
+				"if": map[string]any{
+					"properties": map[string]any{
+						name: map[string]any{
+							"const": IfValueToConst(ifValue, attr.Type),
+						},
+					},
+					"required": []string{name},
+				},
+				"then": siblings,
+			})
+		}
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if len(allOf) > 0 {
+		schema["allOf"] = allOf
+	}
+
+	// "unevaluated" (instead of "additional") so that the "ifvalues"
+	// sibling attributes are allowed
+	if attr := attrs["*"]; attr != nil {
+		schema["unevaluatedProperties"] = AttributeSchema(attr)
+	} else {
+		schema["unevaluatedProperties"] = false
+	}
+
+	return schema
+}
+
+// The "ifvalues" keys are strings, so convert them back to the type of
+// the attribute so that "const" will match
+func IfValueToConst(val string, daType string) any {
+	switch daType {
+	case BOOLEAN:
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	case DECIMAL, INTEGER, UINTEGER:
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return val
+}
+
+func ItemSchema(item *Item) map[string]any {
+	if item == nil {
+		return map[string]any{}
+	}
+	return AttributeSchema(&Attribute{
+		Type:       item.Type,
+		Item:       item.Item,
+		Attributes: item.Attributes,
+	})
+}
+
+func AttributeSchema(attr *Attribute) map[string]any {
+	schema := map[string]any{}
+
+	switch attr.Type {
+	case ANY, "":
+		// No restrictions
+	case ARRAY:
+		schema["type"] = "array"
+		schema["items"] = ItemSchema(attr.Item)
+	case BOOLEAN:
+		schema["type"] = "boolean"
+	case DECIMAL:
+		schema["type"] = "number"
+	case INTEGER:
+		schema["type"] = "integer"
+	case UINTEGER:
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case MAP:
+		schema["type"] = "object"
+		schema["propertyNames"] = map[string]any{
+			"pattern": RegexpMapKey.String(),
+		}
+		schema["additionalProperties"] = ItemSchema(attr.Item)
+	case OBJECT:
+		schema = ObjectSchema(attr.Attributes)
+	case STRING:
+		schema["type"] = "string"
+	case TIMESTAMP:
+		schema["type"] = "string"
+		schema["format"] = "date-time"
+	case URI, URL:
+		schema["type"] = "string"
+		schema["format"] = "uri"
+	case URI_REFERENCE, XID:
+		schema["type"] = "string"
+		schema["format"] = "uri-reference"
+	case URI_TEMPLATE:
+		schema["type"] = "string"
+		schema["format"] = "uri-template"
+	}
+
+	if attr.Description != "" {
+		schema["description"] = attr.Description
+	}
+	if attr.ReadOnly {
+		schema["readOnly"] = true
+	}
+	if !IsNil(attr.Default) {
+		schema["default"] = attr.Default
+	}
+
+	// A non-strict enum is just a list of suggestions
+	if len(attr.Enum) > 0 {
+		if attr.GetStrict() {
+			schema["enum"] = slices.Clone(attr.Enum)
+		} else {
+			schema["examples"] = slices.Clone(attr.Enum)
+		}
+	}
+
+	if attr.Pattern != "" {
+		schema["pattern"] = attr.Pattern
+	}
+	if attr.MinLength != nil {
+		schema["minLength"] = *attr.MinLength
+	}
+	if attr.MaxLength != nil {
+		schema["maxLength"] = *attr.MaxLength
+	}
+	if attr.Minimum != nil {
+		schema["minimum"] = *attr.Minimum
+	}
+	if attr.Maximum != nil {
+		schema["maximum"] = *attr.Maximum
+	}
+	if attr.Format != "" {
+		schema["format"] = attr.Format
+	}
+
+	if attr.MinItems != nil || attr.MaxItems != nil {
+		minName, maxName := "minItems", "maxItems"
+		if attr.Type == MAP {
+			minName, maxName = "minProperties", "maxProperties"
+		}
+		if attr.MinItems != nil {
+			schema[minName] = *attr.MinItems
+		}
+		if attr.MaxItems != nil {
+			schema[maxName] = *attr.MaxItems
+		}
+	}
+
+	return schema
+}
@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestModel2JSONSchema(t *testing.T) {
+	m := &Model{
+		Attributes: Attributes{
+			"owner": {Name: "owner", Type: STRING, ClientRequired: true,
+				Pattern: "^[a-z]+$"},
+			"tags": {Name: "tags", Type: ARRAY, MaxItems: PtrInt(3),
+				Item: &Item{Type: STRING}},
+		},
+		Groups: map[string]*GroupModel{
+			"dirs": {Plural: "dirs", Singular: "dir",
+				Attributes: Attributes{
+					"*": {Name: "*", Type: INTEGER},
+				},
+				Resources: map[string]*ResourceModel{
+					"files": {Plural: "files", Singular: "file",
+						HasDocument: PtrBool(true),
+						Attributes: Attributes{
+							"format": {Name: "format", Type: STRING,
+								Enum: []any{"json", "xml"},
+								IfValues: IfValues{
+									"json": {SiblingAttributes: Attributes{
+										"indent": {Name: "indent",
+											Type:           UINTEGER,
+											ClientRequired: true},
+									}},
+								}},
+							"size": {Name: "size", Type: INTEGER,
+								Enum: []any{1, 2}, Strict: PtrBool(false)},
+						}},
+				}},
+		},
+	}
+
+	buf, err := Model2JSONSchema(m, "")
+	if err != nil {
+		t.Fatalf("Model2JSONSchema: %s", err)
+	}
+
+	schema := map[string]any{}
+	if err := json.Unmarshal(buf, &schema); err != nil {
+		t.Fatalf("Unmarshal: %s\n%s", err, buf)
+	}
+
+	type Test struct {
+		path string // "."-separated path into "schema"
+		exp  string // JSON of the expected value, "" means it's not there
+	}
+
+	// The $defs keys are part of the output's API, see ModelSchemas
+	keys := SortedKeys(schema["$defs"].(map[string]any))
+	if got := strings.Join(keys, ","); got != "group-dirs,registry,"+
+		"resource-dirs-files,version-dirs-files" {
+		t.Fatalf("Bad $defs keys: %s", got)
+	}
+
+	tests := []Test{
+		{"$ref", `"#/$defs/registry"`},
+
+		// Registry
+		{"$defs.registry.properties.specversion.readOnly", `true`},
+		{"$defs.registry.properties.owner", `{"pattern":"^[a-z]+$","type":"string"}`},
+		{"$defs.registry.properties.tags", `{"items":{"type":"string"},"maxItems":3,"type":"array"}`},
+		{"$defs.registry.required", `["owner"]`},
+		{"$defs.registry.unevaluatedProperties", `false`},
+		{"$defs.registry.properties.dirs.additionalProperties", `{"$ref":"#/$defs/group-dirs"}`},
+		{"$defs.registry.properties.dirscount", `{"minimum":0,"type":"integer"}`},
+		{"$defs.registry.properties.dirsurl", `{"format":"uri","type":"string"}`},
+		{"$defs.registry.properties.defaultversionid", ``},
+
+		// Group
+		{"$defs.group-dirs.properties.specversion", ``},
+		{"$defs.group-dirs.properties.epoch", `{"minimum":0,"type":"integer"}`},
+		{"$defs.group-dirs.unevaluatedProperties", `{"type":"integer"}`},
+		{"$defs.group-dirs.properties.files.additionalProperties", `{"$ref":"#/$defs/resource-dirs-files"}`},
+
+		// Resource
+		{"$defs.resource-dirs-files.properties.defaultversionid.readOnly", `true`},
+		{"$defs.resource-dirs-files.properties.epoch.type", `"integer"`},
+		{"$defs.resource-dirs-files.properties.versiontags.type", `"object"`},
+		{"$defs.resource-dirs-files.properties.deprecated.type", `"object"`},
+		{"$defs.resource-dirs-files.properties.file", `{}`},
+		{"$defs.resource-dirs-files.properties.fileurl.format", `"uri"`},
+		{"$defs.resource-dirs-files.properties.fileproxyurl", ``},
+		{"$defs.resource-dirs-files.properties.versions.additionalProperties", `{"$ref":"#/$defs/version-dirs-files"}`},
+		{"$defs.resource-dirs-files.properties.format.enum", `["json","xml"]`},
+		{"$defs.resource-dirs-files.properties.size", `{"examples":[1,2],"type":"integer"}`},
+		{"$defs.resource-dirs-files.allOf", `[{"if":{"properties":{"format":{"const":"json"}},"required":["format"]},"then":{"properties":{"indent":{"minimum":0,"type":"integer"}},"required":["indent"]}}]`},
+
+		// Version
+		{"$defs.version-dirs-files.properties.defaultversionid", ``},
+		{"$defs.version-dirs-files.properties.versiontags", ``},
+		{"$defs.version-dirs-files.properties.isdefault.readOnly", `true`},
+		{"$defs.version-dirs-files.properties.deprecated.type", `"object"`},
+		{"$defs.version-dirs-files.properties.versions", ``},
+		{"$defs.version-dirs-files.properties.format.type", `"string"`},
+	}
+
+	for _, test := range tests {
+		var val any = schema
+		for _, part := range strings.Split(test.path, ".") {
+			if obj, ok := val.(map[string]any); ok {
+				val = obj[part]
+			} else {
+				val = nil
+			}
+		}
+
+		got := ""
+		if val != nil {
+			tmp, _ := json.Marshal(val)
+			got = string(tmp)
+		}
+		if got != test.exp {
+			t.Fatalf("%s:\nExp: %s\nGot: %s", test.path, test.exp, got)
+		}
+	}
+}
+
+func TestIfValueToConst(t *testing.T) {
+	type Test struct {
+		val    string
+		daType string
+		exp    any
+	}
+
+	tests := []Test{
+		{"json", STRING, "json"},
+		{"true", BOOLEAN, true},
+		{"maybe", BOOLEAN, "maybe"},
+		{"5", INTEGER, 5.0},
+		{"5.5", DECIMAL, 5.5},
+		{"5", STRING, "5"},
+	}
+
+	for _, test := range tests {
+		got := IfValueToConst(test.val, test.daType)
+		if got != test.exp {
+			t.Fatalf("IfValueToConst(%q, %q): Exp: %v(%T) Got: %v(%T)",
+				test.val, test.daType, test.exp, test.exp, got, got)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// A single requirement of a label selector, e.g. "env in (prod,staging)".
+// The syntax is the same as Kubernetes' label selectors:
+//
+//	key               - label exists
+//	!key              - label doesn't exist
+//	key=val           - label has that value ("==" is an alias)
+//	key!=val          - label doesn't exist or has a different value
+//	key in (v1,v2)    - label has one of the values
+//	key notin (v1,v2) - label doesn't exist or has none of the values
+type LabelRequirement struct {
+	Key    string
+	Op     string // exists, !, =, !=, in, notin
+	Values []string
+}
+
+var RegexpLabelSet = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+// Split "sel" on the commas that aren't inside of a "(...)"
+func splitLabelSelector(sel string) ([]string, error) {
+	res := []string{}
+	depth := 0
+	start := 0
+	for i, ch := range sel {
+		switch ch {
+		case '(':
+			if depth++; depth > 1 {
+				return nil, fmt.Errorf("nested parentheses aren't allowed")
+			}
+		case ')':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("unexpected ')'")
+			}
+		case ',':
+			if depth == 0 {
+				res = append(res, sel[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("missing ')'")
+	}
+	return append(res, sel[start:]), nil
+}
+
+func ParseLabelSelector(sel string) ([]*LabelRequirement, error) {
+	parts, err := splitLabelSelector(sel)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid label selector %q: %s", sel, err)
+	}
+
+	reqs := []*LabelRequirement{}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		req := &LabelRequirement{}
+		if key, ok := strings.CutPrefix(part, "!"); ok {
+			req.Key, req.Op = strings.TrimSpace(key), "!"
+		} else if m := RegexpLabelSet.FindStringSubmatch(part); m != nil {
+			req.Key, req.Op = m[1], m[2]
+			for _, val := range strings.Split(m[3], ",") {
+				if val = strings.TrimSpace(val); val != "" {
+					req.Values = append(req.Values, val)
+				}
+			}
+			if len(req.Values) == 0 {
+				return nil, fmt.Errorf("Invalid label selector %q: %q "+
+					"must have at least one value", sel, part)
+			}
+		} else if key, val, ok := strings.Cut(part, "!="); ok {
+			req.Key, req.Op = key, "!="
+			req.Values = []string{val}
+		} else if key, val, ok := strings.Cut(part, "=="); ok {
+			req.Key, req.Op = key, "="
+			req.Values = []string{val}
+		} else if key, val, ok := strings.Cut(part, "="); ok {
+			req.Key, req.Op = key, "="
+			req.Values = []string{val}
+		} else {
+			req.Key, req.Op = part, "exists"
+		}
+
+		req.Key = strings.TrimSpace(req.Key)
+		if !IsValidMapKey(req.Key) {
+			return nil, fmt.Errorf("Invalid label selector %q: invalid "+
+				"label key %q", sel, req.Key)
+		}
+		for i, val := range req.Values {
+			req.Values[i] = strings.TrimSpace(val)
+			if strings.ContainsAny(req.Values[i], "=!()") {
+				return nil, fmt.Errorf("Invalid label selector %q: invalid "+
+					"label value %q", sel, req.Values[i])
+			}
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
+
+// Convert the label selector into filter expressions, returned as a list
+// of OR'd groups of AND'd expressions (see RequestInfo.Filters)
+func (info *RequestInfo) LabelFilters(sel string) ([][]*FilterExpr, error) {
+	reqs, err := ParseLabelSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+
+	abstract := ""
+	if info.Abstract != "" {
+		absPP, _ := PropPathFromPath(info.Abstract)
+		abstract = absPP.Abstract()
+	}
+
+	orFilters := [][]*FilterExpr{[]*FilterExpr{}}
+	and := func(exprs ...*FilterExpr) {
+		for i, andFilters := range orFilters {
+			orFilters[i] = append(andFilters, exprs...)
+		}
+	}
+
+	for _, req := range reqs {
+		path := info.FilterPath(NewPPP("labels").P(req.Key))
+
+		switch req.Op {
+		case "exists":
+			and(&FilterExpr{Path: path})
+		case "!":
+			and(&FilterExpr{Path: path, Negate: true, Abstract: abstract})
+		case "=":
+			and(&FilterExpr{Path: path, Value: req.Values[0], HasEqual: true})
+		case "!=", "notin":
+			for _, val := range req.Values {
+				and(&FilterExpr{Path: path, Value: val, HasEqual: true,
+					Negate: true, Abstract: abstract})
+			}
+		case "in":
+			// Each value becomes its own OR'd group
+			newFilters := [][]*FilterExpr{}
+			for _, andFilters := range orFilters {
+				for _, val := range req.Values {
+					newFilters = append(newFilters,
+						append(slices.Clone(andFilters), &FilterExpr{
+							Path: path, Value: val, HasEqual: true}))
+				}
+			}
+			orFilters = newFilters
+		}
+	}
+
+	if len(orFilters[0]) == 0 {
+		return nil, nil
+	}
+	return orFilters, nil
+}
@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	type SelectorTest struct {
+		Selector string
+		Result   string // "op:key:v1|v2" separated by spaces
+		Err      string
+	}
+
+	tests := []SelectorTest{
+		{"", "", ""},
+		{"env", "exists:env:", ""},
+		{" !env ", "!:env:", ""},
+		{"env=prod", "=:env:prod", ""},
+		{"env==prod", "=:env:prod", ""},
+		{"env = prod", "=:env:prod", ""},
+		{"env!=prod", "!=:env:prod", ""},
+		{"env=", "=:env:", ""},
+		{"env in (prod, staging)", "in:env:prod|staging", ""},
+		{"env notin (prod)", "notin:env:prod", ""},
+		{"env in (prod,staging),!experimental,tier!=frontend",
+			"in:env:prod|staging !:experimental: !=:tier:frontend", ""},
+		{"a.b-c_d,,x", "exists:a.b-c_d: exists:x:", ""},
+
+		{"env in (prod", "", `Invalid label selector "env in (prod": missing ')'`},
+		{"env)", "", `Invalid label selector "env)": unexpected ')'`},
+		{"env in ((a))", "", `Invalid label selector "env in ((a))": nested parentheses aren't allowed`},
+		{"env in ()", "", `Invalid label selector "env in ()": "env in ()" must have at least one value`},
+		{"Env", "", `Invalid label selector "Env": invalid label key "Env"`},
+		{"!", "", `Invalid label selector "!": invalid label key ""`},
+		{"=prod", "", `Invalid label selector "=prod": invalid label key ""`},
+		{"env=a=b", "", `Invalid label selector "env=a=b": invalid label value "a=b"`},
+	}
+
+	for _, test := range tests {
+		reqs, err := ParseLabelSelector(test.Selector)
+		if test.Err != "" {
+			if err == nil || err.Error() != test.Err {
+				t.Fatalf("Parse(%q)\nExp err: %s\nGot err: %v", test.Selector,
+					test.Err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %s", test.Selector, err)
+		}
+
+		list := []string{}
+		for _, req := range reqs {
+			list = append(list, req.Op+":"+req.Key+":"+
+				strings.Join(req.Values, "|"))
+		}
+		got := strings.Join(list, " ")
+		if got != test.Result {
+			t.Fatalf("Parse(%q)\nExp: %s\nGot: %s", test.Selector,
+				test.Result, got)
+		}
+	}
+}
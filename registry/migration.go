@@ -0,0 +1,438 @@
+package registry
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/duglin/dlog"
+)
+
+const (
+	MIGRATE_RENAME  = "rename"  // move "name" to "newname"
+	MIGRATE_DEFAULT = "default" // set "name" to "value" if it's missing
+	MIGRATE_DROP    = "drop"    // remove "name"
+
+	// Rename Group type "name" to "newname" (GROUPS), or rename/move
+	// Resource type "name" to "newname" (GROUPS/RESOURCES)
+	MIGRATE_RENAME_TYPE = "renametype"
+)
+
+// A simple change to the existing data that's done as part of a model
+// update (see Model.ApplyNewModel). They're only included in the PUT /model
+// request, they're never saved as part of the model.
+type Migration struct {
+	Op      string `json:"op"`
+	Entity  string `json:"entity,omitempty"` // "", GROUPS or GROUPS/RESOURCES
+	Name    string `json:"name"`
+	NewName string `json:"newname,omitempty"` // MIGRATE_RENAME(_TYPE)
+	Value   any    `json:"value,omitempty"`   // MIGRATE_DEFAULT
+}
+
+// Make sure the migrations make sense for model "m". The "entity" of a
+// migration is the type of entity whose data is changed: "" for the Registry,
+// "GROUPS" for a Group type and "GROUPS/RESOURCES" for the Versions of a
+// Resource type (which is where their extensions are stored).
+// MIGRATE_RENAME_TYPE migrations don't have an "entity", their "newname" must
+// be a type in "m" while their "name" is checked when they're run since it's
+// a type in the current model.
+func (m *Model) VerifyMigrations(migrations []*Migration) error {
+	for i, mig := range migrations {
+		if mig == nil {
+			return fmt.Errorf("Migration %d must not be empty", i)
+		}
+
+		switch mig.Op {
+		case MIGRATE_RENAME_TYPE:
+			if err := m.VerifyTypeRename(mig); err != nil {
+				return fmt.Errorf("Migration %d %s", i, err)
+			}
+			continue
+		case MIGRATE_RENAME:
+			if !IsValidAttributeName(mig.NewName) {
+				return fmt.Errorf("Migration %d has an invalid \"newname\" "+
+					"value: %q", i, mig.NewName)
+			}
+			if mig.NewName == mig.Name {
+				return fmt.Errorf("Migration %d has the same \"name\" and "+
+					"\"newname\": %q", i, mig.Name)
+			}
+		case MIGRATE_DEFAULT:
+			if IsNil(mig.Value) {
+				return fmt.Errorf("Migration %d must have a \"value\"", i)
+			}
+		case MIGRATE_DROP:
+		default:
+			return fmt.Errorf("Migration %d has an invalid \"op\" value (%s), "+
+				"must be one of: %s, %s, %s, %s", i, mig.Op, MIGRATE_DEFAULT,
+				MIGRATE_DROP, MIGRATE_RENAME, MIGRATE_RENAME_TYPE)
+		}
+
+		if !IsValidAttributeName(mig.Name) {
+			return fmt.Errorf("Migration %d has an invalid \"name\" value: %q",
+				i, mig.Name)
+		}
+		for _, name := range []string{mig.Name, mig.NewName} {
+			if SpecProps[name] != nil {
+				return fmt.Errorf("Migration %d can't change the spec "+
+					"defined attribute %q", i, name)
+			}
+		}
+
+		if _, err := m.MigrationAbstract(mig); err != nil {
+			return fmt.Errorf("Migration %d %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Checks a MIGRATE_RENAME_TYPE migration. Any error is meant to follow
+// "Migration N".
+func (m *Model) VerifyTypeRename(mig *Migration) error {
+	isBad := func(name string) bool {
+		parts := strings.Split(name, "/")
+		return len(parts) > 2 || slices.ContainsFunc(parts,
+			func(part string) bool { return !IsValidAttributeName(part) })
+	}
+
+	if isBad(mig.Name) {
+		return fmt.Errorf("has an invalid \"name\" value: %q", mig.Name)
+	}
+	if isBad(mig.NewName) {
+		return fmt.Errorf("has an invalid \"newname\" value: %q", mig.NewName)
+	}
+	if strings.Count(mig.Name, "/") != strings.Count(mig.NewName, "/") {
+		return fmt.Errorf("must rename a Group type to a Group type, or a " +
+			"Resource type to a Resource type")
+	}
+	if mig.Name == mig.NewName {
+		return fmt.Errorf("has the same \"name\" and \"newname\": %q",
+			mig.Name)
+	}
+	if mig.Entity != "" {
+		return fmt.Errorf("must not have an \"entity\"")
+	}
+
+	_, err := m.MigrationAbstract(&Migration{Entity: mig.NewName})
+	return err
+}
+
+// Returns the name that Group type ("GROUPS") or Resource type
+// ("GROUPS/RESOURCES") "name" will have once the MIGRATE_RENAME_TYPE
+// "migrations" are done. Renaming a Group type renames the Group part of the
+// names of its Resource types too.
+func RenamedType(migrations []*Migration, name string) string {
+	for _, mig := range migrations {
+		if mig.Op != MIGRATE_RENAME_TYPE {
+			continue
+		}
+		if name == mig.Name {
+			name = mig.NewName
+		} else if gmName, rmName, ok := strings.Cut(name, "/"); ok &&
+			gmName == mig.Name {
+			name = mig.NewName + "/" + rmName
+		}
+	}
+	return name
+}
+
+// Returns the Abstract of the entities that "mig" will change
+func (m *Model) MigrationAbstract(mig *Migration) (string, error) {
+	if mig.Entity == "" {
+		return "", nil
+	}
+
+	gmName, rmName, _ := strings.Cut(mig.Entity, "/")
+	gm := m.Groups[gmName]
+	if gm == nil {
+		return "", fmt.Errorf("has an unknown Group type: %q", gmName)
+	}
+	if rmName == "" {
+		return NewPPP(gmName).Abstract(), nil
+	}
+	if gm.Resources[rmName] == nil {
+		return "", fmt.Errorf("has an unknown Resource type: %q", mig.Entity)
+	}
+	return NewPPP(gmName).P(rmName).P("versions").Abstract(), nil
+}
+
+// Executes the migrations, in order, against the data in the Registry. The
+// entities are saved as-is, nothing is validated or updated (e.g. "epoch").
+func (m *Model) RunMigrations(migrations []*Migration) error {
+	reg := m.Registry
+
+	for _, mig := range migrations {
+		if mig.Op == MIGRATE_RENAME_TYPE {
+			continue // See RunTypeMigrations
+		}
+		log.VPrintf(4, "Migration: %s %q(%s)", mig.Op, mig.Name, mig.Entity)
+
+		abs, err := m.MigrationAbstract(mig)
+		if err != nil {
+			return err
+		}
+
+		query, args := `Abstract=?`, []any{abs}
+		if mig.Entity == "" {
+			query, args = `Level=0`, nil
+		}
+
+		entities, err := RawEntitiesFromQuery(reg.tx, reg.DbSID, query,
+			args...)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entities {
+			oldVal, ok := e.Object[mig.Name]
+			ok = ok && !IsNil(oldVal)
+
+			switch mig.Op {
+			case MIGRATE_RENAME:
+				if !ok {
+					continue
+				}
+				err = e.JustSet(NewPPP(mig.NewName), oldVal)
+				if err == nil {
+					err = e.JustSet(NewPPP(mig.Name), nil)
+				}
+			case MIGRATE_DEFAULT:
+				if ok {
+					continue
+				}
+				err = e.JustSet(NewPPP(mig.Name), mig.Value)
+			case MIGRATE_DROP:
+				if !ok {
+					continue
+				}
+				err = e.JustSet(NewPPP(mig.Name), nil)
+			}
+
+			if err == nil {
+				err = e.Save()
+			}
+			if err != nil {
+				return fmt.Errorf("Error migrating %q: %s", "/"+e.Path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Executes the MIGRATE_RENAME_TYPE migrations of "newM", in order, against
+// the current model and the data in the Registry. This needs to be done
+// before "newM" is applied, otherwise the old types, and all of their
+// entities, would be deleted since they're not in "newM".
+func (m *Model) RunTypeMigrations(newM *Model) error {
+	for _, mig := range newM.Migrations {
+		if mig.Op != MIGRATE_RENAME_TYPE {
+			continue
+		}
+		log.VPrintf(4, "Migration: %s %q->%q", mig.Op, mig.Name, mig.NewName)
+
+		var err error
+		if strings.Contains(mig.Name, "/") {
+			err = m.RenameResourceType(mig.Name, mig.NewName, newM)
+		} else {
+			err = m.RenameGroupType(mig.Name, mig.NewName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Replaces the first part of the Path ("oldPath") and of the Abstract
+// ("oldAbs") of the rows of "table" that match "where"
+func ReplacePathPrefix(tx *Tx, table string, oldPath string, newPath string,
+	oldAbs string, newAbs string, where string, args ...any) error {
+
+	args = append([]any{newPath, len(oldPath) + 1, newAbs, len(oldAbs) + 1},
+		args...)
+	return Do(tx, `
+		UPDATE `+table+`
+		SET Path=CONCAT(?,SUBSTRING(Path,?)),
+		    Abstract=CONCAT(?,SUBSTRING(Abstract,?))
+		WHERE `+where, args...)
+}
+
+// Renames Group type "oldName" to "newName" without touching its Groups,
+// other than their paths
+func (m *Model) RenameGroupType(oldName string, newName string) error {
+	tx := m.Registry.tx
+	gm := m.Groups[oldName]
+	if gm == nil {
+		return fmt.Errorf("Can't rename unknown Group type: %q", oldName)
+	}
+	if m.Groups[newName] != nil {
+		return fmt.Errorf("Can't rename Group type %q to %q, it already "+
+			"exists", oldName, newName)
+	}
+
+	err := DoOne(tx, `UPDATE ModelEntities SET Plural=? WHERE SID=?`,
+		newName, gm.SID)
+	if err == nil {
+		err = ReplacePathPrefix(tx, `"Groups"`, oldName, newName,
+			oldName, newName, `ModelSID=?`, gm.SID)
+	}
+	if err == nil {
+		err = ReplacePathPrefix(tx, `Resources`, oldName, newName,
+			oldName, newName, `GroupSID IN (
+			    SELECT SID FROM "Groups" WHERE ModelSID=?)`, gm.SID)
+	}
+	if err == nil {
+		err = ReplacePathPrefix(tx, `Versions`, oldName, newName,
+			oldName, newName, `ResourceSID IN (
+			    SELECT r.SID FROM Resources AS r
+			    JOIN "Groups" AS g ON (g.SID=r.GroupSID)
+			    WHERE g.ModelSID=?)`, gm.SID)
+	}
+	if err != nil {
+		log.Printf("Error renaming Group type %q: %s", oldName, err)
+		return err
+	}
+
+	delete(m.Groups, oldName)
+	gm.Plural = newName
+	m.Groups[newName] = gm
+
+	return m.Registry.RenameXIDs(oldName, newName)
+}
+
+// Renames Resource type "oldName" (GROUPS/RESOURCES) to "newName". If the
+// Group type changes then each Resource is moved to the Group, of the new
+// type, with the same ID as its current one - creating it if needed.
+// "newM" is used for the Singular name of the Group type if it needs to be
+// created.
+func (m *Model) RenameResourceType(oldName string, newName string,
+	newM *Model) error {
+
+	tx := m.Registry.tx
+	oldGMName, oldRMName, _ := strings.Cut(oldName, "/")
+	newGMName, newRMName, _ := strings.Cut(newName, "/")
+
+	oldGM := m.Groups[oldGMName]
+	if oldGM == nil || oldGM.Resources[oldRMName] == nil {
+		return fmt.Errorf("Can't rename unknown Resource type: %q", oldName)
+	}
+	rm := oldGM.Resources[oldRMName]
+
+	newGM := m.Groups[newGMName]
+	if newGM == nil {
+		var err error
+		newGM, err = m.AddGroupModel(newGMName,
+			newM.Groups[newGMName].Singular)
+		if err != nil {
+			return err
+		}
+	}
+	if newGM.Resources[newRMName] != nil {
+		return fmt.Errorf("Can't rename Resource type %q to %q, it already "+
+			"exists", oldName, newName)
+	}
+
+	err := DoOne(tx, `UPDATE ModelEntities SET ParentSID=?, Plural=?
+	    WHERE SID=?`, newGM.SID, newRMName, rm.SID)
+	if err != nil {
+		log.Printf("Error renaming Resource type %q: %s", oldName, err)
+		return err
+	}
+
+	delete(oldGM.Resources, oldRMName)
+	rm.Plural = newRMName
+	rm.GroupModel = newGM
+	newGM.Resources[newRMName] = rm
+
+	// Find all of the Groups that have Resources of this type
+	results, err := Query(tx, `
+		SELECT DISTINCT g.SID, g.UID FROM "Groups" AS g
+		JOIN Resources AS r ON (r.GroupSID=g.SID)
+		WHERE r.ModelSID=?`, rm.SID)
+	defer results.Close()
+	if err != nil {
+		return err
+	}
+
+	groups := [][2]string{} // SID, UID
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		groups = append(groups,
+			[2]string{NotNilString(row[0]), NotNilString(row[1])})
+	}
+	results.Close()
+
+	oldAbs := NewPPP(oldGMName).P(oldRMName).Abstract()
+	newAbs := NewPPP(newGMName).P(newRMName).Abstract()
+
+	for _, group := range groups {
+		gSID, gID := group[0], group[1]
+
+		newGSID := gSID
+		if newGM != oldGM {
+			g, err := m.Registry.FindGroup(newGMName, gID, false)
+			if err == nil && g == nil {
+				g, err = m.Registry.AddGroup(newGMName, gID)
+			}
+			if err != nil {
+				return err
+			}
+			newGSID = g.DbSID
+		}
+
+		oldPath := oldGMName + "/" + gID + "/" + oldRMName
+		newPath := newGMName + "/" + gID + "/" + newRMName
+
+		err = ReplacePathPrefix(tx, `Versions`, oldPath, newPath,
+			oldAbs, newAbs, `ResourceSID IN (
+			    SELECT SID FROM Resources WHERE GroupSID=? AND ModelSID=?)`,
+			gSID, rm.SID)
+		if err == nil {
+			err = ReplacePathPrefix(tx, `Resources`, oldPath, newPath,
+				oldAbs, newAbs, `GroupSID=? AND ModelSID=?`, gSID, rm.SID)
+		}
+		if err == nil {
+			err = Do(tx, `UPDATE Resources SET GroupSID=?
+			    WHERE GroupSID=? AND ModelSID=?`, newGSID, gSID, rm.SID)
+		}
+		if err == nil {
+			err = m.Registry.RenameXIDs(oldPath, newPath)
+		}
+		if err != nil {
+			log.Printf("Error moving %q to %q: %s", oldPath, newPath, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Checks all of the entities in the Registry against the current model and
+// returns one error per invalid entity, prefixed with its path. The returned
+// "error" is for problems talking to the DB.
+func (reg *Registry) ValidateEntities() ([]error, error) {
+	entities, err := RawEntitiesFromQuery(reg.tx, reg.DbSID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	errs := []error{}
+	for _, e := range entities {
+		// Resources are checked via their Versions
+		if e.Level == 2 {
+			continue
+		}
+
+		e.Registry = reg
+		e.NewObject = e.Object
+		e.EpochSet = true // don't compare epochs
+
+		if err := e.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("/%s: %s", e.Path, err))
+		}
+	}
+
+	return errs, nil
+}
@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"testing"
+)
+
+func TestVerifyMigrations(t *testing.T) {
+	m := &Model{
+		Groups: map[string]*GroupModel{
+			"dirs": {Plural: "dirs", Singular: "dir",
+				Resources: map[string]*ResourceModel{
+					"files": {Plural: "files", Singular: "file"},
+				}},
+		},
+	}
+
+	type Test struct {
+		mig *Migration
+		abs string
+		err string
+	}
+
+	tests := []Test{
+		{&Migration{Op: "rename", Name: "a", NewName: "b"}, "", ""},
+		{&Migration{Op: "drop", Entity: "dirs", Name: "a"}, "dirs", ""},
+		{&Migration{Op: "default", Entity: "dirs/files", Name: "a",
+			Value: 5}, NewPPP("dirs").P("files").P("versions").Abstract(), ""},
+
+		{&Migration{Op: "move", Name: "a"}, "",
+			`Migration 0 has an invalid "op" value (move), must be one of: default, drop, rename, renametype`},
+		{&Migration{Op: "drop", Name: ""}, "",
+			`Migration 0 has an invalid "name" value: ""`},
+		{&Migration{Op: "drop", Name: "A"}, "",
+			`Migration 0 has an invalid "name" value: "A"`},
+		{&Migration{Op: "rename", Name: "a"}, "",
+			`Migration 0 has an invalid "newname" value: ""`},
+		{&Migration{Op: "rename", Name: "a", NewName: "a"}, "",
+			`Migration 0 has the same "name" and "newname": "a"`},
+		{&Migration{Op: "rename", Name: "a", NewName: "epoch"}, "",
+			`Migration 0 can't change the spec defined attribute "epoch"`},
+		{&Migration{Op: "drop", Name: "labels"}, "",
+			`Migration 0 can't change the spec defined attribute "labels"`},
+		{&Migration{Op: "default", Name: "a"}, "",
+			`Migration 0 must have a "value"`},
+		{&Migration{Op: "drop", Entity: "foos", Name: "a"}, "",
+			`Migration 0 has an unknown Group type: "foos"`},
+		{&Migration{Op: "drop", Entity: "dirs/foos", Name: "a"}, "",
+			`Migration 0 has an unknown Resource type: "dirs/foos"`},
+
+		{&Migration{Op: "renametype", Name: "olds", NewName: "dirs"}, "", ""},
+		{&Migration{Op: "renametype", Name: "olds/x", NewName: "dirs/files"},
+			"", ""},
+		{&Migration{Op: "renametype", Name: "olds", NewName: "foos"}, "",
+			`Migration 0 has an unknown Group type: "foos"`},
+		{&Migration{Op: "renametype", Name: "olds/x", NewName: "dirs/foos"},
+			"", `Migration 0 has an unknown Resource type: "dirs/foos"`},
+		{&Migration{Op: "renametype", Name: "olds/x", NewName: "dirs"}, "",
+			`Migration 0 must rename a Group type to a Group type, or a ` +
+				`Resource type to a Resource type`},
+		{&Migration{Op: "renametype", Name: "dirs", NewName: "dirs"}, "",
+			`Migration 0 has the same "name" and "newname": "dirs"`},
+		{&Migration{Op: "renametype", Name: "a/b/c", NewName: "dirs"}, "",
+			`Migration 0 has an invalid "name" value: "a/b/c"`},
+		{&Migration{Op: "renametype", Name: "olds", NewName: "Dirs"}, "",
+			`Migration 0 has an invalid "newname" value: "Dirs"`},
+		{&Migration{Op: "renametype", Entity: "dirs", Name: "olds",
+			NewName: "dirs"}, "", `Migration 0 must not have an "entity"`},
+	}
+
+	for _, test := range tests {
+		err := m.VerifyMigrations([]*Migration{test.mig})
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Fatalf("%#v:\nExp: %s\nGot: %s", test.mig, test.err, got)
+		}
+
+		if err == nil {
+			abs, _ := m.MigrationAbstract(test.mig)
+			if abs != test.abs {
+				t.Fatalf("%#v:\nExp abs: %q\nGot abs: %q", test.mig,
+					test.abs, abs)
+			}
+		}
+	}
+}
+
+func TestRenamedType(t *testing.T) {
+	migs := []*Migration{
+		{Op: "drop", Name: "dirs"},
+		{Op: "renametype", Name: "dirs", NewName: "folders"},
+		{Op: "renametype", Name: "folders/files", NewName: "folders/docs"},
+		{Op: "renametype", Name: "olds/logs", NewName: "folders/logs"},
+	}
+
+	for _, test := range [][2]string{
+		{"dirs", "folders"},
+		{"dirs/files", "folders/docs"},
+		{"dirs/other", "folders/other"},
+		{"olds", "olds"},
+		{"olds/logs", "folders/logs"},
+		{"dirsx/files", "dirsx/files"},
+	} {
+		if got := RenamedType(migs, test[0]); got != test[1] {
+			t.Fatalf("%s: Exp: %s Got: %s", test[0], test[1], got)
+		}
+	}
+}
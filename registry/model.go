@@ -8,6 +8,7 @@ import (
 	"maps"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -36,6 +37,9 @@ type Model struct {
 	Schemas    []string               `json:"schemas,omitempty"`
 	Attributes Attributes             `json:"attributes,omitempty"`
 	Groups     map[string]*GroupModel `json:"groups,omitempty"` // Plural
+
+	// Only used on PUT /model, never saved. See ApplyNewModel
+	Migrations []*Migration `json:"migrations,omitempty"`
 }
 
 type Attributes map[string]*Attribute // AttrName->Attr
@@ -69,6 +73,17 @@ type Attribute struct {
 	ClientRequired bool      `json:"clientrequired,omitempty"`
 	ServerRequired bool      `json:"serverrequired,omitempty"`
 	Default        any       `json:"default,omitempty"`
+	OnDelete       string    `json:"ondelete,omitempty"`
+
+	// Constraints on the value, see constraints.go
+	Pattern   string   `json:"pattern,omitempty"`   // strings
+	MinLength *int     `json:"minlength,omitempty"` // strings
+	MaxLength *int     `json:"maxlength,omitempty"` // strings
+	Minimum   *float64 `json:"minimum,omitempty"`   // numbers
+	Maximum   *float64 `json:"maximum,omitempty"`   // numbers
+	MinItems  *int     `json:"minitems,omitempty"`  // arrays & maps
+	MaxItems  *int     `json:"maxitems,omitempty"`  // arrays & maps
+	Format    string   `json:"format,omitempty"`    // strings
 
 	Attributes Attributes `json:"attributes,omitempty"` // for Objs
 	Item       *Item      `json:"item,omitempty"`       // for maps & arrays
@@ -109,15 +124,19 @@ type ResourceModel struct {
 	SID        string      `json:"-"`
 	GroupModel *GroupModel `json:"-"`
 
-	Plural           string            `json:"plural"`
-	Singular         string            `json:"singular"`
-	MaxVersions      int               `json:"maxversions"`             // do not include omitempty
-	SetVersionId     *bool             `json:"setversionid"`            // do not include omitempty
-	SetStickyDefault *bool             `json:"setstickydefaultversion"` // do not include omitempty
-	HasDocument      *bool             `json:"hasdocument"`             // do not include omitempty
-	ReadOnly         bool              `json:"readonly,omitempty"`
-	TypeMap          map[string]string `json:"typemap,omitempty"`
-	Attributes       Attributes        `json:"attributes,omitempty"`
+	Plural                   string            `json:"plural"`
+	Singular                 string            `json:"singular"`
+	MaxVersions              int               `json:"maxversions"`             // do not include omitempty
+	SetVersionId             *bool             `json:"setversionid"`            // do not include omitempty
+	SetStickyDefault         *bool             `json:"setstickydefaultversion"` // do not include omitempty
+	HasDocument              *bool             `json:"hasdocument"`             // do not include omitempty
+	ReadOnly                 bool              `json:"readonly,omitempty"`
+	Compatibility            string            `json:"compatibility,omitempty"`
+	VersionIdStrategy        string            `json:"versionidstrategy,omitempty"`
+	Retention                *RetentionPolicy  `json:"retention,omitempty"`
+	DeprecatedBlocksVersions bool              `json:"deprecatedblocksversions,omitempty"`
+	TypeMap                  map[string]string `json:"typemap,omitempty"`
+	Attributes               Attributes        `json:"attributes,omitempty"`
 }
 
 // To be picky, let's Marshal the list of attributes with Spec defined ones
@@ -282,7 +301,7 @@ func (m *Model) Save() error {
 		}
 	}
 
-	return nil
+	return m.SaveRevision()
 }
 
 func (m *Model) SetSchemas(schemas []string) error {
@@ -604,7 +623,8 @@ func LoadModel(reg *Registry) *Model {
         SELECT
             SID, RegistrySID, ParentSID, Plural, Singular, Attributes,
 			MaxVersions, SetVersionId, SetStickyDefault, HasDocument, ReadOnly,
-			TypeMap
+			TypeMap, Compatibility, VersionIdStrategy, Retention,
+			DeprecatedBlocksVersions
         FROM ModelEntities
         WHERE RegistrySID=?
         ORDER BY ParentSID ASC`, reg.DbSID)
@@ -625,6 +645,10 @@ func LoadModel(reg *Registry) *Model {
 		if row[11] != nil {
 			Unmarshal([]byte(NotNilString(row[11])), &typemap)
 		}
+		retention := (*RetentionPolicy)(nil)
+		if row[14] != nil {
+			Unmarshal([]byte(NotNilString(row[14])), &retention)
+		}
 
 		if *row[2] == nil { // ParentSID nil -> new Group
 			g := &GroupModel{ // Plural
@@ -647,17 +671,21 @@ func LoadModel(reg *Registry) *Model {
 
 			if g != nil { // should always be true, but...
 				r := &ResourceModel{
-					SID:              NotNilString(row[0]),
-					GroupModel:       g,
-					Plural:           NotNilString(row[3]),
-					Singular:         NotNilString(row[4]),
-					Attributes:       attrs,
-					MaxVersions:      NotNilIntDef(row[6], MAXVERSIONS),
-					SetVersionId:     PtrBool(NotNilBoolDef(row[7], SETVERSIONID)),
-					SetStickyDefault: PtrBool(NotNilBoolDef(row[8], SETSTICKYDEFAULT)),
-					HasDocument:      PtrBool(NotNilBoolDef(row[9], HASDOCUMENT)),
-					ReadOnly:         NotNilBoolDef(row[10], READONLY),
-					TypeMap:          typemap,
+					SID:                      NotNilString(row[0]),
+					GroupModel:               g,
+					Plural:                   NotNilString(row[3]),
+					Singular:                 NotNilString(row[4]),
+					Attributes:               attrs,
+					MaxVersions:              NotNilIntDef(row[6], MAXVERSIONS),
+					SetVersionId:             PtrBool(NotNilBoolDef(row[7], SETVERSIONID)),
+					SetStickyDefault:         PtrBool(NotNilBoolDef(row[8], SETSTICKYDEFAULT)),
+					HasDocument:              PtrBool(NotNilBoolDef(row[9], HASDOCUMENT)),
+					ReadOnly:                 NotNilBoolDef(row[10], READONLY),
+					TypeMap:                  typemap,
+					Compatibility:            NotNilString(row[12]),
+					VersionIdStrategy:        NotNilString(row[13]),
+					Retention:                retention,
+					DeprecatedBlocksVersions: NotNilBoolDef(row[15], false),
 				}
 
 				r.Attributes.SetSpecPropsFields()
@@ -681,11 +709,21 @@ func (m *Model) FindGroupModel(gTypePlural string) *GroupModel {
 	return nil
 }
 
+// Replaces the model with "newM", deleting the data of any Group or Resource
+// types that were removed. Types renamed by "newM.Migrations" are renamed
+// first so that their data is kept, then the rest of the migrations are run
+// against the existing data.
 func (m *Model) ApplyNewModel(newM *Model) error {
 	newM.Registry = m.Registry
 	if err := newM.Verify(); err != nil {
 		return err
 	}
+	if err := newM.VerifyMigrations(newM.Migrations); err != nil {
+		return err
+	}
+	if err := m.RunTypeMigrations(newM); err != nil {
+		return err
+	}
 
 	// Delete old Schemas, then add new ones
 	m.Schemas = []string{XREGSCHEMA + "/" + SPECVERSION}
@@ -702,6 +740,7 @@ func (m *Model) ApplyNewModel(newM *Model) error {
 	}
 
 	m.Attributes = newM.Attributes
+	m.Attributes.SetSpecPropsFields()
 
 	// Find all old groups that need to be deleted
 	for gmPlural, gm := range m.Groups {
@@ -735,19 +774,24 @@ func (m *Model) ApplyNewModel(newM *Model) error {
 			oldGM.Singular = newGM.Singular
 		}
 		oldGM.Attributes = newGM.Attributes
+		oldGM.Attributes.SetSpecPropsFields()
 
 		for _, newRM := range newGM.Resources {
 			log.VPrintf(4, "Applying Resource: %s", newRM.Plural)
 			oldRM := oldGM.Resources[newRM.Plural]
 			if oldRM == nil {
 				oldRM, err = oldGM.AddResourceModelFull(&ResourceModel{
-					Plural:           newRM.Plural,
-					Singular:         newRM.Singular,
-					MaxVersions:      newRM.MaxVersions,
-					SetVersionId:     newRM.SetVersionId,
-					SetStickyDefault: newRM.SetStickyDefault,
-					HasDocument:      newRM.HasDocument,
-					ReadOnly:         newRM.ReadOnly,
+					Plural:                   newRM.Plural,
+					Singular:                 newRM.Singular,
+					MaxVersions:              newRM.MaxVersions,
+					SetVersionId:             newRM.SetVersionId,
+					SetStickyDefault:         newRM.SetStickyDefault,
+					HasDocument:              newRM.HasDocument,
+					ReadOnly:                 newRM.ReadOnly,
+					Compatibility:            newRM.Compatibility,
+					VersionIdStrategy:        newRM.VersionIdStrategy,
+					Retention:                newRM.Retention,
+					DeprecatedBlocksVersions: newRM.DeprecatedBlocksVersions,
 				})
 				if err != nil {
 					log.VPrintf(4, "Err: %s", err)
@@ -761,8 +805,13 @@ func (m *Model) ApplyNewModel(newM *Model) error {
 				oldRM.SetStickyDefault = newRM.SetStickyDefault
 				oldRM.HasDocument = newRM.HasDocument
 				oldRM.ReadOnly = newRM.ReadOnly
+				oldRM.Compatibility = newRM.Compatibility
+				oldRM.VersionIdStrategy = newRM.VersionIdStrategy
+				oldRM.Retention = newRM.Retention
+				oldRM.DeprecatedBlocksVersions = newRM.DeprecatedBlocksVersions
 			}
 			oldRM.Attributes = newRM.Attributes
+			oldRM.Attributes.SetSpecPropsFields()
 			oldRM.TypeMap = newRM.TypeMap
 		}
 	}
@@ -773,7 +822,7 @@ func (m *Model) ApplyNewModel(newM *Model) error {
 		return err
 	}
 
-	return nil
+	return m.RunMigrations(newM.Migrations)
 }
 
 func (gm *GroupModel) Delete() error {
@@ -800,17 +849,12 @@ func (gm *GroupModel) Save() error {
 	buf, _ := json.Marshal(gm.Attributes)
 	attrs := string(buf)
 
-	err := DoZeroTwo(gm.Registry.tx, `
-        INSERT INTO ModelEntities(
-            SID, RegistrySID,
-			ParentSID, Plural, Singular, Attributes)
-        VALUES(?,?,?,?,?,?)
-        ON DUPLICATE KEY UPDATE
-		    ParentSID=?,Plural=?,Singular=?,Attributes=?
-		`,
-		gm.SID, gm.Registry.DbSID,
+	err := DoZeroOne(gm.Registry.tx, `
+        UPDATE ModelEntities
+		SET ParentSID=?,Plural=?,Singular=?,Attributes=?
+		WHERE SID=? AND RegistrySID=?`,
 		nil, gm.Plural, gm.Singular, attrs,
-		nil, gm.Plural, gm.Singular, attrs)
+		gm.SID, gm.Registry.DbSID)
 	if err != nil {
 		log.Printf("Error updating groupModel(%s): %s", gm.Plural, err)
 	}
@@ -947,14 +991,20 @@ func (gm *GroupModel) AddResourceModelFull(rm *ResourceModel) (*ResourceModel, e
 
 	buf, _ := json.Marshal(rm.TypeMap)
 	typemap := string(buf)
+	buf, _ = json.Marshal(rm.Retention)
+	retention := string(buf)
 
 	err := DoOne(gm.Registry.tx, `
 		INSERT INTO ModelEntities(
 			SID, RegistrySID, ParentSID, Plural, Singular, MaxVersions,
-			SetVersionId, SetStickyDefault, HasDocument, ReadOnly, TypeMap)
-		VALUES(?,?,?,?,?,?,?,?,?,?,?)`,
+			SetVersionId, SetStickyDefault, HasDocument, ReadOnly, TypeMap,
+			Compatibility, VersionIdStrategy, Retention,
+			DeprecatedBlocksVersions)
+		VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
 		rm.SID, gm.Registry.DbSID, gm.SID, rm.Plural, rm.Singular, rm.MaxVersions,
-		rm.GetSetVersionId(), rm.GetSetStickyDefault(), rm.GetHasDocument(), rm.ReadOnly, typemap)
+		rm.GetSetVersionId(), rm.GetSetStickyDefault(), rm.GetHasDocument(), rm.ReadOnly, typemap,
+		rm.Compatibility, rm.VersionIdStrategy, retention,
+		rm.DeprecatedBlocksVersions)
 	if err != nil {
 		log.Printf("Error inserting resourceModel(%s): %s", rm.Plural, err)
 		return nil, err
@@ -966,6 +1016,12 @@ func (gm *GroupModel) AddResourceModelFull(rm *ResourceModel) (*ResourceModel, e
 	if err = gm.Registry.Model.VerifyAndSave(); err != nil {
 		// Undo
 		ResetMap(gm.Resources, rm.Plural, oldVal)
+		if err2 := DoOne(gm.Registry.tx, `
+			DELETE FROM ModelEntities WHERE SID=? AND RegistrySID=?`,
+			rm.SID, gm.Registry.DbSID); err2 != nil {
+			return nil, fmt.Errorf("%s (and removing resourceModel(%s) "+
+				"failed: %s)", err, rm.Plural, err2)
+		}
 		return nil, err
 	}
 
@@ -984,6 +1040,20 @@ func (rm *ResourceModel) GetHasDocument() bool {
 	return rm.HasDocument == nil || *rm.HasDocument == true
 }
 
+func (rm *ResourceModel) GetCompatibility() string {
+	if rm.Compatibility == "" {
+		return COMPATIBILITY
+	}
+	return rm.Compatibility
+}
+
+func (rm *ResourceModel) GetVersionIdStrategy() string {
+	if rm.VersionIdStrategy == "" {
+		return VERSIONIDSTRATEGY
+	}
+	return rm.VersionIdStrategy
+}
+
 func (rm *ResourceModel) Delete() error {
 	log.VPrintf(3, ">Enter: Delete.ResourceModel: %s", rm.Plural)
 	defer log.VPrintf(3, "<Exit: Delete.ResourceModel")
@@ -1009,26 +1079,24 @@ func (rm *ResourceModel) Save() error {
 	attrs := string(buf)
 	buf, _ = json.Marshal(rm.TypeMap)
 	typemap := string(buf)
+	buf, _ = json.Marshal(rm.Retention)
+	retention := string(buf)
 
-	err := DoZeroTwo(rm.GroupModel.Registry.tx, `
-        INSERT INTO ModelEntities(
-            SID, RegistrySID,
-			ParentSID, Plural, Singular, MaxVersions,
-			Attributes,
-			SetVersionId, SetStickyDefault, HasDocument, ReadOnly, TypeMap)
-        VALUES(?,?,?,?,?,?,?,?,?,?,?,?)
-        ON DUPLICATE KEY UPDATE
-            ParentSID=?, Plural=?, Singular=?,
+	err := DoZeroOne(rm.GroupModel.Registry.tx, `
+        UPDATE ModelEntities
+		SET ParentSID=?, Plural=?, Singular=?,
 			Attributes=?,
-            MaxVersions=?, SetVersionId=?, SetStickyDefault=?, HasDocument=?, ReadOnly=?, TypeMap=?`,
-		rm.SID, rm.GroupModel.Registry.DbSID,
-		rm.GroupModel.SID, rm.Plural, rm.Singular, rm.MaxVersions,
-		attrs,
-		rm.GetSetVersionId(), rm.GetSetStickyDefault(), rm.GetHasDocument(), rm.ReadOnly, typemap,
-
+            MaxVersions=?, SetVersionId=?, SetStickyDefault=?, HasDocument=?, ReadOnly=?, TypeMap=?,
+			Compatibility=?, VersionIdStrategy=?, Retention=?,
+			DeprecatedBlocksVersions=?
+		WHERE SID=? AND RegistrySID=?`,
 		rm.GroupModel.SID, rm.Plural, rm.Singular,
 		attrs,
-		rm.MaxVersions, rm.GetSetVersionId(), rm.GetSetStickyDefault(), rm.GetHasDocument(), rm.ReadOnly, typemap)
+		rm.MaxVersions, rm.GetSetVersionId(), rm.GetSetStickyDefault(), rm.GetHasDocument(), rm.ReadOnly, typemap,
+		rm.Compatibility, rm.VersionIdStrategy, retention,
+		rm.DeprecatedBlocksVersions,
+
+		rm.SID, rm.GroupModel.Registry.DbSID)
 	if err != nil {
 		log.Printf("Error updating resourceModel(%s): %s", rm.Plural, err)
 		return err
@@ -1169,14 +1237,14 @@ func IsScalar(daType string) bool {
 	return daType == BOOLEAN || daType == DECIMAL || daType == INTEGER ||
 		daType == STRING || daType == TIMESTAMP || daType == UINTEGER ||
 		daType == URI || daType == URI_REFERENCE || daType == URI_TEMPLATE ||
-		daType == URL
+		daType == URL || daType == XID
 }
 
 // Is some string variant
 func IsString(daType string) bool {
 	return daType == STRING || daType == TIMESTAMP ||
 		daType == URI || daType == URI_REFERENCE || daType == URI_TEMPLATE ||
-		daType == URL
+		daType == URL || daType == XID
 }
 
 func (a *Attribute) GetStrict() bool {
@@ -1350,12 +1418,20 @@ func (m *Model) GetBaseAttributes() Attributes {
 	return attrs
 }
 
+// Top-level paths that the server handles itself (e.g. /model), so they
+// can't be used as the name of a Group
+var ReservedGroupNames = []string{"model", "retention"}
+
 func (gm *GroupModel) Verify(gmName string) error {
 	if !IsValidAttributeName(gmName) {
 		return fmt.Errorf("Invalid Group name/key %q - must match %q",
 			gmName, RegexpPropName.String())
 	}
 
+	if slices.Contains(ReservedGroupNames, gmName) {
+		return fmt.Errorf("Group name %q is reserved", gmName)
+	}
+
 	if gm.Plural != gmName {
 		return fmt.Errorf("Group %q must have a `plural` value of %q, not %q",
 			gmName, gmName, gm.Plural)
@@ -1516,6 +1592,26 @@ func (rm *ResourceModel) Verify(rmName string) error {
 		}
 	}
 
+	if rm.Compatibility != "" && !IsValidCompatibility(rm.Compatibility) {
+		return fmt.Errorf("Resource %q has an invalid 'compatibility' value "+
+			"(%s). Must be one of %s", rmName, rm.Compatibility,
+			strings.Join(CompatibilityValues, ", "))
+	}
+
+	switch rm.VersionIdStrategy {
+	case "", VERSIONID_COUNTER, VERSIONID_SEMVER, VERSIONID_TIMESTAMP,
+		VERSIONID_UUID:
+	default:
+		return fmt.Errorf("Resource %q has an invalid 'versionidstrategy' "+
+			"value (%s). Must be one of %s, %s, %s, %s", rmName,
+			rm.VersionIdStrategy, VERSIONID_COUNTER, VERSIONID_SEMVER,
+			VERSIONID_TIMESTAMP, VERSIONID_UUID)
+	}
+
+	if err := rm.Retention.Verify(rm); err != nil {
+		return err
+	}
+
 	// Make sure the typemap's values are just certain strings
 	for _, v := range rm.TypeMap {
 		if v != "string" && v != "json" && v != "binary" {
@@ -1941,6 +2037,27 @@ func (attrs Attributes) Verify(ld *LevelData) error {
 				return fmt.Errorf("%q \"default\" value must be of type %q",
 					path.UI(), attr.Type)
 			}
+
+			if len(attr.Enum) > 0 && attr.GetStrict() {
+				valStr := fmt.Sprintf("%v", val)
+				if !slices.ContainsFunc(attr.Enum, func(e any) bool {
+					return fmt.Sprintf("%v", e) == valStr
+				}) {
+					return fmt.Errorf("%q \"default\" value (%v) must be "+
+						"one of the \"enum\" values", path.UI(), val)
+				}
+			}
+		}
+
+		if err := attr.VerifyConstraints(path); err != nil {
+			return err
+		}
+
+		if attr.OnDelete != "" && attr.OnDelete != ONDELETE_BLOCK &&
+			attr.OnDelete != ONDELETE_CASCADE {
+			return fmt.Errorf("%q has an invalid \"ondelete\" value (%s), "+
+				"must be one of: %s, %s", path.UI(), attr.OnDelete,
+				ONDELETE_BLOCK, ONDELETE_CASCADE)
 		}
 
 		// Object doesn't need an Item, but maps and arrays do
@@ -2054,7 +2171,8 @@ var DefinedTypes = map[string]bool{
 	OBJECT:    true,
 	STRING:    true,
 	TIMESTAMP: true,
-	URI:       true, URI_REFERENCE: true, URI_TEMPLATE: true, URL: true}
+	URI:       true, URI_REFERENCE: true, URI_TEMPLATE: true, URL: true,
+	XID: true}
 
 // attr.Type must be a scalar
 // Used to check JSON type vs our types
@@ -2173,6 +2291,8 @@ func RegisterModelSerializer(name string, sm ModelSerializer) {
 
 func init() {
 	RegisterModelSerializer(XREGSCHEMA+"/"+SPECVERSION, Model2xRegistryJson)
+	RegisterModelSerializer(JSONSCHEMA+"/"+JSONSCHEMA_DRAFT, Model2JSONSchema)
+	RegisterModelSerializer(OPENAPI+"/"+OPENAPI_VERSION, Model2OpenAPI)
 }
 
 func AbstractToModels(reg *Registry, abs string) (*GroupModel, *ResourceModel) {
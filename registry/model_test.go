@@ -50,6 +50,13 @@ func TestModelVerifySimple(t *testing.T) {
 				},
 			},
 		}, ""},
+
+		{"reserved group name", Model{
+			Groups: map[string]*GroupModel{
+				"retention": &GroupModel{Plural: "retention",
+					Singular: "retention1"},
+			},
+		}, `Group name "retention" is reserved`},
 	}
 
 	for _, test := range tests {
@@ -109,6 +116,16 @@ func TestModelVerifyRegAttr(t *testing.T) {
 			Attributes: Attributes{"x": {Name: "x", Type: URI_TEMPLATE}}}, ``},
 		{"type - url", Model{
 			Attributes: Attributes{"x": {Name: "x", Type: URL}}}, ``},
+		{"type - xid", Model{
+			Attributes: Attributes{"x": {Name: "x", Type: XID}}}, ``},
+		{"type - xid - ondelete", Model{
+			Attributes: Attributes{"x": {Name: "x", Type: XID,
+				OnDelete: ONDELETE_CASCADE}}}, ``},
+		{"err - ondelete", Model{
+			Attributes: Attributes{"x": {Name: "x", Type: XID,
+				OnDelete: "ignore"}}},
+			`"model.x" has an invalid "ondelete" value (ignore), must be ` +
+				`one of: block, cascade`},
 		{"type - any", Model{
 			Attributes: Attributes{"x": {Name: "x", Type: ANY}}}, ``},
 		{"type - any", Model{
@@ -151,6 +168,26 @@ func TestModelVerifyRegAttr(t *testing.T) {
 			Attributes: Attributes{"x": {Name: "x", Type: "url",
 				Default: "xxx"}}},
 			`"model.x" must have "serverrequired" since a "default" value is provided`},
+		{"type - default in enum", Model{
+			Attributes: Attributes{"x": {Name: "x", Type: STRING,
+				ServerRequired: true, Default: "b",
+				Enum: []any{"a", "b"}}}}, ""},
+		{"type - default not in enum", Model{
+			Attributes: Attributes{"x": {Name: "x", Type: STRING,
+				ServerRequired: true, Default: "c",
+				Enum: []any{"a", "b"}}}},
+			`"model.x" "default" value (c) must be one of the "enum" values`},
+		{"type - default not in enum - not strict", Model{
+			Attributes: Attributes{"x": {Name: "x", Type: STRING,
+				ServerRequired: true, Default: "c", Strict: PtrBool(false),
+				Enum: []any{"a", "b"}}}}, ""},
+		{"type - default in ifvalues", Model{
+			Attributes: Attributes{"x": {Name: "x", Type: STRING,
+				IfValues: IfValues{"a": &IfValue{
+					SiblingAttributes: Attributes{"y": {Name: "y",
+						Type: INTEGER, ServerRequired: true,
+						Default: "one"}}}}}}},
+			`"model.x.ifvalues.a.y" "default" value must be of type "integer"`},
 
 		// Now some Item stuff
 		{"Item - missing", Model{
@@ -332,6 +369,113 @@ func TestModelVerifyEnum(t *testing.T) {
 	}
 }
 
+func TestModelVerifyConstraints(t *testing.T) {
+	type Test struct {
+		name  string
+		model Model
+		err   string
+	}
+
+	tests := []Test{
+		{"pattern - string", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Pattern: "^[a-z]+$"}}}, ""},
+		{"pattern - url", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: URL, Pattern: "^https:"}}}, ""},
+		{"pattern - int", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: INTEGER, Pattern: "^[a-z]+$"}}},
+			`"model.x" is not a string, so "pattern" is not allowed`},
+		{"pattern - bad", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Pattern: "[a-z"}}},
+			`"model.x" has an invalid "pattern" value ([a-z): error ` +
+				"parsing regexp: missing closing ]: `[a-z`"},
+
+		{"length - ok", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, MinLength: PtrInt(1),
+				MaxLength: PtrInt(1)}}}, ""},
+		{"length - bool", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: BOOLEAN, MaxLength: PtrInt(1)}}},
+			`"model.x" is not a string, so "maxlength" is not allowed`},
+		{"length - negative", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, MinLength: PtrInt(-1)}}},
+			`"model.x" "minlength" must be >= 0`},
+		{"length - min > max", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, MinLength: PtrInt(3),
+				MaxLength: PtrInt(2)}}},
+			`"model.x" "minlength" must be <= "maxlength"`},
+
+		{"range - ok", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: DECIMAL, Minimum: PtrFloat(-1.5),
+				Maximum: PtrFloat(1.5)}}}, ""},
+		{"range - string", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Minimum: PtrFloat(1)}}},
+			`"model.x" is not numeric, so "minimum" is not allowed`},
+		{"range - min > max", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: INTEGER, Minimum: PtrFloat(5),
+				Maximum: PtrFloat(4)}}},
+			`"model.x" "minimum" must be <= "maximum"`},
+
+		{"items - array", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: ARRAY, MinItems: PtrInt(1),
+				Item: &Item{Type: STRING}}}}, ""},
+		{"items - map", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: MAP, MaxItems: PtrInt(1),
+				Item: &Item{Type: STRING}}}}, ""},
+		{"items - object", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: OBJECT, MinItems: PtrInt(1)}}},
+			`"model.x" is not an array or map, so "minitems" is not allowed`},
+		{"items - min > max", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: ARRAY, MinItems: PtrInt(2),
+				MaxItems: PtrInt(1), Item: &Item{Type: STRING}}}},
+			`"model.x" "minitems" must be <= "maxitems"`},
+
+		{"format - ok", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Format: FORMAT_EMAIL}}}, ""},
+		{"format - int", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: INTEGER, Format: FORMAT_EMAIL}}},
+			`"model.x" is not a string, so "format" is not allowed`},
+		{"format - unknown", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Format: "phone"}}},
+			`"model.x" has an invalid "format" value (phone), must be one ` +
+				`of: date, email, hostname, ipv4, ipv6, semver, uuid`},
+
+		{"default - ok", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: INTEGER, ServerRequired: true,
+				Default: 5, Maximum: PtrFloat(5)}}}, ""},
+		{"default - out of range", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: INTEGER, ServerRequired: true,
+				Default: 6, Maximum: PtrFloat(5)}}},
+			`"model.x" "default" value (6) is invalid: Attribute "model.x" ` +
+				`must be <= 5`},
+		{"default - bad pattern", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, ServerRequired: true,
+				Default: "ABC", Pattern: "^[a-z]+$"}}},
+			`"model.x" "default" value (ABC) is invalid: Attribute "model.x" ` +
+				`must match the pattern "^[a-z]+$"`},
+
+		{"nested - item", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: MAP, Item: &Item{Type: OBJECT,
+				Attributes: Attributes{"y": {Name: "y", Type: BOOLEAN,
+					Format: FORMAT_UUID}}}}}},
+			`"model.x.item.y" is not a string, so "format" is not allowed`},
+	}
+
+	for _, test := range tests {
+		err := test.model.Verify()
+		if test.err == "" && err != nil {
+			t.Fatalf("ModelVerify: %s - should have worked, got: %s",
+				test.name, err)
+		}
+		if test.err != "" && err == nil {
+			t.Fatalf("ModelVerify: %s - should have failed with: %s",
+				test.name, test.err)
+		}
+		if err != nil && test.err != err.Error() {
+			t.Fatalf("ModifyVerify: %s\nExp: %s\nGot: %s", test.name,
+				test.err, err.Error())
+		}
+	}
+}
+
 func TestGetModelSerializer(t *testing.T) {
 	type Match struct {
 		format string
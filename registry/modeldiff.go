@@ -0,0 +1,370 @@
+package registry
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	CHANGE_SAFE     = "safe"     // existing data is still valid
+	CHANGE_BREAKING = "breaking" // existing data might be deleted or invalid
+)
+
+type ModelChange struct {
+	Kind    string `json:"kind"`    // CHANGE_SAFE or CHANGE_BREAKING
+	Path    string `json:"path"`    // e.g. groups.dirs.attributes.size
+	Message string `json:"message"` // Human readable description
+}
+
+func (mc *ModelChange) String() string {
+	return fmt.Sprintf("%-8s %s: %s", mc.Kind, mc.Path, mc.Message)
+}
+
+// Returns the list of changes needed to go from "oldM" to "newM", sorted by
+// path. Only changes that might impact users of the Registry are included,
+// e.g. a new "description" is ignored.
+func DiffModels(oldM *Model, newM *Model) []*ModelChange {
+	changes := []*ModelChange{}
+	add := func(kind string, path string, format string, args ...any) {
+		changes = append(changes, &ModelChange{
+			Kind:    kind,
+			Path:    path,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	DiffAttributes(oldM.Attributes, newM.Attributes, "attributes", add)
+
+	// Index the old types by the names they'll have after any
+	// MIGRATE_RENAME_TYPE migrations so that they're compared to the right
+	// new ones rather than being seen as removed
+	oldGMs := map[string]*GroupModel{}
+	oldRMs := map[string]map[string]*ResourceModel{} // GROUPS->RESOURCES
+	oldNames := map[string]string{}                  // new name->old name
+	for gmName, gm := range oldM.Groups {
+		newName := RenamedType(newM.Migrations, gmName)
+		oldGMs[newName] = gm
+		oldNames[newName] = gmName
+
+		for rmName, rm := range gm.Resources {
+			name := gmName + "/" + rmName
+			newName := RenamedType(newM.Migrations, name)
+			newGMName, newRMName, _ := strings.Cut(newName, "/")
+			if oldRMs[newGMName] == nil {
+				oldRMs[newGMName] = map[string]*ResourceModel{}
+			}
+			oldRMs[newGMName][newRMName] = rm
+			oldNames[newName] = name
+		}
+	}
+
+	for _, gmName := range SortedKeys(MergeMaps(oldGMs, newM.Groups)) {
+		path := "groups." + gmName
+		oldGM, newGM := oldGMs[gmName], newM.Groups[gmName]
+
+		if newGM == nil {
+			add(CHANGE_BREAKING, path, "Group type removed, all of its "+
+				"Groups will be deleted")
+			continue
+		}
+		if oldGM == nil {
+			add(CHANGE_SAFE, path, "Group type added")
+		} else {
+			if oldNames[gmName] != gmName {
+				add(CHANGE_SAFE, path, "Group type renamed from %q",
+					oldNames[gmName])
+			}
+			DiffAttributes(oldGM.Attributes, newGM.Attributes,
+				path+".attributes", add)
+		}
+
+		for _, rmName := range SortedKeys(MergeMaps(oldRMs[gmName],
+			newGM.Resources)) {
+
+			path := path + ".resources." + rmName
+			oldRM, newRM := oldRMs[gmName][rmName], newGM.Resources[rmName]
+
+			if oldRM == nil {
+				// Resources of new Group types are implied by the Group
+				if oldGM != nil {
+					add(CHANGE_SAFE, path, "Resource type added")
+				}
+				continue
+			}
+			if newRM == nil {
+				add(CHANGE_BREAKING, path, "Resource type removed, all of "+
+					"its Resources will be deleted")
+				continue
+			}
+
+			// Don't bother saying so if it's just due to its Group type
+			// being renamed
+			oldName := oldNames[gmName+"/"+rmName]
+			if oldName != oldNames[gmName]+"/"+rmName {
+				add(CHANGE_SAFE, path, "Resource type renamed from %q",
+					oldName)
+			}
+
+			// 0 means unlimited
+			oldMax, newMax := oldRM.MaxVersions, newRM.MaxVersions
+			if oldMax != newMax {
+				if newMax != 0 && (oldMax == 0 || newMax < oldMax) {
+					add(CHANGE_BREAKING, path+".maxversions", "Lowered from "+
+						"%s to %d, extra Versions will be deleted",
+						MaxVersionsString(oldMax), newMax)
+				} else {
+					add(CHANGE_SAFE, path+".maxversions", "Raised from %d "+
+						"to %s", oldMax, MaxVersionsString(newMax))
+				}
+			}
+
+			if oldRM.GetHasDocument() != newRM.GetHasDocument() {
+				add(CHANGE_BREAKING, path+".hasdocument", "Changed from %t "+
+					"to %t", oldRM.GetHasDocument(), newRM.GetHasDocument())
+			}
+
+			DiffAttributes(oldRM.Attributes, newRM.Attributes,
+				path+".attributes", add)
+		}
+	}
+
+	slices.SortStableFunc(changes, func(a, b *ModelChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return changes
+}
+
+func MaxVersionsString(maxVersions int) string {
+	if maxVersions == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d", maxVersions)
+}
+
+func HasBreakingChanges(changes []*ModelChange) bool {
+	return slices.ContainsFunc(changes, func(mc *ModelChange) bool {
+		return mc.Kind == CHANGE_BREAKING
+	})
+}
+
+// Returns a new map with the keys of both maps, the values of "m2" win
+func MergeMaps[M ~map[string]V, V any](m1 M, m2 M) M {
+	res := maps.Clone(m1)
+	if res == nil {
+		res = M{}
+	}
+	maps.Copy(res, m2)
+	return res
+}
+
+type addChangeFn func(kind string, path string, format string, args ...any)
+
+func DiffAttributes(oldAttrs Attributes, newAttrs Attributes, path string,
+	add addChangeFn) {
+
+	for _, name := range SortedKeys(MergeMaps(oldAttrs, newAttrs)) {
+		oldAttr, newAttr := oldAttrs[name], newAttrs[name]
+		path := path + "." + name
+
+		if oldAttr == nil {
+			if newAttr.ClientRequired && IsNil(newAttr.Default) {
+				add(CHANGE_BREAKING, path, "Required attribute added")
+			} else {
+				add(CHANGE_SAFE, path, "Attribute added")
+			}
+			continue
+		}
+
+		if newAttr == nil {
+			if newAttrs["*"] != nil {
+				add(CHANGE_SAFE, path, "Attribute removed, existing values "+
+					`will be checked against "*"`)
+			} else {
+				add(CHANGE_BREAKING, path, "Attribute removed, existing "+
+					"values will be invalid")
+			}
+			continue
+		}
+
+		DiffAttribute(oldAttr, newAttr, path, add)
+	}
+}
+
+func DiffAttribute(oldAttr *Attribute, newAttr *Attribute, path string,
+	add addChangeFn) {
+
+	if !oldAttr.ClientRequired && newAttr.ClientRequired {
+		if IsNil(newAttr.Default) {
+			add(CHANGE_BREAKING, path, "Attribute is now required")
+		} else {
+			add(CHANGE_SAFE, path, "Attribute is now required, missing "+
+				"values will use the default")
+		}
+	}
+
+	if DiffType(oldAttr.Type, oldAttr.Item, oldAttr.Attributes,
+		newAttr.Type, newAttr.Item, newAttr.Attributes, path, add) {
+		// No need to check the enums of different types
+		return
+	}
+
+	// A non-strict enum allows anything, so only strict ones restrict
+	if newAttr.GetStrict() && len(newAttr.Enum) > 0 {
+		if len(oldAttr.Enum) == 0 || !oldAttr.GetStrict() {
+			add(CHANGE_BREAKING, path+".enum", "Values are now limited to: %s",
+				EnumString(newAttr.Enum))
+		} else if removed := EnumMissing(oldAttr.Enum, newAttr.Enum); len(removed) > 0 {
+			add(CHANGE_BREAKING, path+".enum", "Values removed: %s",
+				EnumString(removed))
+		}
+	}
+
+	for _, ifValue := range SortedKeys(MergeMaps(oldAttr.IfValues,
+		newAttr.IfValues)) {
+
+		oldIf, newIf := oldAttr.IfValues[ifValue], newAttr.IfValues[ifValue]
+		oldSiblings, newSiblings := Attributes(nil), Attributes(nil)
+		if oldIf != nil {
+			oldSiblings = oldIf.SiblingAttributes
+		}
+		if newIf != nil {
+			newSiblings = newIf.SiblingAttributes
+		}
+		DiffAttributes(oldSiblings, newSiblings,
+			path+".ifvalues."+ifValue+".siblingattributes", add)
+	}
+}
+
+// Returns true if the types are different. Maps and arrays are only the
+// same if their items are, and objects are checked attribute by attribute.
+func DiffType(oldType string, oldItem *Item, oldAttrs Attributes,
+	newType string, newItem *Item, newAttrs Attributes, path string,
+	add addChangeFn) bool {
+
+	if oldType != newType {
+		if newType == ANY {
+			add(CHANGE_SAFE, path+".type", "Changed from %q to %q",
+				oldType, newType)
+		} else {
+			add(CHANGE_BREAKING, path+".type", "Changed from %q to %q",
+				oldType, newType)
+		}
+		return true
+	}
+
+	switch newType {
+	case ARRAY, MAP:
+		if oldItem == nil || newItem == nil {
+			return false
+		}
+		return DiffType(oldItem.Type, oldItem.Item, oldItem.Attributes,
+			newItem.Type, newItem.Item, newItem.Attributes, path+".item", add)
+	case OBJECT:
+		DiffAttributes(oldAttrs, newAttrs, path+".attributes", add)
+	}
+
+	return false
+}
+
+// Returns the values in "oldEnum" that aren't in "newEnum"
+func EnumMissing(oldEnum []any, newEnum []any) []any {
+	missing := []any{}
+	for _, oldVal := range oldEnum {
+		if !slices.ContainsFunc(newEnum, func(newVal any) bool {
+			return fmt.Sprintf("%v", oldVal) == fmt.Sprintf("%v", newVal)
+		}) {
+			missing = append(missing, oldVal)
+		}
+	}
+	return missing
+}
+
+func EnumString(enum []any) string {
+	strs := []string{}
+	for _, val := range enum {
+		strs = append(strs, fmt.Sprintf("%v", val))
+	}
+	return strings.Join(strs, ", ")
+}
+
+// Checks the entities of a Registry against "newM". "data" is the
+// serialization of the Registry, with everything inlined, as it was created
+// under "oldM". Entities whose Group or Resource type isn't in "newM", even
+// after any MIGRATE_RENAME_TYPE migrations, are skipped since they'll be
+// deleted rather than becoming invalid. There's no DB behind "data" so
+// checks that need one, like whether an xid's target exists, are skipped.
+// Returns one error per invalid entity, prefixed with its path.
+func ValidateRegistryData(oldM *Model, newM *Model, data map[string]any) []error {
+	reg := &Registry{Model: newM}
+	errs := []error{}
+
+	validate := func(path string, level int, abs string, id string,
+		obj map[string]any, colls []string) {
+
+		obj = maps.Clone(obj)
+		for _, coll := range colls {
+			delete(obj, coll)
+			delete(obj, coll+"url")
+			delete(obj, coll+"count")
+		}
+
+		e := &Entity{
+			Registry:  reg,
+			Level:     level,
+			Abstract:  abs,
+			UID:       id,
+			Object:    obj,
+			NewObject: obj,
+			EpochSet:  true, // don't compare epochs
+		}
+		if err := e.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", path, err))
+		}
+	}
+
+	validate("/", 0, "", "", data, SortedKeys(oldM.Groups))
+
+	for _, gmName := range SortedKeys(oldM.Groups) {
+		newGMName := RenamedType(newM.Migrations, gmName)
+		if newM.Groups[newGMName] == nil {
+			continue
+		}
+		gm := oldM.Groups[gmName]
+		groups, _ := data[gmName].(map[string]any)
+
+		for _, gID := range SortedKeys(groups) {
+			group, _ := groups[gID].(map[string]any)
+			gPath := "/" + gmName + "/" + gID
+			validate(gPath, 1, newGMName, gID, group, SortedKeys(gm.Resources))
+
+			for _, rmName := range SortedKeys(gm.Resources) {
+				newGMName, newRMName, _ := strings.Cut(
+					RenamedType(newM.Migrations, gmName+"/"+rmName), "/")
+				if newM.Groups[newGMName] == nil ||
+					newM.Groups[newGMName].Resources[newRMName] == nil {
+					continue
+				}
+				abs := NewPPP(newGMName).P(newRMName).Abstract()
+				resources, _ := group[rmName].(map[string]any)
+
+				// Resources are just their default Version plus a few
+				// Resource-level attributes, so only check the Versions
+				// (Entity.Validate() skips Resources anyway)
+				for _, rID := range SortedKeys(resources) {
+					resource, _ := resources[rID].(map[string]any)
+					versions, _ := resource["versions"].(map[string]any)
+					for _, vID := range SortedKeys(versions) {
+						version, _ := versions[vID].(map[string]any)
+						validate(gPath+"/"+rmName+"/"+rID+"/versions/"+vID,
+							3, abs, vID, version, nil)
+					}
+				}
+			}
+		}
+	}
+
+	return errs
+}
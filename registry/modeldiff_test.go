@@ -0,0 +1,230 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func diffTestModel() *Model {
+	return &Model{
+		Attributes: Attributes{
+			"owner": {Name: "owner", Type: STRING},
+		},
+		Groups: map[string]*GroupModel{
+			"dirs": {Plural: "dirs", Singular: "dir",
+				Attributes: Attributes{
+					"size": {Name: "size", Type: INTEGER},
+				},
+				Resources: map[string]*ResourceModel{
+					"files": {Plural: "files", Singular: "file",
+						MaxVersions: 5,
+						Attributes: Attributes{
+							"format": {Name: "format", Type: STRING,
+								Enum: []any{"json", "xml"}},
+							"meta": {Name: "meta", Type: OBJECT,
+								Attributes: Attributes{
+									"level": {Name: "level", Type: INTEGER},
+								}},
+						}},
+					"logs": {Plural: "logs", Singular: "log"},
+				}},
+			"olds": {Plural: "olds", Singular: "old"},
+		},
+	}
+}
+
+func TestDiffModels(t *testing.T) {
+	oldM := diffTestModel()
+	newM := diffTestModel()
+
+	if changes := DiffModels(oldM, newM); len(changes) != 0 {
+		t.Fatalf("Should be no changes, got: %v", changes)
+	}
+
+	// Registry
+	newM.Attributes["owner"].ClientRequired = true
+	newM.Attributes["name2"] = &Attribute{Name: "name2", Type: STRING}
+
+	// Groups
+	delete(newM.Groups, "olds")
+	newM.Groups["news"] = &GroupModel{Plural: "news", Singular: "new"}
+	newM.Groups["dirs"].Attributes["size"].Type = STRING
+
+	// Resources
+	files := newM.Groups["dirs"].Resources["files"]
+	files.MaxVersions = 3
+	files.Attributes["format"].Enum = []any{"json"}
+	files.Attributes["meta"].Attributes["level"].Type = ANY
+	files.Attributes["req"] = &Attribute{Name: "req", Type: STRING,
+		ClientRequired: true}
+	files.Attributes["req2"] = &Attribute{Name: "req2", Type: STRING,
+		ClientRequired: true, Default: "x"}
+	delete(newM.Groups["dirs"].Resources, "logs")
+
+	changes := DiffModels(oldM, newM)
+	got := []string{}
+	for _, change := range changes {
+		got = append(got, change.String())
+	}
+
+	exp := []string{
+		`safe     attributes.name2: Attribute added`,
+		`breaking attributes.owner: Attribute is now required`,
+		`breaking groups.dirs.attributes.size.type: Changed from "integer" to "string"`,
+		`breaking groups.dirs.resources.files.attributes.format.enum: ` +
+			`Values removed: xml`,
+		`safe     groups.dirs.resources.files.attributes.meta.attributes.level.type: ` +
+			`Changed from "integer" to "any"`,
+		`breaking groups.dirs.resources.files.attributes.req: Required attribute added`,
+		`safe     groups.dirs.resources.files.attributes.req2: Attribute added`,
+		`breaking groups.dirs.resources.files.maxversions: Lowered from 5 ` +
+			`to 3, extra Versions will be deleted`,
+		`breaking groups.dirs.resources.logs: Resource type removed, all of ` +
+			`its Resources will be deleted`,
+		`safe     groups.news: Group type added`,
+		`breaking groups.olds: Group type removed, all of its Groups will ` +
+			`be deleted`,
+	}
+
+	if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("Exp:\n%s\nGot:\n%s", strings.Join(exp, "\n"),
+			strings.Join(got, "\n"))
+	}
+	if !HasBreakingChanges(changes) {
+		t.Fatalf("Should have breaking changes")
+	}
+}
+
+func TestDiffModelsRenameTypes(t *testing.T) {
+	oldM := diffTestModel()
+	newM := diffTestModel()
+
+	// Rename "dirs" and move its "logs" to "olds" as "events"
+	newM.Groups["folders"] = newM.Groups["dirs"]
+	newM.Groups["folders"].Plural = "folders"
+	delete(newM.Groups, "dirs")
+	newM.Groups["olds"].Resources = map[string]*ResourceModel{
+		"events": newM.Groups["folders"].Resources["logs"],
+	}
+	delete(newM.Groups["folders"].Resources, "logs")
+	newM.Migrations = []*Migration{
+		{Op: "renametype", Name: "dirs", NewName: "folders"},
+		{Op: "renametype", Name: "folders/logs", NewName: "olds/events"},
+	}
+
+	changes := DiffModels(oldM, newM)
+	got := []string{}
+	for _, change := range changes {
+		got = append(got, change.String())
+	}
+
+	exp := []string{
+		`safe     groups.folders: Group type renamed from "dirs"`,
+		`safe     groups.olds.resources.events: Resource type renamed ` +
+			`from "dirs/logs"`,
+	}
+
+	if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("Exp:\n%s\nGot:\n%s", strings.Join(exp, "\n"),
+			strings.Join(got, "\n"))
+	}
+}
+
+func TestDiffModelsEnums(t *testing.T) {
+	type Test struct {
+		oldEnum   []any
+		oldStrict bool
+		newEnum   []any
+		newStrict bool
+		exp       string
+	}
+
+	tests := []Test{
+		{nil, true, []any{"a"}, true, "breaking x.enum: Values are now limited to: a"},
+		{nil, true, []any{"a"}, false, ""},
+		{[]any{"a"}, false, []any{"a"}, true, "breaking x.enum: Values are now limited to: a"},
+		{[]any{"a", "b"}, true, []any{"a"}, true, "breaking x.enum: Values removed: b"},
+		{[]any{"a"}, true, []any{"a", "b"}, true, ""},
+		{[]any{"a"}, true, nil, true, ""},
+		{[]any{1, 2.0}, true, []any{1.0, 2}, true, ""},
+	}
+
+	for i, test := range tests {
+		got := ""
+		DiffAttribute(
+			&Attribute{Type: STRING, Enum: test.oldEnum,
+				Strict: PtrBool(test.oldStrict)},
+			&Attribute{Type: STRING, Enum: test.newEnum,
+				Strict: PtrBool(test.newStrict)},
+			"x",
+			func(kind string, path string, format string, args ...any) {
+				got = kind + " " + path + ": " + fmt.Sprintf(format, args...)
+			})
+		if got != test.exp {
+			t.Fatalf("Test %d:\nExp: %s\nGot: %s", i, test.exp, got)
+		}
+	}
+}
+
+func TestValidateRegistryData(t *testing.T) {
+	oldM := diffTestModel()
+	newM := diffTestModel()
+	newM.Groups["dirs"].Attributes["size"].Type = STRING
+	newM.Groups["dirs"].Resources["files"].Attributes["format"].Enum = []any{"json"}
+	newM.Groups["dirs"].Attributes["peer"] = &Attribute{Name: "peer", Type: XID}
+	delete(newM.Groups, "olds")
+
+	for _, m := range []*Model{oldM, newM} {
+		if err := m.Verify(); err != nil {
+			t.Fatalf("Verify: %s", err)
+		}
+	}
+
+	data := map[string]any{
+		"specversion": SPECVERSION,
+		"id":          "reg",
+		"epoch":       1.0,
+		"owner":       "me",
+		"dirs": map[string]any{
+			"d1": map[string]any{"id": "d1", "epoch": 1.0, "size": "big",
+				"files": map[string]any{
+					"f1": map[string]any{"id": "f1",
+						"versions": map[string]any{
+							"v1": map[string]any{"id": "v1", "epoch": 1.0,
+								"format": "json"},
+							"v2": map[string]any{"id": "v2", "epoch": 1.0,
+								"format": "xml"},
+						},
+						"versionscount": 2.0,
+					},
+				},
+				"filesurl":   "http://localhost/dirs/d1/files",
+				"filescount": 1.0,
+			},
+			"d2": map[string]any{"id": "d2", "epoch": 1.0, "size": 5.0},
+			"d3": map[string]any{"id": "d3", "epoch": 1.0, "peer": "/dirs/d1"},
+			"d4": map[string]any{"id": "d4", "epoch": 1.0, "peer": "dirs"},
+		},
+		"dirscount": 4.0,
+		"olds":      map[string]any{"o1": map[string]any{"id": "o1"}},
+		"oldscount": 1.0,
+	}
+
+	errs := ValidateRegistryData(oldM, newM, data)
+	got := []string{}
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+
+	exp := []string{
+		`/dirs/d1/files/f1/versions/v2: Attribute "format"(xml) must be one of the enum values: json`,
+		`/dirs/d2: Attribute "size" must be a string`,
+		`/dirs/d4: Attribute "peer" has an invalid xid (dirs), must be of the form /GROUPS/gID[/RESOURCES/rID[/versions/vID]]`,
+	}
+
+	if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("Exp:\n%s\nGot:\n%s", strings.Join(exp, "\n"),
+			strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/duglin/dlog"
+)
+
+// An immutable copy of the model as it was at the end of a Tx that changed
+// it. Each one gets the next "epoch", starting at 1 when the Registry is
+// created.
+type ModelRevision struct {
+	Epoch     int    `json:"epoch"`
+	CreatedBy string `json:"createdby,omitempty"` // Tx.User
+	CreatedAt string `json:"createdat"`
+	Model     *Model `json:"model,omitempty"`
+}
+
+// Saves the current model as a revision. Only one revision is created per
+// Tx, so if the model is saved more than once in the same Tx then the
+// revision created by the first save is just updated.
+func (m *Model) SaveRevision() error {
+	tx := m.Registry.tx
+
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if tx.ModelRevision != 0 {
+		return Do(tx, `
+			UPDATE ModelRevisions SET Model=?, CreatedBy=?, CreatedAt=?
+			WHERE RegistrySID=? AND Revision=?`,
+			string(buf), tx.User, tx.CreateTime,
+			m.Registry.DbSID, tx.ModelRevision)
+	}
+
+	epoch, err := m.Registry.GetModelEpoch()
+	if err != nil {
+		return err
+	}
+	epoch++
+
+	err = DoOne(tx, `
+		INSERT INTO ModelRevisions(RegistrySID, Revision, CreatedBy,
+		    CreatedAt, Model)
+		VALUES(?,?,?,?,?)`,
+		m.Registry.DbSID, epoch, tx.User, tx.CreateTime, string(buf))
+	if err != nil {
+		log.Printf("Error saving model revision(%d): %s", epoch, err)
+		return err
+	}
+
+	tx.ModelRevision = epoch
+	return nil
+}
+
+// Returns the epoch of the latest model revision, 0 if there isn't one
+func (reg *Registry) GetModelEpoch() (int, error) {
+	results, err := Query(reg.tx, `
+		SELECT Revision FROM ModelRevisions
+		WHERE RegistrySID=? ORDER BY Revision DESC LIMIT 1`,
+		reg.DbSID)
+	defer results.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	row := results.NextRow()
+	if row == nil {
+		return 0, nil
+	}
+	return NotNilInt(row[0]), nil
+}
+
+// Returns all of the model revisions, oldest first, without the models
+func (reg *Registry) GetModelRevisions() ([]*ModelRevision, error) {
+	results, err := Query(reg.tx, `
+		SELECT Revision, CreatedBy, CreatedAt FROM ModelRevisions
+		WHERE RegistrySID=? ORDER BY Revision`,
+		reg.DbSID)
+	defer results.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	revs := []*ModelRevision{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		revs = append(revs, &ModelRevision{
+			Epoch:     NotNilInt(row[0]),
+			CreatedBy: NotNilString(row[1]),
+			CreatedAt: NotNilString(row[2]),
+		})
+	}
+
+	return revs, nil
+}
+
+// Returns the model revision with the specified epoch, nil if not found
+func (reg *Registry) GetModelRevision(epoch int) (*ModelRevision, error) {
+	results, err := Query(reg.tx, `
+		SELECT CreatedBy, CreatedAt, Model FROM ModelRevisions
+		WHERE RegistrySID=? AND Revision=?`,
+		reg.DbSID, epoch)
+	defer results.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	row := results.NextRow()
+	if row == nil {
+		return nil, nil
+	}
+
+	rev := &ModelRevision{
+		Epoch:     epoch,
+		CreatedBy: NotNilString(row[0]),
+		CreatedAt: NotNilString(row[1]),
+		Model:     &Model{},
+	}
+	if err := Unmarshal([]byte(NotNilString(row[2])), rev.Model); err != nil {
+		return nil, fmt.Errorf("Error parsing model revision %d: %s",
+			epoch, err)
+	}
+	rev.Model.Registry = reg
+
+	return rev, nil
+}
+
+// Re-applies the model from an earlier revision. It goes through
+// ApplyNewModel so it's fully validated, and it creates a new revision
+// rather than removing the newer ones.
+func (reg *Registry) RollbackModel(epoch int) error {
+	rev, err := reg.GetModelRevision(epoch)
+	if err != nil {
+		return err
+	}
+	if rev == nil {
+		return fmt.Errorf("Model revision %d not found", epoch)
+	}
+
+	return reg.Model.ApplyNewModel(rev.Model)
+}
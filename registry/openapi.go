@@ -0,0 +1,385 @@
+package registry
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// The model serializer for the "openapi" format. It generates an OpenAPI
+// document that describes the HTTP API of a Registry with this model. The
+// entity schemas are the same as the "jsonschema" ones (OpenAPI 3.1 uses
+// JSON Schema draft 2020-12), they're just under "components" instead.
+func Model2OpenAPI(m *Model, format string) ([]byte, error) {
+	ref := func(name string) map[string]any {
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	regRef := ref("registry")
+	modelSchema := map[string]any{"type": "object"}
+
+	paths := map[string]map[string]any{}
+
+	paths["/"] = map[string]any{
+		"get": OpenAPIOp("getRegistry", "Get the Registry",
+			[]string{"inline", "filter", "labels", "deprecated"},
+			nil, regRef, "200"),
+		"put": OpenAPIOp("putRegistry", "Update the Registry",
+			[]string{"nested"}, regRef, regRef, "200"),
+		"patch": OpenAPIOp("patchRegistry", "Update some of the Registry's "+
+			"attributes", []string{"nested"}, regRef, regRef, "200"),
+	}
+
+	paths["/model"] = map[string]any{
+		"get": OpenAPIOp("getModel", "Get the Registry's model",
+			[]string{"schema", "revision"}, nil, modelSchema, "200"),
+		"put": OpenAPIOp("putModel", "Replace the Registry's model",
+			[]string{"dryrun"}, modelSchema, modelSchema, "200"),
+	}
+	paths["/model/history"] = map[string]any{
+		"get": OpenAPIOp("getModelHistory", "Get the list of model revisions",
+			nil, nil, map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"epoch":     map[string]any{"type": "integer"},
+						"createdby": map[string]any{"type": "string"},
+						"createdat": map[string]any{
+							"type":   "string",
+							"format": "date-time",
+						},
+					},
+				},
+			}, "200"),
+	}
+	paths["/model/rollback"] = map[string]any{
+		"post": OpenAPIOp("rollbackModel", "Re-apply an earlier revision of "+
+			"the model", []string{"revision", "dryrun"}, nil, modelSchema,
+			"200"),
+	}
+
+	for _, gmName := range SortedKeys(m.Groups) {
+		gm := m.Groups[gmName]
+		gName := OpenAPIName(gm.Singular)
+		gRef := ref(GroupSchemaName(gm))
+		gPath := "/" + gm.Plural + "/{groupid}"
+
+		paths["/"+gm.Plural] = OpenAPICollection(OpenAPIName(gm.Plural),
+			gm.Plural, gRef)
+		paths[gPath] = OpenAPIEntity(gName, gm.Singular, gRef, 1, false)
+
+		for _, rmName := range SortedKeys(gm.Resources) {
+			rm := gm.Resources[rmName]
+			rName := gName + OpenAPIName(rm.Singular)
+			rRef := ref(ResourceSchemaName(gm, rm))
+			vRef := ref(VersionSchemaName(gm, rm))
+			rsPath := gPath + "/" + rm.Plural
+			rPath := rsPath + "/{resourceid}"
+			vPath := rPath + "/versions/{versionid}"
+			hasDoc := rm.GetHasDocument()
+
+			rmPaths := map[string]map[string]any{
+				rsPath: OpenAPICollection(gName+OpenAPIName(rm.Plural),
+					rm.Plural, rRef),
+				rPath: OpenAPIEntity(rName, rm.Singular, rRef, 2, hasDoc),
+				rPath + "/versions": OpenAPICollection(rName+"Versions",
+					"versions", vRef),
+				vPath: OpenAPIEntity(rName+"Version", "version", vRef, 3,
+					hasDoc),
+			}
+			if hasDoc {
+				rmPaths[rPath+"$meta"] = OpenAPIEntity(rName+"Meta",
+					rm.Singular, rRef, 2, false)
+				rmPaths[vPath+"$meta"] = OpenAPIEntity(rName+"VersionMeta",
+					"version", vRef, 3, false)
+			}
+
+			for path, ops := range rmPaths {
+				// Just leave the read operations
+				if rm.ReadOnly {
+					for _, verb := range []string{"put", "post", "patch",
+						"delete"} {
+						delete(ops, verb)
+					}
+				}
+				paths[path] = ops
+			}
+		}
+	}
+
+	// Add the path parameters, e.g. "{groupid}", to each path
+	for path, ops := range paths {
+		params := []string{}
+		for _, name := range []string{"groupid", "resourceid", "versionid"} {
+			if strings.Contains(path, "{"+name+"}") {
+				params = append(params, name)
+			}
+		}
+		if len(params) > 0 {
+			ops["parameters"] = OpenAPIParamRefs(params...)
+		}
+	}
+
+	schemas := ModelSchemas(m, "#/components/schemas/")
+	schemas["error"] = map[string]any{"type": "string"}
+
+	doc := map[string]any{
+		"openapi": OPENAPI_VERSION,
+		"info": map[string]any{
+			"title":   "xRegistry",
+			"version": SPECVERSION,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas":    schemas,
+			"parameters": OpenAPIParameters,
+		},
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// Turns a singular name into something that can be used as part of an
+// operationId, e.g. "dir" -> "Dir"
+func OpenAPIName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// The query and path parameters used by the operations
+var OpenAPIParameters = map[string]any{
+	"inline": map[string]any{
+		"name":        "inline",
+		"in":          "query",
+		"description": `Nested collections to include, e.g. "*" or "dirs.files"`,
+		"schema": map[string]any{
+			"type":  "array",
+			"items": map[string]any{"type": "string"},
+		},
+	},
+	"filter": map[string]any{
+		"name":        "filter",
+		"in":          "query",
+		"description": `Only include entities that match, e.g. "dirs.name=x"`,
+		"schema": map[string]any{
+			"type":  "array",
+			"items": map[string]any{"type": "string"},
+		},
+	},
+	"labels": map[string]any{
+		"name":        "labels",
+		"in":          "query",
+		"description": `Label selector, e.g. "env in (prod,test)"`,
+		"schema": map[string]any{
+			"type":  "array",
+			"items": map[string]any{"type": "string"},
+		},
+	},
+	"deprecated": map[string]any{
+		"name":            "deprecated",
+		"in":              "query",
+		"description":     "Only include deprecated entities",
+		"allowEmptyValue": true,
+		"schema":          map[string]any{"type": "string"},
+	},
+	"nested": map[string]any{
+		"name":            "nested",
+		"in":              "query",
+		"description":     "Process the nested collections in the request",
+		"allowEmptyValue": true,
+		"schema":          map[string]any{"type": "string"},
+	},
+	"epoch": map[string]any{
+		"name":        "epoch",
+		"in":          "query",
+		"description": "Only delete the entity if its epoch matches",
+		"schema":      map[string]any{"type": "integer", "minimum": 0},
+	},
+	"setdefaultversionid": map[string]any{
+		"name":        "setdefaultversionid",
+		"in":          "query",
+		"description": `The Version to make the default, "null" to unstick it`,
+		"schema":      map[string]any{"type": "string"},
+	},
+	"revision": map[string]any{
+		"name":        "revision",
+		"in":          "query",
+		"description": "The epoch of a model revision, see /model/history",
+		"schema":      map[string]any{"type": "integer", "minimum": 1},
+	},
+	"dryrun": map[string]any{
+		"name":            "dryrun",
+		"in":              "query",
+		"description":     "Check the existing data against the new model without saving anything",
+		"allowEmptyValue": true,
+		"schema":          map[string]any{"type": "string"},
+	},
+	"schema": map[string]any{
+		"name":        "schema",
+		"in":          "query",
+		"description": `The format of the model, e.g. "jsonschema"`,
+		"schema":      map[string]any{"type": "string"},
+	},
+	"groupid": map[string]any{
+		"name":     "groupid",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]any{"type": "string"},
+	},
+	"resourceid": map[string]any{
+		"name":     "resourceid",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]any{"type": "string"},
+	},
+	"versionid": map[string]any{
+		"name":     "versionid",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]any{"type": "string"},
+	},
+}
+
+// Returns the operation object. "params" are the names of the
+// OpenAPIParameters, "reqSchema" is nil if there's no request body and
+// "resSchema" is returned for each of the success "codes".
+func OpenAPIOp(id string, summary string, params []string, reqSchema any,
+	resSchema any, codes ...string) map[string]any {
+
+	responses := map[string]any{
+		"default": OpenAPIContent("Error", map[string]any{
+			"$ref": "#/components/schemas/error"}),
+	}
+	for _, code := range codes {
+		responses[code] = OpenAPIContent("Success", resSchema)
+	}
+
+	op := map[string]any{
+		"operationId": id,
+		"summary":     summary,
+		"responses":   responses,
+	}
+
+	if len(params) > 0 {
+		op["parameters"] = OpenAPIParamRefs(params...)
+	}
+
+	if reqSchema != nil {
+		op["requestBody"] = OpenAPIContent("", reqSchema)
+		op["requestBody"].(map[string]any)["required"] = true
+	}
+
+	return op
+}
+
+func OpenAPIParamRefs(names ...string) []any {
+	list := []any{}
+	for _, name := range names {
+		list = append(list, map[string]any{
+			"$ref": "#/components/parameters/" + name})
+	}
+	return list
+}
+
+// Returns a request or response object. A nil "schema" means there's no
+// body. Errors are plain text, and an empty schema means it's a Resource's
+// document so it can be anything.
+func OpenAPIContent(desc string, schema any) map[string]any {
+	res := map[string]any{}
+	if desc != "" {
+		res["description"] = desc
+	}
+
+	if schema == nil {
+		return res
+	}
+
+	mediaType := "application/json"
+	if obj, ok := schema.(map[string]any); ok {
+		if obj["$ref"] == "#/components/schemas/error" {
+			mediaType = "text/plain"
+		} else if len(obj) == 0 {
+			mediaType = "*/*"
+		}
+	}
+	res["content"] = map[string]any{
+		mediaType: map[string]any{"schema": schema},
+	}
+	return res
+}
+
+// The operations on a collection, e.g. /GROUPS
+func OpenAPICollection(name string, plural string, ref any) map[string]any {
+	mapSchema := map[string]any{
+		"type":                 "object",
+		"additionalProperties": ref,
+	}
+	idsSchema := map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id":    map[string]any{"type": "string"},
+				"epoch": map[string]any{"type": "integer", "minimum": 0},
+			},
+			"required": []string{"id"},
+		},
+	}
+
+	ops := map[string]any{
+		"get": OpenAPIOp("list"+name, "Get the "+plural,
+			[]string{"inline", "filter", "labels", "deprecated"},
+			nil, mapSchema, "200"),
+		"post": OpenAPIOp("post"+name, "Create or update some "+plural,
+			[]string{"nested"}, mapSchema, mapSchema, "200"),
+		"delete": OpenAPIOp("delete"+name, "Delete some, or all, "+
+			"of the "+plural, nil, idsSchema, nil, "204"),
+	}
+	// No list means delete all of them
+	ops["delete"].(map[string]any)["requestBody"].(map[string]any)["required"] = false
+
+	return ops
+}
+
+// The operations on a single entity at "level" (1=Group, 2=Resource,
+// 3=Version). "hasDoc" means the body is the entity's document rather
+// than its metadata.
+func OpenAPIEntity(name string, singular string, ref any, level int,
+	hasDoc bool) map[string]any {
+
+	body := ref
+	getParams := []string{"inline", "filter"}
+	putParams := []string{"nested"}
+	if hasDoc {
+		body = map[string]any{}
+		getParams = nil
+		putParams = nil
+	}
+	delParams := []string{"epoch"}
+	if level == 3 {
+		delParams = append(delParams, "setdefaultversionid")
+	}
+
+	ops := map[string]any{
+		"get": OpenAPIOp("get"+name, "Get the "+singular, getParams,
+			nil, body, "200"),
+		"put": OpenAPIOp("put"+name, "Create or update the "+singular,
+			putParams, body, body, "200", "201"),
+		"delete": OpenAPIOp("delete"+name, "Delete the "+singular,
+			delParams, nil, nil, "204"),
+	}
+
+	if !hasDoc {
+		ops["patch"] = OpenAPIOp("patch"+name, "Update some of the "+
+			singular+"'s attributes", putParams, body, body, "200", "201")
+	}
+
+	if level == 2 {
+		ops["post"] = OpenAPIOp("post"+name, "Add a new Version to the "+
+			singular, []string{"setdefaultversionid"}, body, body,
+			"200", "201")
+	}
+
+	return ops
+}
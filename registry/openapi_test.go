@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestModel2OpenAPI(t *testing.T) {
+	m := &Model{
+		Groups: map[string]*GroupModel{
+			"dirs": {Plural: "dirs", Singular: "dir",
+				Resources: map[string]*ResourceModel{
+					"files": {Plural: "files", Singular: "file",
+						HasDocument: PtrBool(true)},
+					"infos": {Plural: "infos", Singular: "info",
+						HasDocument: PtrBool(false)},
+					"logs": {Plural: "logs", Singular: "log",
+						HasDocument: PtrBool(false), ReadOnly: true},
+				}},
+		},
+	}
+
+	buf, err := Model2OpenAPI(m, "")
+	if err != nil {
+		t.Fatalf("Model2OpenAPI: %s", err)
+	}
+
+	doc := map[string]any{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		t.Fatalf("Unmarshal: %s\n%s", err, buf)
+	}
+
+	type Test struct {
+		path string // "|"-separated path into "doc"
+		exp  string // JSON of the expected value, "" means it's not there
+	}
+
+	files := "paths|/dirs/{groupid}/files/{resourceid}"
+	infos := "paths|/dirs/{groupid}/infos/{resourceid}"
+	logs := "paths|/dirs/{groupid}/logs/{resourceid}"
+
+	tests := []Test{
+		{"openapi", `"3.1.0"`},
+		{"info|version", `"0.5"`},
+
+		{"paths|/|get|operationId", `"getRegistry"`},
+		{"paths|/|get|responses|200|content|application/json|schema", `{"$ref":"#/components/schemas/registry"}`},
+		{"paths|/|get|responses|default|content|text/plain|schema", `{"$ref":"#/components/schemas/error"}`},
+		{"paths|/|delete", ``},
+		{"paths|/|post", ``},
+		{"paths|/model|get|parameters", `[{"$ref":"#/components/parameters/schema"},{"$ref":"#/components/parameters/revision"}]`},
+		{"paths|/model|put|parameters", `[{"$ref":"#/components/parameters/dryrun"}]`},
+		{"paths|/model/history|get|operationId", `"getModelHistory"`},
+		{"paths|/model/rollback|post|parameters", `[{"$ref":"#/components/parameters/revision"},{"$ref":"#/components/parameters/dryrun"}]`},
+		{"paths|/model/rollback|post|requestBody", ``},
+
+		// Groups
+		{"paths|/dirs|get|operationId", `"listDirs"`},
+		{"paths|/dirs|get|responses|200|content|application/json|schema", `{"additionalProperties":{"$ref":"#/components/schemas/group-dirs"},"type":"object"}`},
+		{"paths|/dirs|put", ``},
+		{"paths|/dirs|delete|requestBody|required", `false`},
+		{"paths|/dirs|parameters", ``},
+		{"paths|/dirs/{groupid}|parameters", `[{"$ref":"#/components/parameters/groupid"}]`},
+		{"paths|/dirs/{groupid}|put|responses|201|content|application/json|schema", `{"$ref":"#/components/schemas/group-dirs"}`},
+		{"paths|/dirs/{groupid}|delete|parameters", `[{"$ref":"#/components/parameters/epoch"}]`},
+		{"paths|/dirs/{groupid}|post", ``},
+
+		// Resources with a document
+		{files + "|get|operationId", `"getDirFile"`},
+		{files + "|get|responses|200|content|*/*|schema", `{}`},
+		{files + "|patch", ``},
+		{files + "|post|operationId", `"postDirFile"`},
+		{files + "$meta|get|responses|200|content|application/json|schema", `{"$ref":"#/components/schemas/resource-dirs-files"}`},
+		{files + "$meta|patch|operationId", `"patchDirFileMeta"`},
+		{files + "/versions|get|operationId", `"listDirFileVersions"`},
+		{files + "/versions/{versionid}|parameters", `[{"$ref":"#/components/parameters/groupid"},{"$ref":"#/components/parameters/resourceid"},{"$ref":"#/components/parameters/versionid"}]`},
+		{files + "/versions/{versionid}|delete|parameters", `[{"$ref":"#/components/parameters/epoch"},{"$ref":"#/components/parameters/setdefaultversionid"}]`},
+		{files + "/versions/{versionid}|post", ``},
+		{files + "/versions/{versionid}$meta|get|responses|200|content|application/json|schema", `{"$ref":"#/components/schemas/version-dirs-files"}`},
+
+		// Resources without a document
+		{infos + "|get|responses|200|content|application/json|schema", `{"$ref":"#/components/schemas/resource-dirs-infos"}`},
+		{infos + "|patch|operationId", `"patchDirInfo"`},
+		{infos + "$meta", ``},
+		{infos + "/versions/{versionid}$meta", ``},
+
+		// Read-only Resources
+		{logs + "|get|operationId", `"getDirLog"`},
+		{logs + "|put", ``},
+		{logs + "|delete", ``},
+		{"paths|/dirs/{groupid}/logs|get|operationId", `"listDirLogs"`},
+		{"paths|/dirs/{groupid}/logs|post", ``},
+		{logs + "/versions|post", ``},
+
+		{"components|schemas|registry|properties|dirs|additionalProperties", `{"$ref":"#/components/schemas/group-dirs"}`},
+		{"components|schemas|error", `{"type":"string"}`},
+		{"components|parameters|versionid|in", `"path"`},
+		{"components|parameters|dryrun|in", `"query"`},
+		{"components|parameters|inline|dryrun", ``},
+	}
+
+	for _, test := range tests {
+		var val any = doc
+		for _, part := range strings.Split(test.path, "|") {
+			if obj, ok := val.(map[string]any); ok {
+				val = obj[part]
+			} else {
+				val = nil
+			}
+		}
+
+		got := ""
+		if val != nil {
+			tmp, _ := json.Marshal(val)
+			got = string(tmp)
+		}
+		if got != test.exp {
+			t.Fatalf("%s:\nExp: %s\nGot: %s", test.path, test.exp, got)
+		}
+	}
+
+	// Every "$ref" must point to something in the doc
+	var checkRefs func(val any)
+	checkRefs = func(val any) {
+		switch val := val.(type) {
+		case map[string]any:
+			if ref, ok := val["$ref"].(string); ok {
+				var target any = doc
+				for _, part := range strings.Split(
+					strings.TrimPrefix(ref, "#/"), "/") {
+
+					obj, _ := target.(map[string]any)
+					target = obj[part]
+				}
+				if target == nil {
+					t.Fatalf("Unresolved $ref: %s", ref)
+				}
+			}
+			for _, v := range val {
+				checkRefs(v)
+			}
+		case []any:
+			for _, v := range val {
+				checkRefs(v)
+			}
+		}
+	}
+	checkRefs(doc)
+}
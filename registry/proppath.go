@@ -177,17 +177,17 @@ func PropPathFromDB(str string) (*PropPath, error) {
 var stateTable = [][]string{
 	// TODO: switch to a-z instead of 0-9 for state char if we need more than 10
 	// nextState + ACTIONS    nextState of '/' means stop
-	// a-z   0-9    -      _      .       [      ]     '     \0    else
-	{"1  ", "/U ", "/U ", "2BI", "/U ", "9I ", "/U ", "/U", "/U", "/U"}, // 0-nothing
-	{"2BI", "2BI", "/U ", "2BI", "/U ", "/U ", "/U ", "/U", "/U", "/U"}, // 1-strtAttr
-	{"2BI", "2BI", "2BI", "2BI", "1IS", "3IS", "/U ", "/U", "/S", "/U"}, // 2-in attr
-	{"/P ", "4BI", "/U ", "/U ", "/U ", "/U ", "/U ", "6I", "/U", "/U"}, // 3-start [
-	{"/P ", "4BI", "/U ", "/U ", "/U ", "/U ", "5IN", "/U", "/U", "/U"}, // 4-in [
-	{"/U ", "/U ", "/U ", "/U ", "1IA", "3I ", "/U ", "/U", "/ ", "/U"}, // 5-post ]
-	{"7BI", "7BI", "/U ", "/U ", "/U ", "/U ", "/U ", "/U", "/U", "/U"}, // 6-start ['
-	{"7BI", "7BI", "7BI", "7BI", "7BI", "/U ", "/U ", "8I", "/U", "/U"}, // 7-in ['
-	{"/U ", "/U ", "/U ", "/U ", "/U ", "/U ", "5IS", "8I", "/U", "/U"}, // 8-in ['..'
-	{"/Q ", "/U ", "/U ", "/U ", "/U ", "/U ", "/U ", "6I", "/U", "/U"}, // 9-str [
+	// a-z   0-9    -      _      .       [      ]     '     \0    else  *
+	{"1  ", "/U ", "/U ", "2BI", "/U ", "9I ", "/U ", "/U", "/U", "/U", "5IW"}, // 0-nothing
+	{"2BI", "2BI", "/U ", "2BI", "/U ", "/U ", "/U ", "/U", "/U", "/U", "5IW"}, // 1-strtAttr
+	{"2BI", "2BI", "2BI", "2BI", "1IS", "3IS", "/U ", "/U", "/S", "/U", "/U "}, // 2-in attr
+	{"/P ", "4BI", "/U ", "/U ", "/U ", "/U ", "/U ", "6I", "/U", "/U", "/P "}, // 3-start [
+	{"/P ", "4BI", "/U ", "/U ", "/U ", "/U ", "5IN", "/U", "/U", "/U", "/P "}, // 4-in [
+	{"/U ", "/U ", "/U ", "/U ", "1IA", "3I ", "/U ", "/U", "/ ", "/U", "/U "}, // 5-post ]
+	{"7BI", "7BI", "/U ", "/U ", "/U ", "/U ", "/U ", "/U", "/U", "/U", "/U "}, // 6-start ['
+	{"7BI", "7BI", "7BI", "7BI", "7BI", "/U ", "/U ", "8I", "/U", "/U", "/U "}, // 7-in ['
+	{"/U ", "/U ", "/U ", "/U ", "/U ", "/U ", "5IS", "8I", "/U", "/U", "/U "}, // 8-in ['..'
+	{"/Q ", "/U ", "/U ", "/U ", "/U ", "/U ", "/U ", "6I", "/U", "/U", "/U "}, // 9-str [
 }
 
 var ch2Col = map[byte]int{}
@@ -207,6 +207,7 @@ func init() {
 	ch2Col[']'] = 6
 	ch2Col['\''] = 7
 	ch2Col[0] = 8
+	ch2Col['*'] = 10
 }
 
 func MustPropPathFromUI(str string) *PropPath {
@@ -264,6 +265,11 @@ func PropPathFromUI(str string) (*PropPath, error) {
 						Index: -1,
 					})
 					buf.Reset()
+				case 'W': // wildcard part, e.g. a.*.b
+					res.Parts = append(res.Parts, PropPart{
+						Text:  "*",
+						Index: -1,
+					})
 				case 'N': // end of index(numeric) part
 					tmp, err := strconv.Atoi(buf.String())
 					if err != nil {
@@ -40,6 +40,10 @@ func TestParseUI(t *testing.T) {
 		{"a1[2]['a3']", `{[{"a1",-1},{"2",2},{"a3",-1}]}`},
 		{"a1[2][3]", `{[{"a1",-1},{"2",2},{"3",3}]}`},
 
+		{"*", `{[{"*",-1}]}`},
+		{"a1.*.a3", `{[{"a1",-1},{"*",-1},{"a3",-1}]}`},
+		{"*.*[1]", `{[{"*",-1},{"*",-1},{"1",1}]}`},
+
 		// Errors
 		{".prop", `Unexpected . in ".prop" at pos 1`},
 		{"1", `Unexpected 1 in "1" at pos 1`},
@@ -57,6 +61,12 @@ func TestParseUI(t *testing.T) {
 		{"a1[]", `Unexpected ] in "a1[]" at pos 4`},
 		{"a1['']", `Unexpected ' in "a1['']" at pos 5`},
 		{"a1[']", `Unexpected ] in "a1[']" at pos 5`},
+
+		{"a*", `Unexpected * in "a*" at pos 2`},
+		{"a.*b", `Unexpected b in "a.*b" at pos 4`},
+		{"a.*.", `Unexpected end of property in "a.*."`},
+		{"a[*]", `Expecting an integer at pos 3 in "a[*]"`},
+		{"a['*']", `Unexpected * in "a['*']" at pos 4`},
 	}
 
 	for _, test := range tests {
@@ -594,20 +594,36 @@ eSID IN ( -- eSID from query
 				}
 				firstAnd = false
 				check := ""
-				args = append(args, reg.DbSID, filter.Path)
+				checkArgs := []any{reg.DbSID, filter.Path}
 				if filter.HasEqual {
-					args = append(args, filter.Value)
+					checkArgs = append(checkArgs, filter.Value)
 					check = "PropValue=?"
 				} else {
 					check = "PropValue IS NOT NULL"
 				}
 				// BINARY means case-sensitive for that operand
-				query += `
-          SELECT eSID,Path FROM FullTree
-          WHERE
-            RegSID=? AND
+				match := `RegSID=? AND
             (BINARY CONCAT(IF(Abstract<>'',CONCAT(Abstract,'` + string(DB_IN) + `'),''),PropName)=? AND
                ` + check + `)`
+
+				if !filter.Negate {
+					args = append(args, checkArgs...)
+					query += `
+          SELECT eSID,Path FROM FullTree
+          WHERE
+            ` + match
+				} else {
+					// All entities of that type except the ones that match
+					args = append(args, reg.DbSID, filter.Abstract)
+					args = append(args, checkArgs...)
+					query += `
+          SELECT eSID,Path FROM Entities
+          WHERE
+            RegSID=? AND Abstract=? AND eSID NOT IN (
+              SELECT eSID FROM FullTree
+              WHERE
+            ` + match + `)`
+				}
 			} // end of AndFilter
 			query += `
           -- end of expr1
@@ -2,9 +2,13 @@ package registry
 
 import (
 	"fmt"
+	"maps"
+	"regexp"
 	"strconv"
+	"time"
 
 	log "github.com/duglin/dlog"
+	"github.com/google/uuid"
 )
 
 type Resource struct {
@@ -19,6 +23,19 @@ var specialResourceAttrs = map[string]bool{
 	"defaultversionid":     true,
 	"stickydefaultversion": true,
 	"#nextversionid":       true,
+	"versiontags":          true,
+	"deprecated":           true,
+}
+
+var RegexpVersionTag = regexp.MustCompile("^[a-z0-9][a-z0-9_\\-]{0,62}$")
+
+// Version tags are named pointers to Versions, stored on the Resource.
+// They're not part of the model since they only apply to Resources, so
+// they're added to the Resource's attributes on the fly.
+var VersionTagsAttr = &Attribute{
+	Name: "versiontags",
+	Type: MAP,
+	Item: &Item{Type: STRING},
 }
 
 // Remove any attributes that appear on Resources but not Versions.
@@ -30,6 +47,8 @@ func RemoveResourceAttributes(obj map[string]any) {
 			delete(obj, attr.Name)
 		}
 	}
+	delete(obj, VersionTagsAttr.Name)
+	delete(obj, DeprecatedAttr.Name)
 }
 
 func (r *Resource) Get(name string) any {
@@ -188,23 +207,8 @@ func (r *Resource) UpsertVersionWithObject(id string, obj Object, addType AddTyp
 
 	if id == "" {
 		// No versionID provided so grab the next available one
-		tmp := r.Get("#nextversionid")
-		nextID := NotNilInt(&tmp)
-		for {
-			id = strconv.Itoa(nextID)
-			v, err = r.FindVersion(id, false)
-			if err != nil {
-				return nil, false,
-					fmt.Errorf("Error checking for Version %q: %s", id, err)
-			}
-
-			// Increment no matter what since it's "next" not "default"
-			nextID++
-
-			if v == nil {
-				r.JustSet("#nextversionid", nextID)
-				break
-			}
+		if id, err = r.NextVersionID(); err != nil {
+			return nil, false, err
 		}
 	} else {
 		v, err = r.FindVersion(id, true)
@@ -228,6 +232,13 @@ func (r *Resource) UpsertVersionWithObject(id string, obj Object, addType AddTyp
 	// If Verson doesn't exist, create it
 	isNew := (v == nil)
 	if v == nil {
+		if err = r.ValidateVersionID(id); err != nil {
+			return nil, false, err
+		}
+		if err = r.CheckDeprecated(); err != nil {
+			return nil, false, err
+		}
+
 		v = &Version{
 			Entity: Entity{
 				tx: r.tx,
@@ -290,16 +301,56 @@ func (r *Resource) UpsertVersionWithObject(id string, obj Object, addType AddTyp
 		}
 	}
 
-	if err = v.ValidateAndSave(); err != nil {
+	if err = v.ValidateAndPrep(); err != nil {
+		return nil, false, err
+	}
+
+	// Any new document must honor the Resource's "compatibility" policy
+	// w.r.t. the current default Version. Check before it's saved.
+	issues, err := r.CheckCompatibility(v)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(issues) > 0 {
+		if !r.tx.CheckCompat {
+			_, rm := r.GetModels()
+			err = fmt.Errorf("Version %q is not %s compatible with "+
+				"Version %q: %s", v.UID, rm.GetCompatibility(),
+				r.Get("defaultversionid"), CompatIssuesString(issues, 5))
+			if isNew {
+				// Don't leave the empty Version behind
+				if dErr := v.Delete(""); dErr != nil {
+					log.Printf("Error deleting Version %q: %s", v.UID, dErr)
+				}
+			}
+			return nil, false, err
+		}
+		// Dry-run, just save the issues for the caller
+		for _, issue := range issues {
+			r.tx.CompatIssues = append(r.tx.CompatIssues,
+				fmt.Sprintf("%s: %s", v.Path, issue))
+		}
+	}
+
+	if err = v.Save(); err != nil {
 		return nil, false, err
 	}
 
 	// If we can only have one Version, then set the one we just created
 	// as the default.
 	// Also set it if we're not sticky w.r.t. default version
+	// For semver, the default is the highest version, not the newest one
 	_, rm := r.GetModels()
 	if rm.MaxVersions == 1 || (isNew && r.Get("stickydefaultversion") != true) {
-		err = r.SetSave("defaultversionid", v.UID)
+		defaultID := v.UID
+		if rm.GetVersionIdStrategy() == VERSIONID_SEMVER && rm.MaxVersions != 1 {
+			vIDs, err := r.GetVersionIDs()
+			if err != nil {
+				return nil, false, err
+			}
+			defaultID = vIDs[len(vIDs)-1]
+		}
+		err = r.SetSave("defaultversionid", defaultID)
 		if err != nil {
 			return nil, false, err
 		}
@@ -313,6 +364,136 @@ func (r *Resource) UpsertVersionWithObject(id string, obj Object, addType AddTyp
 	return v, isNew, nil
 }
 
+// Compare the new document of Version "v" (in its NewObject) with the
+// Resource's current default Version and return the list of changes that
+// violate the Resource model's "compatibility" policy. If "v" is the default
+// Version then its new document is compared with its current one. Nothing is
+// checked if the document isn't changing or either one isn't a local one.
+func (r *Resource) CheckCompatibility(v *Version) ([]string, error) {
+	_, rm := r.GetModels()
+	policy := rm.GetCompatibility()
+	if policy == COMPAT_NONE {
+		return nil, nil
+	}
+
+	newDoc := []byte(nil)
+	switch doc := v.NewObject["#resource"].(type) {
+	case []byte:
+		newDoc = doc
+	case string:
+		newDoc = []byte(doc)
+	}
+	if len(newDoc) == 0 {
+		return nil, nil
+	}
+
+	prev, err := r.GetDefault()
+	if err != nil {
+		return nil, err
+	}
+	if prev == nil {
+		return nil, nil
+	}
+
+	oldDoc, ok := prev.Get("#resource").([]byte)
+	if !ok || len(oldDoc) == 0 {
+		return nil, nil
+	}
+
+	issues, err := CheckCompatibility(policy, oldDoc, newDoc)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking compatibility of Version "+
+			"%q: %s", v.UID, err)
+	}
+	return issues, nil
+}
+
+// Generate the ID of a new Version based on the Resource model's
+// "versionidstrategy"
+func (r *Resource) NextVersionID() (string, error) {
+	_, rm := r.GetModels()
+
+	switch rm.GetVersionIdStrategy() {
+	case VERSIONID_SEMVER:
+		vIDs, err := r.GetVersionIDs()
+		if err != nil {
+			return "", err
+		}
+
+		// vIDs is sorted by semver, so the last valid one is the highest
+		latest := (*Semver)(nil)
+		for i := len(vIDs) - 1; i >= 0 && latest == nil; i-- {
+			latest, _ = ParseSemver(vIDs[i])
+		}
+
+		if latest == nil {
+			if r.tx.VersionBump == SEMVER_MAJOR || r.tx.VersionBump == "" {
+				return "1.0.0", nil
+			}
+			latest = &Semver{}
+		}
+
+		next, err := latest.Bump(r.tx.VersionBump)
+		if err != nil {
+			return "", err
+		}
+		return next.String(), nil
+	}
+
+	// Everything else just keeps generating until we get an unused one
+	tmp := r.Get("#nextversionid")
+	nextID := NotNilInt(&tmp)
+	for {
+		id := ""
+		switch rm.GetVersionIdStrategy() {
+		case VERSIONID_TIMESTAMP:
+			id = time.Now().UTC().Format(VERSIONID_TIMESTAMP_FORMAT)
+		case VERSIONID_UUID:
+			id = uuid.NewString()
+		default:
+			id = strconv.Itoa(nextID)
+		}
+
+		v, err := r.FindVersion(id, false)
+		if err != nil {
+			return "", fmt.Errorf("Error checking for Version %q: %s", id, err)
+		}
+
+		// Increment no matter what since it's "next" not "default"
+		nextID++
+
+		if v == nil {
+			if rm.GetVersionIdStrategy() == VERSIONID_COUNTER {
+				r.JustSet("#nextversionid", nextID)
+			}
+			return id, nil
+		}
+	}
+}
+
+// Make sure a Version ID matches the Resource model's "versionidstrategy"
+func (r *Resource) ValidateVersionID(id string) error {
+	_, rm := r.GetModels()
+
+	switch rm.GetVersionIdStrategy() {
+	case VERSIONID_SEMVER:
+		if _, err := ParseSemver(id); err != nil {
+			return fmt.Errorf("Invalid Version ID %q, must be a semantic "+
+				"version (e.g. 1.2.3)", id)
+		}
+	case VERSIONID_TIMESTAMP:
+		if _, err := time.Parse(VERSIONID_TIMESTAMP_FORMAT, id); err != nil {
+			return fmt.Errorf("Invalid Version ID %q, must be a timestamp "+
+				"of the form %s", id, VERSIONID_TIMESTAMP_FORMAT)
+		}
+	case VERSIONID_UUID:
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("Invalid Version ID %q, must be a UUID", id)
+		}
+	}
+	return nil
+}
+
 func (r *Resource) AddVersion(id string) (*Version, error) {
 	v, _, err := r.UpsertVersionWithObject(id, nil, ADD_ADD)
 	return v, err
@@ -339,6 +520,12 @@ func (r *Resource) GetVersionIDs() ([]string, error) {
 		vIDs = append(vIDs, NotNilString(row[0]))
 	}
 	results.Close()
+
+	// Semvers are ordered by their precedence, not by when they were created
+	if _, rm := r.GetModels(); rm.GetVersionIdStrategy() == VERSIONID_SEMVER {
+		SortSemverIDs(vIDs)
+	}
+
 	return vIDs, nil
 }
 
@@ -361,28 +548,252 @@ func (r *Resource) EnsureMaxVersions() error {
 	// Starting with the oldest, keep deleting until we reach the max
 	// number of Versions allowed. Technically, this should always just
 	// delete 1, but ya never know. Also, skip the one that's tagged
-	// as "default" since that one is special
+	// as "default" since that one is special, and any with a version tag.
+	// Versions referenced by an xid aren't pruned either.
+	tagged := map[string]bool{}
+	for _, vID := range r.GetVersionTags() {
+		tagged[vID] = true
+	}
+
 	count := len(vIDs)
-	for count > rm.MaxVersions {
-		// Skip the "default" Version
-		if vIDs[0] != defaultID {
-			err = DoOne(r.tx, `DELETE FROM Versions
-					WHERE ResourceSID=? AND UID=?`, r.DbSID, vIDs[0])
-			if err != nil {
-				return fmt.Errorf("Error deleting Version %q: %s", vIDs[0], err)
+	for count > rm.MaxVersions && len(vIDs) > 0 {
+		vID := vIDs[0]
+		vIDs = vIDs[1:]
+
+		// Skip the "default" Version and any tagged ones
+		if vID == defaultID || tagged[vID] {
+			continue
+		}
+
+		refs, err := FindXIDRefs(r.tx, r.Registry, r.Path+"/versions/"+vID)
+		if err != nil {
+			return err
+		}
+		if len(refs) > 0 {
+			continue
+		}
+
+		err = DoOne(r.tx, `DELETE FROM Versions
+				WHERE ResourceSID=? AND UID=?`, r.DbSID, vID)
+		if err != nil {
+			return fmt.Errorf("Error deleting Version %q: %s", vID, err)
+		}
+		if v := r.tx.GetVersion(r, vID); v != nil {
+			r.tx.RemoveVersion(v)
+		}
+		count--
+	}
+	return nil
+}
+
+// Returns the map of version tag -> Version ID
+func (r *Resource) GetVersionTags() map[string]string {
+	res := map[string]string{}
+	tags, ok := r.Entity.Get(VersionTagsAttr.Name).(map[string]any)
+	if !ok {
+		return res
+	}
+	for tag, vID := range tags {
+		if str, ok := vID.(string); ok {
+			res[tag] = str
+		}
+	}
+	return res
+}
+
+// Returns the ID of the Version that "tag" points to, "" if not set
+func (r *Resource) GetVersionTag(tag string) string {
+	return r.GetVersionTags()[tag]
+}
+
+// Point "tag" at Version "vID". A "vID" of "" will remove the tag.
+func (r *Resource) SetVersionTag(tag string, vID string) error {
+	if !RegexpVersionTag.MatchString(tag) {
+		return fmt.Errorf("Invalid version tag %q - must match %q", tag,
+			RegexpVersionTag.String())
+	}
+
+	if r.GetVersionTag(tag) == vID {
+		return nil
+	}
+
+	val := any(nil)
+	if vID != "" {
+		v, err := r.FindVersion(vID, false)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return fmt.Errorf("Can't tag Version %q as %q, it doesn't exist",
+				vID, tag)
+		}
+		val = vID
+	}
+
+	fromID := r.GetVersionTag(tag)
+	log.VPrintf(2, "Moving version tag %s/%s: %q -> %q", r.Path, tag,
+		fromID, vID)
+
+	pp := NewPPP(VersionTagsAttr.Name).P(tag)
+	if val == nil && len(r.GetVersionTags()) == 1 {
+		// Removing the last one, so remove the entire map
+		pp = NewPPP(VersionTagsAttr.Name)
+	}
+	if err := r.Entity.JustSet(pp, val); err != nil {
+		return err
+	}
+	if err := r.Entity.ValidateAndSave(); err != nil {
+		return err
+	}
+
+	if err := r.touch(); err != nil {
+		return err
+	}
+
+	return r.SaveVersionTagMove(tag, fromID, vID)
+}
+
+// A change to one of a Resource's version tags
+type VersionTagMove struct {
+	Tag     string `json:"tag"`
+	From    string `json:"from,omitempty"` // "" if the tag is new
+	To      string `json:"to,omitempty"`   // "" if the tag was removed
+	Epoch   int    `json:"epoch"`          // The Resource's, after the move
+	MovedBy string `json:"movedby,omitempty"`
+	MovedAt string `json:"movedat"`
+}
+
+// Records that "tag" was moved from Version "fromID" to "toID"
+func (r *Resource) SaveVersionTagMove(tag string, fromID string, toID string) error {
+	from, to := any(nil), any(nil)
+	if fromID != "" {
+		from = fromID
+	}
+	if toID != "" {
+		to = toID
+	}
+	epoch, _ := r.Get("epoch").(int)
+
+	err := DoOne(r.tx, `
+		INSERT INTO VersionTagMoves(ResourceSID, Tag, FromVersion, ToVersion,
+		    Epoch, MovedBy, MovedAt)
+		VALUES(?,?,?,?,?,?,?)`,
+		r.DbSID, tag, from, to, epoch, r.tx.User, r.tx.CreateTime)
+	if err != nil {
+		log.Printf("Error saving version tag move(%s/%s): %s", r.Path, tag,
+			err)
+	}
+	return err
+}
+
+// Returns the history of the Resource's version tags, oldest first
+func (r *Resource) GetVersionTagMoves() ([]*VersionTagMove, error) {
+	results, err := Query(r.tx, `
+		SELECT Tag, FromVersion, ToVersion, Epoch, MovedBy, MovedAt
+		FROM VersionTagMoves
+		WHERE ResourceSID=? ORDER BY Counter`,
+		r.DbSID)
+	defer results.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	moves := []*VersionTagMove{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		moves = append(moves, &VersionTagMove{
+			Tag:     NotNilString(row[0]),
+			From:    NotNilString(row[1]),
+			To:      NotNilString(row[2]),
+			Epoch:   NotNilInt(row[3]),
+			MovedBy: NotNilString(row[4]),
+			MovedAt: NotNilString(row[5]),
+		})
+	}
+
+	return moves, nil
+}
+
+// Replace (or merge with) the current set of version tags. "val" is the
+// incoming "versiontags" attribute from the client, nil removes them all.
+// When merging, a nil tag value removes just that tag.
+func (r *Resource) SetVersionTags(val any, merge bool) error {
+	newTags := map[string]any{}
+	if !IsNil(val) {
+		var ok bool
+		if newTags, ok = val.(map[string]any); !ok {
+			return fmt.Errorf("Attribute %q must be a map of strings",
+				VersionTagsAttr.Name)
+		}
+	}
+
+	if !merge || IsNil(val) {
+		for tag, _ := range r.GetVersionTags() {
+			if _, ok := newTags[tag]; !ok {
+				newTags[tag] = nil
+			}
+		}
+	}
+
+	for _, tag := range SortedKeys(newTags) {
+		vID := ""
+		if v := newTags[tag]; !IsNil(v) {
+			var ok bool
+			if vID, ok = v.(string); !ok || vID == "" {
+				return fmt.Errorf("Version tag %q must be a non-empty "+
+					"string", tag)
+			}
+		}
+		if err := r.SetVersionTag(tag, vID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes all tags pointing to Version "vID"
+func (r *Resource) RemoveVersionTags(vID string) error {
+	for tag, id := range r.GetVersionTags() {
+		if id == vID {
+			if err := r.SetVersionTag(tag, ""); err != nil {
+				return err
 			}
-			count--
 		}
-		vIDs = vIDs[1:]
 	}
 	return nil
 }
 
+// A change to the Resource itself (not a Version) is reflected in the epoch
+// and modifiedat of the default Version since that's what shows up as
+// the Resource's attributes
+func (r *Resource) touch() error {
+	v, err := r.GetDefault()
+	if err != nil || v == nil {
+		return err
+	}
+	if v.NewObject == nil {
+		v.NewObject = maps.Clone(v.Object)
+	}
+	return v.ValidateAndSave()
+}
+
 func (r *Resource) Delete() error {
 	log.VPrintf(3, ">Enter: Resource.Delete(%s)", r.UID)
 	defer log.VPrintf(3, "<Exit: Resource.Delete")
 
-	return DoOne(r.tx, `DELETE FROM Resources WHERE SID=?`, r.DbSID)
+	if err := r.CheckReadOnly(); err != nil {
+		return err
+	}
+
+	refs, err := r.Registry.GetDeleteRefs(r.Path)
+	if err != nil {
+		return err
+	}
+
+	if err = DoOne(r.tx, `DELETE FROM Resources WHERE SID=?`, r.DbSID); err != nil {
+		return err
+	}
+
+	return r.Registry.CascadeDelete(refs)
 }
 
 func (r *Resource) GetVersions() ([]*Version, error) {
@@ -0,0 +1,309 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/duglin/dlog"
+)
+
+// Retention policy for the Versions of a Resource. These are applied by
+// the background sweeper (see StartRetentionSweeper), not on each write
+// like "maxversions" is. The default Version is never pruned.
+type RetentionPolicy struct {
+	// Delete Versions whose "createdat" is older than this.
+	// Either a Go duration ("36h") or a number of days ("90d")
+	MaxAge string `json:"maxage,omitempty"`
+
+	// Only keep the newest N Versions of each major version (semver only)
+	KeepLastPerMajor int `json:"keeplastpermajor,omitempty"`
+
+	// Never prune a Version that has a version tag. Defaults to true
+	KeepTagged *bool `json:"keeptagged,omitempty"`
+}
+
+func (rp *RetentionPolicy) GetKeepTagged() bool {
+	return rp.KeepTagged == nil || *rp.KeepTagged == true
+}
+
+func (rp *RetentionPolicy) Verify(rm *ResourceModel) error {
+	if rp == nil {
+		return nil
+	}
+	if _, err := ParseRetentionAge(rp.MaxAge); err != nil {
+		return fmt.Errorf("Resource %q has an invalid 'retention.maxage' "+
+			"value: %s", rm.Plural, err)
+	}
+	if rp.KeepLastPerMajor < 0 {
+		return fmt.Errorf("Resource %q must have a "+
+			"'retention.keeplastpermajor' value >= 0", rm.Plural)
+	}
+	if rp.KeepLastPerMajor > 0 &&
+		rm.GetVersionIdStrategy() != VERSIONID_SEMVER {
+		return fmt.Errorf("Resource %q can only use "+
+			"'retention.keeplastpermajor' with a 'versionidstrategy' of %q",
+			rm.Plural, VERSIONID_SEMVER)
+	}
+	return nil
+}
+
+// Parse a "maxage" value. Zero means no limit.
+func ParseRetentionAge(str string) (time.Duration, error) {
+	if str == "" {
+		return 0, nil
+	}
+
+	if days, ok := strings.CutSuffix(str, "d"); ok {
+		num, err := strconv.Atoi(days)
+		if err != nil || num < 0 {
+			return 0, fmt.Errorf("%q is not a valid number of days", str)
+		}
+		return time.Duration(num) * 24 * time.Hour, nil
+	}
+
+	dur, err := time.ParseDuration(str)
+	if err != nil || dur < 0 {
+		return 0, fmt.Errorf("%q is not a valid duration", str)
+	}
+	return dur, nil
+}
+
+// The info about a Version needed to decide whether to prune it
+type RetentionVersion struct {
+	ID        string
+	CreatedAt time.Time
+	IsDefault bool
+	IsTagged  bool
+}
+
+// Returns the IDs of the Versions (in "versions", which is sorted oldest
+// first) that should be deleted per the policy, along with the reason why.
+func (rp *RetentionPolicy) Select(versions []RetentionVersion, now time.Time) map[string]string {
+	res := map[string]string{}
+	if rp == nil {
+		return res
+	}
+
+	keep := func(v RetentionVersion) bool {
+		return v.IsDefault || (v.IsTagged && rp.GetKeepTagged())
+	}
+
+	maxAge, _ := ParseRetentionAge(rp.MaxAge)
+	if maxAge > 0 {
+		for _, v := range versions {
+			if !keep(v) && !v.CreatedAt.IsZero() &&
+				now.Sub(v.CreatedAt) > maxAge {
+				res[v.ID] = fmt.Sprintf("older than %s", rp.MaxAge)
+			}
+		}
+	}
+
+	if rp.KeepLastPerMajor > 0 {
+		// Walk from newest to oldest, counting per major version
+		counts := map[int]int{}
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			sv, err := ParseSemver(v.ID)
+			if err != nil {
+				continue
+			}
+			counts[sv.Major]++
+			if counts[sv.Major] > rp.KeepLastPerMajor && !keep(v) {
+				if _, ok := res[v.ID]; !ok {
+					res[v.ID] = fmt.Sprintf("more than %d versions for "+
+						"major version %d", rp.KeepLastPerMajor, sv.Major)
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+// Returns the list of Versions that the Resource model's retention
+// policy says should be deleted, as a map of Version ID -> reason. Like
+// with "maxversions", Versions referenced by an xid aren't deleted, those
+// are in the 2nd map along with why they're being kept.
+func (r *Resource) GetRetentionCandidates(now time.Time) (map[string]string, map[string]string, error) {
+	_, rm := r.GetModels()
+	if rm.Retention == nil {
+		return map[string]string{}, map[string]string{}, nil
+	}
+
+	vIDs, err := r.GetVersionIDs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defaultID := r.GetAsString("defaultversionid")
+	tagged := map[string]bool{}
+	for _, vID := range r.GetVersionTags() {
+		tagged[vID] = true
+	}
+
+	versions := []RetentionVersion{}
+	for _, vID := range vIDs {
+		v, err := r.FindVersion(vID, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v == nil {
+			continue
+		}
+		ca, _ := time.Parse(time.RFC3339, v.GetAsString("createdat"))
+		versions = append(versions, RetentionVersion{
+			ID:        vID,
+			CreatedAt: ca,
+			IsDefault: vID == defaultID,
+			IsTagged:  tagged[vID],
+		})
+	}
+
+	list := rm.Retention.Select(versions, now)
+	skipped := map[string]string{}
+	for _, vID := range SortedKeys(list) {
+		refs, err := FindXIDRefs(r.tx, r.Registry, r.Path+"/versions/"+vID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(refs) > 0 {
+			skipped[vID] = fmt.Sprintf("%s, but it's referenced by %q",
+				list[vID], "/"+refs[0].Entity.Path)
+			delete(list, vID)
+		}
+	}
+
+	return list, skipped, nil
+}
+
+// Apply the retention policies of all Resource types in the Registry.
+// Returns a map of Version path -> reason for each Version that was
+// deleted, or would be deleted if "dryRun" is true, and the same for the
+// ones that were kept because they're still referenced by an xid.
+func (reg *Registry) ApplyRetention(dryRun bool) (map[string]string, map[string]string, error) {
+	res := map[string]string{}
+	skipped := map[string]string{}
+	now := time.Now()
+
+	for _, gm := range reg.Model.Groups {
+		for _, rm := range gm.Resources {
+			if rm.Retention == nil {
+				continue
+			}
+
+			gAbs := NewPPP(gm.Plural).Abstract()
+			rAbs := NewPPP(gm.Plural).P(rm.Plural).Abstract()
+			entities, err := RawEntitiesFromQuery(reg.tx, reg.DbSID,
+				`Abstract=? OR Abstract=?`, gAbs, rAbs)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// Groups are always followed by their Resources
+			group := (*Group)(nil)
+			for _, e := range entities {
+				if e.Level == 1 {
+					group = &Group{Entity: *e, Registry: reg}
+					continue
+				}
+				PanicIf(group == nil, "Group can't be nil")
+				resource := &Resource{Entity: *e, Group: group}
+
+				list, kept, err := resource.GetRetentionCandidates(now)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				for vID, reason := range kept {
+					skipped[resource.Path+"/versions/"+vID] = reason
+				}
+
+				for _, vID := range SortedKeys(list) {
+					res[resource.Path+"/versions/"+vID] = list[vID]
+					if dryRun {
+						continue
+					}
+
+					v, err := resource.FindVersion(vID, false)
+					if err != nil {
+						return nil, nil, err
+					}
+					if v == nil {
+						continue
+					}
+					log.VPrintf(2, "Retention: deleting %s (%s)", v.Path,
+						list[vID])
+					if err = v.Delete(""); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+		}
+	}
+
+	return res, skipped, nil
+}
+
+// Starts a background go-routine that applies the retention policies of
+// all Registries every "interval". Call the returned func to stop it.
+func StartRetentionSweeper(interval time.Duration) func() {
+	stop := make(chan struct{})
+	once := sync.Once{}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				SweepRetention()
+			}
+		}
+	}()
+
+	return func() { once.Do(func() { close(stop) }) }
+}
+
+// Apply the retention policies of all Registries, each in its own Tx
+func SweepRetention() {
+	names := GetRegistryNames()
+	sort.Strings(names)
+
+	for _, name := range names {
+		tx, err := NewTx()
+		if err != nil {
+			log.Printf("Retention: error creating Tx: %s", err)
+			return
+		}
+
+		// Read-only Resources get pruned too
+		tx.WriteReadOnly = true
+
+		reg, err := FindRegistry(tx, name)
+		if err == nil && reg != nil {
+			var list, skipped map[string]string
+			list, skipped, err = reg.ApplyRetention(false)
+			if err == nil && len(list) > 0 {
+				log.VPrintf(1, "Retention: deleted %d Version(s) from %q",
+					len(list), name)
+			}
+			for _, path := range SortedKeys(skipped) {
+				log.VPrintf(1, "Retention: kept %q in %q: %s", path, name,
+					skipped[path])
+			}
+		}
+
+		if err != nil {
+			log.Printf("Retention: error processing registry %q: %s",
+				name, err)
+		}
+		tx.Conditional(err)
+	}
+}
@@ -0,0 +1,115 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetentionAge(t *testing.T) {
+	type AgeTest struct {
+		Input  string
+		Result time.Duration
+		Err    bool
+	}
+
+	tests := []AgeTest{
+		{"", 0, false},
+		{"90d", 90 * 24 * time.Hour, false},
+		{"0d", 0, false},
+		{"36h", 36 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"xd", 0, true},
+		{"-1d", 0, true},
+		{"-5h", 0, true},
+		{"forever", 0, true},
+	}
+
+	for _, test := range tests {
+		dur, err := ParseRetentionAge(test.Input)
+		if test.Err {
+			if err == nil {
+				t.Fatalf("Parse(%q) should have failed", test.Input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %s", test.Input, err)
+		}
+		if dur != test.Result {
+			t.Fatalf("Parse(%q) got: %s", test.Input, dur)
+		}
+	}
+}
+
+func TestRetentionSelect(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) time.Time {
+		return now.Add(-time.Duration(d) * 24 * time.Hour)
+	}
+	keepTagged := false
+
+	type SelectTest struct {
+		Policy   *RetentionPolicy
+		Versions []RetentionVersion
+		Result   string // sorted "id=reason" pairs
+	}
+
+	tests := []SelectTest{
+		{nil, []RetentionVersion{{ID: "1", CreatedAt: daysAgo(100)}}, ""},
+		{
+			&RetentionPolicy{MaxAge: "90d"},
+			[]RetentionVersion{
+				{ID: "1", CreatedAt: daysAgo(100)},
+				{ID: "2", CreatedAt: daysAgo(95), IsTagged: true},
+				{ID: "3", CreatedAt: daysAgo(91), IsDefault: true},
+				{ID: "4", CreatedAt: daysAgo(10)},
+				{ID: "5"},
+			},
+			"1=older than 90d",
+		},
+		{
+			&RetentionPolicy{MaxAge: "90d", KeepTagged: &keepTagged},
+			[]RetentionVersion{
+				{ID: "1", CreatedAt: daysAgo(100)},
+				{ID: "2", CreatedAt: daysAgo(95), IsTagged: true},
+			},
+			"1=older than 90d,2=older than 90d",
+		},
+		{
+			&RetentionPolicy{KeepLastPerMajor: 2},
+			[]RetentionVersion{
+				{ID: "1.0.0"},
+				{ID: "1.1.0"},
+				{ID: "1.2.0"},
+				{ID: "2.0.0"},
+				{ID: "2.1.0"},
+				{ID: "2.2.0", IsDefault: true},
+			},
+			"1.0.0=more than 2 versions for major version 1," +
+				"2.0.0=more than 2 versions for major version 2",
+		},
+		{
+			&RetentionPolicy{KeepLastPerMajor: 1, MaxAge: "30d"},
+			[]RetentionVersion{
+				{ID: "1.0.0", CreatedAt: daysAgo(40)},
+				{ID: "1.1.0", CreatedAt: daysAgo(20), IsTagged: true},
+				{ID: "1.2.0", CreatedAt: daysAgo(10)},
+				{ID: "2.0.0", CreatedAt: daysAgo(5), IsDefault: true},
+			},
+			"1.0.0=older than 30d",
+		},
+	}
+
+	for i, test := range tests {
+		res := test.Policy.Select(test.Versions, now)
+		list := []string{}
+		for _, id := range SortedKeys(res) {
+			list = append(list, id+"="+res[id])
+		}
+		got := strings.Join(list, ",")
+		if got != test.Result {
+			t.Fatalf("Test %d:\nExp: %s\nGot: %s", i, test.Result, got)
+		}
+	}
+}
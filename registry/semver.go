@@ -0,0 +1,139 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Semantic version, as defined by https://semver.org
+type Semver struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease []string // dot separated identifiers after the "-"
+	Build      string   // everything after the "+", ignored for ordering
+}
+
+var RegexpSemver = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.` +
+	`(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)` +
+	`(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+func ParseSemver(str string) (*Semver, error) {
+	parts := RegexpSemver.FindStringSubmatch(str)
+	if parts == nil {
+		return nil, fmt.Errorf("%q is not a valid semantic version", str)
+	}
+
+	sv := &Semver{Build: parts[5]}
+	var err error
+	if sv.Major, err = strconv.Atoi(parts[1]); err != nil {
+		return nil, fmt.Errorf("%q is not a valid semantic version", str)
+	}
+	if sv.Minor, err = strconv.Atoi(parts[2]); err != nil {
+		return nil, fmt.Errorf("%q is not a valid semantic version", str)
+	}
+	if sv.Patch, err = strconv.Atoi(parts[3]); err != nil {
+		return nil, fmt.Errorf("%q is not a valid semantic version", str)
+	}
+	if parts[4] != "" {
+		sv.PreRelease = strings.Split(parts[4], ".")
+	}
+	return sv, nil
+}
+
+func (sv *Semver) String() string {
+	str := fmt.Sprintf("%d.%d.%d", sv.Major, sv.Minor, sv.Patch)
+	if len(sv.PreRelease) > 0 {
+		str += "-" + strings.Join(sv.PreRelease, ".")
+	}
+	if sv.Build != "" {
+		str += "+" + sv.Build
+	}
+	return str
+}
+
+// Returns a new Semver that is "sv" bumped by "part" (major, minor, patch).
+// Any pre-release or build info is dropped.
+func (sv *Semver) Bump(part string) (*Semver, error) {
+	res := &Semver{Major: sv.Major, Minor: sv.Minor, Patch: sv.Patch}
+	switch part {
+	case SEMVER_MAJOR:
+		res.Major++
+		res.Minor = 0
+		res.Patch = 0
+	case SEMVER_MINOR:
+		res.Minor++
+		res.Patch = 0
+	case SEMVER_PATCH, "":
+		// Bumping a pre-release just drops the pre-release part
+		if len(sv.PreRelease) == 0 {
+			res.Patch++
+		}
+	default:
+		return nil, fmt.Errorf("Invalid semver bump value %q, must be one "+
+			"of: %s, %s, %s", part, SEMVER_MAJOR, SEMVER_MINOR, SEMVER_PATCH)
+	}
+	return res, nil
+}
+
+// Returns <0, 0 or >0 based on the precedence rules of semver
+func (sv *Semver) Compare(other *Semver) int {
+	if sv.Major != other.Major {
+		return sv.Major - other.Major
+	}
+	if sv.Minor != other.Minor {
+		return sv.Minor - other.Minor
+	}
+	if sv.Patch != other.Patch {
+		return sv.Patch - other.Patch
+	}
+
+	// A version w/o a pre-release has a higher precedence than one with
+	if len(sv.PreRelease) == 0 || len(other.PreRelease) == 0 {
+		return len(other.PreRelease) - len(sv.PreRelease)
+	}
+
+	for i := 0; i < len(sv.PreRelease) && i < len(other.PreRelease); i++ {
+		a, b := sv.PreRelease[i], other.PreRelease[i]
+		if a == b {
+			continue
+		}
+		aNum, aErr := strconv.Atoi(a)
+		bNum, bErr := strconv.Atoi(b)
+		switch {
+		case aErr == nil && bErr == nil:
+			return aNum - bNum
+		case aErr == nil:
+			return -1 // numeric identifiers are lower than alphanumeric
+		case bErr == nil:
+			return 1
+		default:
+			return strings.Compare(a, b)
+		}
+	}
+	return len(sv.PreRelease) - len(other.PreRelease)
+}
+
+// Sort a list of Version IDs, oldest first, using semver precedence.
+// IDs that aren't valid semvers are kept in their original relative order
+// and are placed before all valid ones.
+func SortSemverIDs(ids []string) {
+	svs := map[string]*Semver{}
+	for _, id := range ids {
+		if sv, err := ParseSemver(id); err == nil {
+			svs[id] = sv
+		}
+	}
+
+	sort.SliceStable(ids, func(i, j int) bool {
+		a, b := svs[ids[i]], svs[ids[j]]
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Compare(b) < 0
+	})
+}
@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	type SemverTest struct {
+		Input  string
+		Result string // "" means error
+	}
+
+	tests := []SemverTest{
+		{"1.2.3", "1.2.3"},
+		{"0.0.0", "0.0.0"},
+		{"10.20.30-alpha.1+build.5", "10.20.30-alpha.1+build.5"},
+		{"1.0.0-0.3.7", "1.0.0-0.3.7"},
+		{"1.0.0+abc", "1.0.0+abc"},
+		{"1.2", ""},
+		{"v1.2.3", ""},
+		{"01.2.3", ""},
+		{"1.2.3-01", ""},
+		{"1.2.3-", ""},
+		{"a.b.c", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		sv, err := ParseSemver(test.Input)
+		if test.Result == "" {
+			if err == nil {
+				t.Fatalf("Parse(%q) should have failed", test.Input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %s", test.Input, err)
+		}
+		if sv.String() != test.Result {
+			t.Fatalf("Parse(%q) got: %s", test.Input, sv.String())
+		}
+	}
+}
+
+func TestSemverBump(t *testing.T) {
+	type BumpTest struct {
+		Input  string
+		Part   string
+		Result string
+	}
+
+	tests := []BumpTest{
+		{"1.2.3", "", "1.2.4"},
+		{"1.2.3", "patch", "1.2.4"},
+		{"1.2.3", "minor", "1.3.0"},
+		{"1.2.3", "major", "2.0.0"},
+		{"1.2.3-beta+b1", "patch", "1.2.3"},
+		{"1.2.3-beta", "minor", "1.3.0"},
+		{"1.2.3", "huge", "Invalid semver bump value \"huge\", must be " +
+			"one of: major, minor, patch"},
+	}
+
+	for _, test := range tests {
+		sv, err := ParseSemver(test.Input)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %s", test.Input, err)
+		}
+		res, err := sv.Bump(test.Part)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = res.String()
+		}
+		if got != test.Result {
+			t.Fatalf("Bump(%s,%s)\nExp: %s\nGot: %s", test.Input, test.Part,
+				test.Result, got)
+		}
+	}
+}
+
+func TestSortSemverIDs(t *testing.T) {
+	type SortTest struct {
+		Input  string
+		Result string
+	}
+
+	tests := []SortTest{
+		{"", ""},
+		{"1.0.0", "1.0.0"},
+		{"2.0.0,1.0.0,1.10.0,1.2.0", "1.0.0,1.2.0,1.10.0,2.0.0"},
+		{"1.0.0,1.0.0-rc.1,1.0.0-beta,1.0.0-alpha.beta,1.0.0-alpha.1,1.0.0-alpha,1.0.0-beta.11,1.0.0-beta.2",
+			"1.0.0-alpha,1.0.0-alpha.1,1.0.0-alpha.beta,1.0.0-beta,1.0.0-beta.2,1.0.0-beta.11,1.0.0-rc.1,1.0.0"},
+		{"1.0.1,xxx,1.0.0,aaa", "xxx,aaa,1.0.0,1.0.1"},
+	}
+
+	for _, test := range tests {
+		ids := []string{}
+		if test.Input != "" {
+			ids = strings.Split(test.Input, ",")
+		}
+		SortSemverIDs(ids)
+		got := strings.Join(ids, ",")
+		if got != test.Result {
+			t.Fatalf("Sort(%s)\nExp: %s\nGot: %s", test.Input, test.Result,
+				got)
+		}
+	}
+}
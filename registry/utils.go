@@ -56,6 +56,26 @@ func init() {
 	}
 }
 
+// Returns a copy of "val" where any nested maps and arrays are copied too,
+// so changes to the result won't be seen in the original
+func DeepCopy(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(v))
+		for k, item := range v {
+			res[k] = DeepCopy(item)
+		}
+		return res
+	case []any:
+		res := make([]any, len(v))
+		for i, item := range v {
+			res[i] = DeepCopy(item)
+		}
+		return res
+	}
+	return val
+}
+
 func IsNil(a any) bool {
 	val := reflect.ValueOf(a)
 	if !val.IsValid() {
@@ -125,6 +145,14 @@ func PtrBool(b bool) *bool {
 	return &b
 }
 
+func PtrInt(i int) *int {
+	return &i
+}
+
+func PtrFloat(f float64) *float64 {
+	return &f
+}
+
 func PtrBoolDef(val *any, def bool) *bool {
 	result := NotNilBoolDef(val, def)
 	return &result
@@ -351,6 +379,71 @@ func ProcessImports(file string, buf []byte, localFiles bool) ([]byte, error) {
 	return buf, nil
 }
 
+// Merges model fragments (already parsed, and with their imports
+// processed) into one model, in order. Maps (e.g. groups, resources and
+// attributes) are merged, any other value must be the same in every
+// fragment that has it. "files" are the names of the fragments' files,
+// for error messages.
+func MergeModels(files []string, fragments []map[string]any) (map[string]any, error) {
+	res := map[string]any{}
+	owners := map[string]string{} // path -> file that first defined it
+
+	for i, fragment := range fragments {
+		if err := mergeModelMap(res, fragment, "", files[i], owners); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func mergeModelMap(dst map[string]any, src map[string]any, path string,
+	file string, owners map[string]string) error {
+
+	for _, key := range SortedKeys(src) {
+		srcVal := src[key]
+		dstVal, ok := dst[key]
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		if !ok {
+			owners[keyPath] = file
+			if srcMap, isMap := srcVal.(map[string]any); isMap {
+				// Copy it so we know who owns each part of it
+				newMap := map[string]any{}
+				dst[key] = newMap
+				err := mergeModelMap(newMap, srcMap, keyPath, file, owners)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			dst[key] = srcVal
+			continue
+		}
+
+		dstMap, dstIsMap := dstVal.(map[string]any)
+		srcMap, srcIsMap := srcVal.(map[string]any)
+		if dstIsMap && srcIsMap {
+			err := mergeModelMap(dstMap, srcMap, keyPath, file, owners)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(dstVal, srcVal) {
+			dstBuf, _ := json.Marshal(dstVal)
+			srcBuf, _ := json.Marshal(srcVal)
+			return fmt.Errorf("Conflicting definitions of %q: %s in %q, "+
+				"and %s in %q", keyPath, dstBuf, owners[keyPath], srcBuf, file)
+		}
+	}
+
+	return nil
+}
+
 // data is the current map to check for $import statements
 func ImportTraverse(importArgs ImportArgs, data map[string]any) error {
 	var err error
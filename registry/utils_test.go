@@ -2,11 +2,13 @@ package registry
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -208,6 +210,60 @@ func (h *FSHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 }
 
 // Test ProcessImports
+func TestMergeModels(t *testing.T) {
+	parse := func(str string) map[string]any {
+		t.Helper()
+		res := map[string]any{}
+		if err := json.Unmarshal([]byte(str), &res); err != nil {
+			t.Fatalf("Bad JSON %q: %s", str, err)
+		}
+		return res
+	}
+
+	a := parse(`{"groups":{"dirs":{"plural":"dirs","singular":"dir",
+	  "attributes":{"size":{"name":"size","type":"integer"}}}}}`)
+	b := parse(`{"attributes":{"owner":{"name":"owner","type":"string"}},
+	  "groups":{"dirs":{"plural":"dirs",
+	  "resources":{"files":{"plural":"files","singular":"file"}}}}}`)
+
+	res, err := MergeModels([]string{"a.json", "b.json"},
+		[]map[string]any{a, b})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := parse(`{"attributes":{"owner":{"name":"owner","type":"string"}},
+	  "groups":{"dirs":{"plural":"dirs","singular":"dir",
+	  "attributes":{"size":{"name":"size","type":"integer"}},
+	  "resources":{"files":{"plural":"files","singular":"file"}}}}}`)
+	if !reflect.DeepEqual(res, exp) {
+		t.Fatalf("Exp:\n%s\nGot:\n%s", ToJSON(exp), ToJSON(res))
+	}
+
+	// The fragments aren't changed
+	if _, ok := a["attributes"]; ok {
+		t.Fatalf("a.json was changed: %s", ToJSON(a))
+	}
+
+	c := parse(`{"groups":{"dirs":{"attributes":{"size":{"type":"string"}}}}}`)
+	_, err = MergeModels([]string{"a.json", "b.json", "c.json"},
+		[]map[string]any{a, b, c})
+	expErr := `Conflicting definitions of "groups.dirs.attributes.size.type": ` +
+		`"integer" in "a.json", and "string" in "c.json"`
+	if err == nil || err.Error() != expErr {
+		t.Fatalf("Exp: %s\nGot: %v", expErr, err)
+	}
+
+	d := parse(`{"groups":{"dirs":"foo"}}`)
+	_, err = MergeModels([]string{"a.json", "d.json"},
+		[]map[string]any{a, d})
+	expErr = `Conflicting definitions of "groups.dirs": {"attributes":` +
+		`{"size":{"name":"size","type":"integer"}},"plural":"dirs",` +
+		`"singular":"dir"} in "a.json", and "foo" in "d.json"`
+	if err == nil || err.Error() != expErr {
+		t.Fatalf("Exp: %s\nGot: %v", expErr, err)
+	}
+}
+
 func TestProcessImports(t *testing.T) {
 	// Setup HTTP server
 	httpPaths := map[string]string{
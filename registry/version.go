@@ -35,11 +35,30 @@ func (v *Version) Delete(nextVersionID string) error {
 		return fmt.Errorf("Can't set defaultversionid to Version being deleted")
 	}
 
+	if err := v.CheckReadOnly(); err != nil {
+		return err
+	}
+
+	refs, err := v.Registry.GetDeleteRefs(v.Path)
+	if err != nil {
+		return err
+	}
+
+	// Any tags pointing to this Version go away with it
+	if err := v.Resource.RemoveVersionTags(v.UID); err != nil {
+		return err
+	}
+
 	// Zero is ok if it's already been deleted
-	err := DoZeroOne(v.tx, `DELETE FROM Versions WHERE SID=?`, v.DbSID)
+	err = DoZeroOne(v.tx, `DELETE FROM Versions WHERE SID=?`, v.DbSID)
 	if err != nil {
 		return fmt.Errorf("Error deleting Version %q: %s", v.UID, err)
 	}
+	v.tx.RemoveVersion(v)
+
+	if err = v.Registry.CascadeDelete(refs); err != nil {
+		return err
+	}
 
 	// On zero, we'll continue and process the nextVersionID... should we?
 
@@ -0,0 +1,356 @@
+package registry
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	log "github.com/duglin/dlog"
+)
+
+// An "xid" is a reference to another entity in the same Registry, in the
+// form of its path relative to the root of the Registry:
+//   /GROUPS/gID[/RESOURCES/rID[/versions/vID]]
+
+// Returns an error if "xid" isn't well formed or doesn't point to an
+// existing entity. The error is meant to follow an attribute's name.
+// Entities that aren't in a Tx (e.g. see ValidateRegistryData) have no DB
+// to look in, so only the format is checked.
+func (e *Entity) CheckXID(xid string) error {
+	path, ok := strings.CutPrefix(xid, "/")
+	parts := strings.Split(path, "/")
+	if !ok || len(parts)%2 != 0 || slices.Contains(parts, "") ||
+		(len(parts) == 6 && parts[4] != "versions") || len(parts) > 6 {
+		return fmt.Errorf("has an invalid xid (%s), must be of the form "+
+			"/GROUPS/gID[/RESOURCES/rID[/versions/vID]]", xid)
+	}
+
+	if e.tx == nil {
+		return nil
+	}
+
+	target, err := RawEntityFromPath(e.tx, e.Registry.DbSID, path, false)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("references an unknown entity (%s)", xid)
+	}
+	return nil
+}
+
+// Finds the entity that "xid" points to. Resources will include the
+// attributes of their default Version. Returns nil if it's not there.
+func EntityFromXID(tx *Tx, reg *Registry, xid string) (*Entity, error) {
+	results, err := Query(tx, `
+		SELECT
+		  RegSID,Level,Plural,eSID,UID,PropName,PropValue,PropType,Path,Abstract
+		FROM FullTree WHERE RegSID=? AND Path=?`,
+		reg.DbSID, strings.TrimPrefix(xid, "/"))
+	defer results.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return readNextEntity(tx, results)
+}
+
+// An entity that has an xid pointing to an entity that's being deleted
+type XIDRef struct {
+	Entity   *Entity
+	XID      string
+	OnDelete string
+}
+
+// Walk "val" (of type "daType") looking for xid values, calling "fn" for
+// each one. "onDelete" is inherited from the nearest attribute with an
+// "ondelete" value since map and array items can't have one.
+func walkXIDs(val any, daType string, attrs Attributes, item *Item,
+	onDelete string, fn func(xid string, onDelete string)) {
+
+	if IsNil(val) {
+		return
+	}
+
+	switch daType {
+	case XID:
+		if str, ok := val.(string); ok {
+			fn(str, onDelete)
+		}
+	case OBJECT:
+		obj, ok := val.(map[string]any)
+		if !ok {
+			return
+		}
+		for key, v := range obj {
+			attr := attrs[key]
+			if attr == nil {
+				if attr = attrs["*"]; attr == nil {
+					continue
+				}
+			}
+			od := onDelete
+			if attr.OnDelete != "" {
+				od = attr.OnDelete
+			}
+			walkXIDs(v, attr.Type, attr.Attributes, attr.Item, od, fn)
+		}
+	case MAP, ARRAY:
+		if item == nil {
+			return
+		}
+		valValue := reflect.ValueOf(val)
+		if valValue.Kind() == reflect.Map {
+			for _, v := range val.(map[string]any) {
+				walkXIDs(v, item.Type, item.Attributes, item.Item, onDelete, fn)
+			}
+		} else if valValue.Kind() == reflect.Slice {
+			for _, v := range val.([]any) {
+				walkXIDs(v, item.Type, item.Attributes, item.Item, onDelete, fn)
+			}
+		}
+	}
+}
+
+// Same as walkXIDs except "fn" returns the new value for each xid, and a
+// copy of "val" with those new values is returned
+func mapXIDs(val any, daType string, attrs Attributes, item *Item,
+	fn func(xid string) string) any {
+
+	if IsNil(val) {
+		return val
+	}
+
+	switch daType {
+	case XID:
+		if str, ok := val.(string); ok {
+			return fn(str)
+		}
+	case OBJECT:
+		obj, ok := val.(map[string]any)
+		if !ok {
+			return val
+		}
+		newObj := map[string]any{}
+		for key, v := range obj {
+			newObj[key] = v
+			attr := attrs[key]
+			if attr == nil {
+				if attr = attrs["*"]; attr == nil {
+					continue
+				}
+			}
+			newObj[key] = mapXIDs(v, attr.Type, attr.Attributes, attr.Item, fn)
+		}
+		return newObj
+	case MAP:
+		valMap, ok := val.(map[string]any)
+		if !ok || item == nil {
+			return val
+		}
+		newMap := map[string]any{}
+		for k, v := range valMap {
+			newMap[k] = mapXIDs(v, item.Type, item.Attributes, item.Item, fn)
+		}
+		return newMap
+	case ARRAY:
+		valArray, ok := val.([]any)
+		if !ok || item == nil {
+			return val
+		}
+		newArray := []any{}
+		for _, v := range valArray {
+			newArray = append(newArray,
+				mapXIDs(v, item.Type, item.Attributes, item.Item, fn))
+		}
+		return newArray
+	}
+	return val
+}
+
+// Changes all xids that point to the entity at "oldPath", or to anything
+// under it, to point to "newPath" instead. Used when entities are moved as
+// part of renaming their type.
+func (reg *Registry) RenameXIDs(oldPath string, newPath string) error {
+	oldXID, newXID := "/"+oldPath, "/"+newPath
+
+	entities, err := RawEntitiesFromQuery(reg.tx, reg.DbSID, `
+		e.eSID IN (SELECT EntitySID FROM Props
+		           WHERE RegistrySID=? AND (PropValue=? OR PropValue LIKE ?))`,
+		reg.DbSID, oldXID, oldXID+"/%")
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entities {
+		e.Registry = reg
+		changed := false
+		attrs := e.GetAttributes(e.Object)
+		newObj := mapXIDs(e.Object, OBJECT, attrs, nil,
+			func(xid string) string {
+				if xid != oldXID && !strings.HasPrefix(xid, oldXID+"/") {
+					return xid
+				}
+				changed = true
+				return newXID + xid[len(oldXID):]
+			})
+		if !changed {
+			continue
+		}
+
+		e.NewObject = newObj.(map[string]any)
+		if err = e.Save(); err != nil {
+			return fmt.Errorf("Error updating the xids of %q: %s",
+				"/"+e.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns the entities (outside of "path") that have an xid pointing to the
+// entity at "path", or to anything under it
+func FindXIDRefs(tx *Tx, reg *Registry, path string) ([]*XIDRef, error) {
+	xid := "/" + path
+
+	entities, err := RawEntitiesFromQuery(tx, reg.DbSID, `
+		e.eSID IN (SELECT EntitySID FROM Props
+		           WHERE RegistrySID=? AND (PropValue=? OR PropValue LIKE ?))`,
+		reg.DbSID, xid, xid+"/%")
+	if err != nil {
+		return nil, err
+	}
+
+	refs := []*XIDRef{}
+	for _, e := range entities {
+		// References from within the thing being deleted don't count
+		if e.Path == path || strings.HasPrefix(e.Path, path+"/") {
+			continue
+		}
+
+		var ref *XIDRef
+		attrs := e.GetAttributes(e.Object)
+		walkXIDs(e.Object, OBJECT, attrs, nil, "",
+			func(val string, onDelete string) {
+				if val != xid && !strings.HasPrefix(val, xid+"/") {
+					return
+				}
+				if onDelete == "" {
+					onDelete = ONDELETE_BLOCK
+				}
+				if ref == nil {
+					ref = &XIDRef{Entity: e, XID: val, OnDelete: onDelete}
+				} else if onDelete == ONDELETE_BLOCK {
+					// Any "block" wins over "cascade"
+					ref.XID = val
+					ref.OnDelete = onDelete
+				}
+			})
+		if ref != nil {
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs, nil
+}
+
+// Called before the entity at "path" is deleted. Returns the list of
+// entities that need to be deleted along with it, or an error if one of
+// the references to it doesn't allow it to be deleted.
+func (reg *Registry) GetDeleteRefs(path string) ([]*XIDRef, error) {
+	refs, err := FindXIDRefs(reg.tx, reg, path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ref := range refs {
+		if ref.OnDelete != ONDELETE_CASCADE || ref.Entity.Level == 0 {
+			return nil, fmt.Errorf("Can't delete %q, it's referenced by %q",
+				"/"+path, "/"+ref.Entity.Path)
+		}
+	}
+	return refs, nil
+}
+
+// Deletes the entities that referenced an entity that was just deleted.
+// Any that are already gone (e.g. due to an earlier cascade) are skipped.
+func (reg *Registry) CascadeDelete(refs []*XIDRef) error {
+	for _, ref := range refs {
+		parts := strings.Split(ref.Entity.Path, "/")
+		log.VPrintf(2, "Cascading delete to %q due to %q", ref.Entity.Path,
+			ref.XID)
+
+		group, err := reg.FindGroup(parts[0], parts[1], false)
+		if err != nil {
+			return err
+		}
+		if group == nil {
+			continue
+		}
+		if len(parts) == 2 {
+			if err = group.Delete(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		resource, err := group.FindResource(parts[2], parts[3], false)
+		if err != nil {
+			return err
+		}
+		if resource == nil {
+			continue
+		}
+		if len(parts) == 4 {
+			if err = resource.Delete(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		version, err := resource.FindVersion(parts[5], false)
+		if err != nil {
+			return err
+		}
+		if version == nil {
+			continue
+		}
+		if err = version.Delete(""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns true if "pp" (e.g. "dirs.files.ref") is the path to a top-level
+// xid attribute of one of the entity types in the model
+func (m *Model) IsXIDAttribute(pp *PropPath) bool {
+	parts := []string{}
+	for _, part := range pp.Parts {
+		parts = append(parts, part.Text)
+	}
+
+	attrs := Attributes(nil)
+	switch len(parts) {
+	case 1:
+		attrs = m.Attributes
+	case 2:
+		if gm := m.Groups[parts[0]]; gm != nil {
+			attrs = gm.Attributes
+		}
+	case 3, 4:
+		if gm := m.Groups[parts[0]]; gm != nil {
+			rm := gm.Resources[parts[1]]
+			if rm != nil && (len(parts) == 3 || parts[2] == "versions") {
+				attrs = rm.Attributes
+			}
+		}
+	default:
+		return false
+	}
+
+	attr := attrs[parts[len(parts)-1]]
+	return attr != nil && attr.Type == XID
+}
@@ -481,3 +481,116 @@ func TestANDORFilters(t *testing.T) {
 		xCheckGet(t, reg, test.URL, test.Exp)
 	}
 }
+
+func TestLabelSelectors(t *testing.T) {
+	reg := NewRegistry("TestLabelSelectors")
+	defer PassDeleteReg(t, reg)
+
+	gm, err := reg.Model.AddGroupModel("dirs", "dir")
+	xNoErr(t, err)
+	_, err = gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, err)
+	d, _ := reg.AddGroup("dirs", "d1")
+	f, _ := d.AddResource("files", "f1", "v1")
+	f.SetSave("labels.env", "prod")
+	f.SetSave("labels.tier", "backend")
+	f, _ = d.AddResource("files", "f2", "v1")
+	f.SetSave("labels.env", "staging")
+	f.SetSave("labels.tier", "frontend")
+	f, _ = d.AddResource("files", "f3", "v1")
+	f.SetSave("labels.env", "prod")
+	f.SetSave("labels.experimental", "true")
+	d.AddResource("files", "f4", "v1")
+
+	// /dirs/d1/f1  env=prod    tier=backend
+	//         /f2  env=staging tier=frontend
+	//         /f3  env=prod    experimental=true
+	//         /f4
+
+	tests := []struct {
+		Name string
+		URL  string
+		Exp  string
+	}{
+		{
+			Name: "exists",
+			URL:  "dirs/d1/files?oneline&inline&labels=tier",
+			Exp:  `{"f1":{"versions":{"v1":{}}},"f2":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "not exists",
+			URL:  "dirs/d1/files?oneline&inline&labels=!tier",
+			Exp:  `{"f3":{"versions":{"v1":{}}},"f4":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "equals",
+			URL:  "dirs/d1/files?oneline&inline&labels=env=prod",
+			Exp:  `{"f1":{"versions":{"v1":{}}},"f3":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "double equals",
+			URL:  "dirs/d1/files?oneline&inline&labels=env==staging",
+			Exp:  `{"f2":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "not equals - includes missing labels",
+			URL:  "dirs/d1/files?oneline&inline&labels=tier!=frontend",
+			Exp:  `{"f1":{"versions":{"v1":{}}},"f3":{"versions":{"v1":{}}},"f4":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "in",
+			URL:  "dirs/d1/files?oneline&inline&labels=env+in+(staging,dev)",
+			Exp:  `{"f2":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "notin",
+			URL:  "dirs/d1/files?oneline&inline&labels=env+notin+(prod,dev)",
+			Exp:  `{"f2":{"versions":{"v1":{}}},"f4":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "combo",
+			URL:  "dirs/d1/files?oneline&inline&labels=env+in+(prod,staging),!experimental,tier!=frontend",
+			Exp:  `{"f1":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "combo - no match",
+			URL:  "dirs/d1/files?oneline&inline&labels=env=prod,tier=frontend",
+			Exp:  `{}`,
+		},
+		{
+			Name: "OR'd selectors",
+			URL:  "dirs/d1/files?oneline&inline&labels=tier=frontend&labels=experimental",
+			Exp:  `{"f2":{"versions":{"v1":{}}},"f3":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "AND'd with filter",
+			URL:  "dirs/d1/files?oneline&inline&labels=env+in+(prod,staging)&filter=id=f3",
+			Exp:  `{"f3":{"versions":{"v1":{}}}}`,
+		},
+		{
+			Name: "versions",
+			URL:  "dirs/d1/files/f1/versions?oneline&inline&labels=env=prod",
+			Exp:  `{"v1":{}}`,
+		},
+		{
+			Name: "bad key",
+			URL:  "dirs/d1/files?labels=Env",
+			Exp:  "Invalid label selector \"Env\": invalid label key \"Env\"\n",
+		},
+		{
+			Name: "bad selector",
+			URL:  "dirs/d1/files?labels=env+in+(prod",
+			Exp:  "Invalid label selector \"env in (prod\": missing ')'\n",
+		},
+		{
+			Name: "bad selector - empty set",
+			URL:  "dirs/d1/files?labels=env+in+()",
+			Exp:  "Invalid label selector \"env in ()\": \"env in ()\" must have at least one value\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("Test name: %s", test.Name)
+		xCheckGet(t, reg, test.URL, test.Exp)
+	}
+}
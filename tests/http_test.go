@@ -2,7 +2,7 @@ package tests
 
 import (
 	"bytes"
-	// "encoding/json"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -6831,6 +6831,73 @@ func TestHTTPModelSchema(t *testing.T) {
 }
 `)
 
+	xHTTP(t, reg, "GET", "/model?schema="+registry.JSONSCHEMA, "", 200, `{
+  "$defs": {
+    "registry": {
+      "properties": {
+        "createdat": {
+          "format": "date-time",
+          "type": "string"
+        },
+        "description": {
+          "type": "string"
+        },
+        "documentation": {
+          "format": "uri",
+          "type": "string"
+        },
+        "epoch": {
+          "minimum": 0,
+          "type": "integer"
+        },
+        "id": {
+          "type": "string"
+        },
+        "labels": {
+          "additionalProperties": {
+            "type": "string"
+          },
+          "propertyNames": {
+            "pattern": "^[a-z0-9][a-z0-9_.\\-]{0,62}$"
+          },
+          "type": "object"
+        },
+        "model": {
+          "properties": {},
+          "readOnly": true,
+          "type": "object",
+          "unevaluatedProperties": {}
+        },
+        "modifiedat": {
+          "format": "date-time",
+          "type": "string"
+        },
+        "name": {
+          "type": "string"
+        },
+        "self": {
+          "format": "uri",
+          "readOnly": true,
+          "type": "string"
+        },
+        "specversion": {
+          "readOnly": true,
+          "type": "string"
+        }
+      },
+      "type": "object",
+      "unevaluatedProperties": false
+    }
+  },
+  "$ref": "#/$defs/registry",
+  "$schema": "https://json-schema.org/draft/2020-12/schema"
+}
+`)
+
+	xHTTP(t, reg, "GET", "/model?schema="+registry.JSONSCHEMA+"/bad", "", 400,
+		`Unsupported schema format: jsonschema/bad
+`)
+
 	xHTTP(t, reg, "GET", "/model?schema="+registry.XREGSCHEMA+"/bad", "", 400,
 		`Unsupported schema format: xRegistry-json/bad
 `)
@@ -6841,6 +6908,67 @@ func TestHTTPModelSchema(t *testing.T) {
 
 }
 
+func TestHTTPModelOpenAPI(t *testing.T) {
+	reg := NewRegistry("TestHTTPModelOpenAPI")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	rm, _ := gm.AddResourceModel("files", "file", 0, true, true, true)
+	_, err := rm.AddAttribute(&registry.Attribute{
+		Name:           "owner",
+		Type:           registry.STRING,
+		ClientRequired: true,
+		ServerRequired: true,
+	})
+	xNoErr(t, err)
+	xNoErr(t, reg.Commit())
+
+	res, err := http.Get("http://localhost:8181/model?schema=" +
+		registry.OPENAPI)
+	xNoErr(t, err)
+	xCheck(t, res.StatusCode == 200, "Bad status: %d", res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	xNoErr(t, err)
+
+	doc := map[string]any{}
+	xNoErr(t, json.Unmarshal(body, &doc))
+
+	get := func(path ...string) string {
+		var val any = doc
+		for _, part := range path {
+			obj, _ := val.(map[string]any)
+			val = obj[part]
+		}
+		return registry.ToJSON(val)
+	}
+
+	rPath := "/dirs/{groupid}/files/{resourceid}"
+
+	xCheckEqual(t, "", get("openapi"), `"`+registry.OPENAPI_VERSION+`"`)
+	xCheckEqual(t, "", get("paths", "/dirs", "get", "operationId"),
+		`"listDirs"`)
+	xCheckEqual(t, "", get("paths", rPath, "post", "operationId"),
+		`"postDirFile"`)
+	xCheckEqual(t, "", get("paths", rPath+"$meta", "get", "responses", "200",
+		"content", "application/json", "schema", "$ref"),
+		`"#/components/schemas/resource-dirs-files"`)
+	xCheckEqual(t, "", get("paths", rPath+"/versions/{versionid}", "delete",
+		"operationId"), `"deleteDirFileVersion"`)
+	xCheckEqual(t, "", get("components", "schemas", "resource-dirs-files",
+		"required"), `[
+  "owner"
+]`)
+	xCheckEqual(t, "", get("components", "schemas", "version-dirs-files",
+		"properties", "owner"), `{
+  "type": "string"
+}`)
+
+	xHTTP(t, reg, "GET", "/model?schema="+registry.OPENAPI+"/2.0", "", 400,
+		`Unsupported schema format: openapi/2.0
+`)
+}
+
 func TestHTTPReadOnlyResource(t *testing.T) {
 	reg := NewRegistry("TestHTTPReadOnlyResource")
 	defer PassDeleteReg(t, reg)
@@ -6875,9 +7003,23 @@ func TestHTTPReadOnlyResource(t *testing.T) {
 	xNoErr(t, err)
 	xCheck(t, d1 != nil, "d1 should not be nil")
 
-	f1, err := d1.AddResource("files", "f1", "v1")
+	// The Go APIs block writes too, unless the server says otherwise
+	_, err = d1.AddResource("files", "f1", "v1")
+	xCheckErr(t, err,
+		"Write operations to read-only resources are not allowed")
+	xNoErr(t, reg.Rollback())
+
+	stx, err := registry.NewTx()
+	xNoErr(t, err)
+	stx.WriteReadOnly = true
+	sreg, err := registry.FindRegistry(stx, reg.UID)
+	xNoErr(t, err)
+	sd1, err := sreg.FindGroup("dirs", "dir1", false)
+	xNoErr(t, err)
+	f1, err := sd1.AddResource("files", "f1", "v1")
 	xNoErr(t, err)
 	xCheck(t, f1 != nil, "f1 should not be nil")
+	xNoErr(t, stx.Commit())
 
 	xHTTP(t, reg, "GET", "/dirs/dir1/files", "", 200, `{
   "f1": {
@@ -6915,6 +7057,59 @@ func TestHTTPReadOnlyResource(t *testing.T) {
 		"Write operations to read-only resources are not allowed\n")
 	xHTTP(t, reg, "PUT", "/dirs/dir1/files/f1/versions/v1", "", 405,
 		"Write operations to read-only resources are not allowed\n")
+	xHTTP(t, reg, "PATCH", "/dirs/dir1/files/f1$meta", "{}", 405,
+		"Write operations to read-only resources are not allowed\n")
+	xHTTP(t, reg, "PATCH", "/dirs/dir1/files/f1/versions/v1$meta", "{}", 405,
+		"Write operations to read-only resources are not allowed\n")
+	xHTTP(t, reg, "DELETE", "/dirs/dir1/files/f1/versions/v1", "", 405,
+		"Write operations to read-only resources are not allowed\n")
+	xHTTP(t, reg, "DELETE", "/dirs/dir1/files/f1", "", 405,
+		"Write operations to read-only resources are not allowed\n")
+	xHTTP(t, reg, "DELETE", "/dirs/dir1/files", "", 405,
+		"Write operations to read-only resources are not allowed\n")
+
+	// Nested in a write to the Group
+	xHTTP(t, reg, "PUT", "/dirs/dir1?nested", `{"files":{"f2":{}}}`, 400,
+		"Write operations to read-only resources are not allowed\n")
+
+	// Deleting the Group would delete them too
+	xHTTP(t, reg, "DELETE", "/dirs/dir1", "", 405,
+		"Write operations to read-only resources are not allowed, "+
+			"Group \"dir1\" has \"files\" Resources\n")
+	xHTTP(t, reg, "DELETE", "/dirs", "", 405,
+		"Write operations to read-only resources are not allowed, "+
+			"Group \"dir1\" has \"files\" Resources\n")
+
+	cf1, err := d1.FindResource("files", "f1", false)
+	xNoErr(t, err)
+	cv1, err := cf1.FindVersion("v1", false)
+	xNoErr(t, err)
+
+	xCheckErr(t, cv1.SetSave("description", "hi"),
+		"Write operations to read-only resources are not allowed")
+	xCheckErr(t, cv1.Delete(""),
+		"Write operations to read-only resources are not allowed")
+	xCheckErr(t, cf1.Delete(),
+		"Write operations to read-only resources are not allowed")
+	xCheckErr(t, d1.Delete(),
+		"Write operations to read-only resources are not allowed, "+
+			"Group \"dir1\" has \"files\" Resources")
+	_, err = d1.AddResource("files", "f2", "v1")
+	xCheckErr(t, err,
+		"Write operations to read-only resources are not allowed")
+	xNoErr(t, reg.Rollback())
+
+	// But the server itself can still change them
+	f1, err = sd1.FindResource("files", "f1", false)
+	xNoErr(t, err)
+	xNoErr(t, f1.SetSave("description", "hi"))
+	xNoErr(t, f1.Delete())
+	_, err = sd1.AddResource("files", "f2", "v1")
+	xNoErr(t, err)
+	xNoErr(t, sd1.Delete())
+	xNoErr(t, stx.Commit())
+
+	xHTTP(t, reg, "GET", "/dirs/dir1", "", 404, "Not found\n")
 }
 
 func TestDefaultVersionThis(t *testing.T) {
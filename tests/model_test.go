@@ -1,6 +1,10 @@
 package tests
 
 import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/duglin/xreg-github/registry"
@@ -4137,3 +4141,507 @@ func TestMultModel2Create(t *testing.T) {
 	xCheckGet(t, reg, "?inline&oneline",
 		`{"dirs0":{},"dirs01":{},"dirs02":{},"dirs1":{"d1":{"files":{"f1":{"versions":{"v1":{},"v2":{}}}}},"d2":{"files":{"f2":{"versions":{"v1":{},"v1.1":{}}}}}},"dirs14":{},"dirs15":{},"dirs16":{},"dirs2":{"d2":{"files":{"f2":{"versions":{"v1":{}}}}}},"dirs3":{},"dirs4":{},"dirs5":{}}`)
 }
+
+func TestModelDiffData(t *testing.T) {
+	reg := NewRegistry("TestModelDiffData")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddAttr("size", registry.INTEGER)
+	gm.AddResourceModel("files", "file", 0, true, true, false)
+
+	d1, _ := reg.AddGroup("dirs", "d1")
+	xNoErr(t, d1.SetSave("size", 5))
+	_, err := d1.AddResource("files", "f1", "v1")
+	xNoErr(t, err)
+	_, err = reg.AddGroup("dirs", "d2")
+	xNoErr(t, err)
+	xNoErr(t, reg.Commit())
+
+	// Make a copy of the model and change the type of "size"
+	buf, err := json.Marshal(reg.Model)
+	xNoErr(t, err)
+	newM := &registry.Model{}
+	xNoErr(t, registry.Unmarshal(buf, newM))
+	newM.Groups["dirs"].Attributes["size"].Type = registry.STRING
+	xNoErr(t, newM.Verify())
+
+	changes := registry.DiffModels(reg.Model, newM)
+	xCheckEqual(t, "", len(changes), 1)
+	xCheckEqual(t, "", changes[0].String(), `breaking `+
+		`groups.dirs.attributes.size.type: Changed from "integer" to "string"`)
+
+	res, err := http.Get("http://localhost:8181/?inline=*")
+	xNoErr(t, err)
+	body, err := io.ReadAll(res.Body)
+	xNoErr(t, err)
+	data := map[string]any{}
+	xNoErr(t, json.Unmarshal(body, &data))
+
+	errs := registry.ValidateRegistryData(reg.Model, newM, data)
+	xCheckEqual(t, "", len(errs), 1)
+	xCheck(t, strings.HasPrefix(errs[0].Error(), "/dirs/d1: "),
+		"Wrong error: %s", errs[0])
+}
+
+func TestModelDryRunMigrations(t *testing.T) {
+	reg := NewRegistry("TestModelDryRunMigrations")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddAttr("size", registry.INTEGER)
+	rm, _ := gm.AddResourceModel("files", "file", 0, true, true, false)
+	rm.AddAttr("owner", registry.STRING)
+
+	d1, _ := reg.AddGroup("dirs", "d1")
+	xNoErr(t, d1.SetSave("size", 5))
+	f1, err := d1.AddResource("files", "f1", "v1")
+	xNoErr(t, err)
+	xNoErr(t, f1.SetSave("owner", "bob"))
+	_, err = d1.AddResource("files", "f2", "v1")
+	xNoErr(t, err)
+	xNoErr(t, reg.Commit())
+
+	get := func(path string) map[string]any {
+		t.Helper()
+		res, err := http.Get("http://localhost:8181/" + path)
+		xNoErr(t, err)
+		body, err := io.ReadAll(res.Body)
+		xNoErr(t, err)
+		obj := map[string]any{}
+		xNoErr(t, json.Unmarshal(body, &obj))
+		return obj
+	}
+
+	// Nothing is changed, just reported
+	xHTTP(t, reg, "PUT", "/model?dryrun", `{
+  "groups": {
+    "dirs": {
+      "plural": "dirs",
+      "singular": "dir",
+      "attributes": {
+        "size": { "name": "size", "type": "string" }
+      },
+      "resources": {
+        "files": {
+          "plural": "files",
+          "singular": "file",
+          "hasdocument": false,
+          "attributes": {
+            "owner": { "name": "owner", "type": "string", "enum": ["alice"] }
+          }
+        }
+      }
+    }
+  }
+}`, 200, `{
+  "changes": [
+    {
+      "kind": "breaking",
+      "path": "groups.dirs.attributes.size.type",
+      "message": "Changed from \"integer\" to \"string\""
+    },
+    {
+      "kind": "breaking",
+      "path": "groups.dirs.resources.files.attributes.owner.enum",
+      "message": "Values are now limited to: alice"
+    }
+  ],
+  "valid": false,
+  "violations": [
+    "/dirs/d1: Attribute \"size\" must be a string",
+    "/dirs/d1/files/f1/versions/v1: Attribute \"owner\"(bob) must be one of the enum values: alice"
+  ]
+}
+`)
+
+	xCheckEqual(t, "", get("dirs/d1")["size"], 5)
+
+	// Bad migrations are rejected
+	xHTTP(t, reg, "PUT", "/model", `{
+  "migrations": [ { "op": "move", "name": "size" } ]
+}`, 400, `Migration 0 has an invalid "op" value (move), must be one of: `+
+		`default, drop, rename, renametype`+"\n")
+
+	// Rename "size" and make "owner" required, with migrations the
+	// existing data is still valid
+	newModel := `{
+  "groups": {
+    "dirs": {
+      "plural": "dirs",
+      "singular": "dir",
+      "attributes": {
+        "bytes": { "name": "bytes", "type": "integer" }
+      },
+      "resources": {
+        "files": {
+          "plural": "files",
+          "singular": "file",
+          "hasdocument": false,
+          "attributes": {
+            "owner": { "name": "owner", "type": "string",
+                       "clientrequired": true, "serverrequired": true }
+          }
+        }
+      }
+    }
+  },
+  "migrations": [
+    { "op": "rename", "entity": "dirs", "name": "size", "newname": "bytes" },
+    { "op": "default", "entity": "dirs/files", "name": "owner",
+      "value": "nobody" }
+  ]
+}`
+
+	xHTTP(t, reg, "PUT", "/model?dryrun", newModel, 200, `{
+  "changes": [
+    {
+      "kind": "safe",
+      "path": "groups.dirs.attributes.bytes",
+      "message": "Attribute added"
+    },
+    {
+      "kind": "breaking",
+      "path": "groups.dirs.attributes.size",
+      "message": "Attribute removed, existing values will be invalid"
+    },
+    {
+      "kind": "breaking",
+      "path": "groups.dirs.resources.files.attributes.owner",
+      "message": "Attribute is now required"
+    }
+  ],
+  "valid": true,
+  "violations": []
+}
+`)
+
+	xCheckEqual(t, "", get("dirs/d1")["size"], 5)
+
+	req, err := http.NewRequest("PUT", "http://localhost:8181/model",
+		strings.NewReader(newModel))
+	xNoErr(t, err)
+	res, err := http.DefaultClient.Do(req)
+	xNoErr(t, err)
+	res.Body.Close()
+	xCheckEqual(t, "", res.StatusCode, 200)
+
+	d1Obj := get("dirs/d1")
+	xCheckEqual(t, "", d1Obj["bytes"], 5)
+	xCheck(t, d1Obj["size"] == nil, "size should be gone: %v", d1Obj)
+	xCheckEqual(t, "", get("dirs/d1/files/f1")["owner"], "bob")
+	xCheckEqual(t, "", get("dirs/d1/files/f2")["owner"], "nobody")
+
+	// The migrations aren't part of the model
+	xCheck(t, get("model")["migrations"] == nil, "migrations were saved")
+}
+
+func TestModelRenameTypes(t *testing.T) {
+	reg := NewRegistry("TestModelRenameTypes")
+	defer PassDeleteReg(t, reg)
+
+	reg.Model.AddAttr("ref", registry.XID)
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, false)
+	gm.AddResourceModel("logs", "log", 0, true, true, false)
+
+	d1, _ := reg.AddGroup("dirs", "d1")
+	_, err := d1.AddResource("files", "f1", "v1")
+	xNoErr(t, err)
+	_, err = d1.AddResource("logs", "l1", "v1")
+	xNoErr(t, err)
+	xNoErr(t, reg.SetSave("ref", "/dirs/d1/files/f1"))
+	xNoErr(t, reg.Commit())
+
+	get := func(path string) (int, map[string]any) {
+		t.Helper()
+		res, err := http.Get("http://localhost:8181/" + path)
+		xNoErr(t, err)
+		body, err := io.ReadAll(res.Body)
+		xNoErr(t, err)
+		obj := map[string]any{}
+		if res.StatusCode == 200 {
+			xNoErr(t, json.Unmarshal(body, &obj))
+		}
+		return res.StatusCode, obj
+	}
+
+	// Rename "dirs" to "folders" and move "logs" to a new "archives"
+	newModel := `{
+  "attributes": {
+    "ref": { "name": "ref", "type": "xid" }
+  },
+  "groups": {
+    "folders": {
+      "plural": "folders",
+      "singular": "folder",
+      "resources": {
+        "files": { "plural": "files", "singular": "file",
+                   "hasdocument": false }
+      }
+    },
+    "archives": {
+      "plural": "archives",
+      "singular": "archive",
+      "resources": {
+        "logs": { "plural": "logs", "singular": "log",
+                  "hasdocument": false }
+      }
+    }
+  },
+  "migrations": [
+    { "op": "renametype", "name": "dirs", "newname": "folders" },
+    { "op": "renametype", "name": "folders/logs", "newname": "archives/logs" }
+  ]
+}`
+
+	xHTTP(t, reg, "PUT", "/model?dryrun", newModel, 200, `{
+  "changes": [
+    {
+      "kind": "safe",
+      "path": "groups.archives",
+      "message": "Group type added"
+    },
+    {
+      "kind": "safe",
+      "path": "groups.archives.resources.logs",
+      "message": "Resource type renamed from \"dirs/logs\""
+    },
+    {
+      "kind": "safe",
+      "path": "groups.folders",
+      "message": "Group type renamed from \"dirs\""
+    }
+  ],
+  "valid": true,
+  "violations": []
+}
+`)
+
+	code, _ := get("dirs/d1/files/f1")
+	xCheckEqual(t, "", code, 200)
+
+	req, err := http.NewRequest("PUT", "http://localhost:8181/model",
+		strings.NewReader(newModel))
+	xNoErr(t, err)
+	res, err := http.DefaultClient.Do(req)
+	xNoErr(t, err)
+	res.Body.Close()
+	xCheckEqual(t, "", res.StatusCode, 200)
+
+	// Nothing was lost, it's all just been moved
+	code, _ = get("dirs/d1")
+	xCheckEqual(t, "", code, 404)
+
+	code, obj := get("folders/d1/files/f1/versions/v1")
+	xCheckEqual(t, "", code, 200)
+	xCheckEqual(t, "", obj["self"],
+		"http://localhost:8181/folders/d1/files/f1/versions/v1")
+
+	code, obj = get("archives/d1/logs/l1")
+	xCheckEqual(t, "", code, 200)
+	xCheckEqual(t, "", obj["versionscount"], 1)
+
+	code, obj = get("folders/d1")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, obj["logsurl"] == nil, "logs should be gone: %v", obj)
+
+	_, obj = get("")
+	xCheckEqual(t, "", obj["ref"], "/folders/d1/files/f1")
+}
+
+func TestModelHistory(t *testing.T) {
+	reg := NewRegistry("TestModelHistory")
+	defer PassDeleteReg(t, reg)
+
+	// Revision 1 is the empty model from when the Registry was created
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddAttr("size", registry.INTEGER)
+	xNoErr(t, reg.Commit())
+
+	do := func(method string, path string, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, "http://localhost:8181/"+path,
+			strings.NewReader(body))
+		xNoErr(t, err)
+		req.Header.Add("xRegistry~User", "tester")
+		res, err := http.DefaultClient.Do(req)
+		xNoErr(t, err)
+		buf, err := io.ReadAll(res.Body)
+		xNoErr(t, err)
+		return res.StatusCode, string(buf)
+	}
+	getModel := func(path string) map[string]any {
+		t.Helper()
+		code, body := do("GET", path, "")
+		xCheckEqual(t, "", code, 200)
+		model := map[string]any{}
+		xNoErr(t, json.Unmarshal([]byte(body), &model))
+		return model
+	}
+
+	code, body := do("PUT", "model", `{
+  "groups": {
+    "dirs": {
+      "plural": "dirs",
+      "singular": "dir",
+      "resources": {
+        "files": { "plural": "files", "singular": "file" }
+      }
+    }
+  }
+}`)
+	xCheckEqual(t, body, code, 200)
+
+	xHTTP(t, reg, "GET", "/model/history", "", 200, `[
+  {
+    "epoch": 1,
+    "createdat": "2024-01-01T12:00:01Z"
+  },
+  {
+    "epoch": 2,
+    "createdat": "2024-01-01T12:00:02Z"
+  },
+  {
+    "epoch": 3,
+    "createdby": "tester",
+    "createdat": "2024-01-01T12:00:03Z"
+  }
+]
+`)
+
+	// Older revisions are still there
+	groups := getModel("model?revision=2")["groups"].(map[string]any)
+	xCheck(t, groups["dirs"].(map[string]any)["resources"] == nil,
+		"Revision 2 shouldn't have resources: %v", groups)
+	xCheck(t, groups["dirs"].(map[string]any)["attributes"].(map[string]any)["size"] != nil,
+		"Revision 2 should have size: %v", groups)
+
+	groups = getModel("model")["groups"].(map[string]any)
+	xCheck(t, groups["dirs"].(map[string]any)["resources"] != nil,
+		"Latest model should have resources: %v", groups)
+
+	xHTTP(t, reg, "GET", "/model?revision=9", "", 404,
+		"Model revision 9 not found\n")
+	xHTTP(t, reg, "GET", "/model?revision=abc", "", 400,
+		`Invalid "revision" value: "abc"`+"\n")
+	xHTTP(t, reg, "POST", "/model/rollback?revision=0", "", 400,
+		`Invalid "revision" value: "0"`+"\n")
+	xHTTP(t, reg, "PUT", "/model/rollback?revision=2", "", 404,
+		"Not found\n")
+
+	// A dry-run of the rollback doesn't change anything
+	code, body = do("POST", "model/rollback?revision=2&dryrun", "")
+	xCheckEqual(t, body, code, 200)
+	xCheck(t, strings.Contains(body, `"path": "groups.dirs.resources.files"`),
+		"Missing change: %s", body)
+	groups = getModel("model")["groups"].(map[string]any)
+	xCheck(t, groups["dirs"].(map[string]any)["resources"] != nil,
+		"Dry-run shouldn't change the model: %v", groups)
+
+	// Rolling back creates a new revision
+	code, body = do("POST", "model/rollback?revision=2", "")
+	xCheckEqual(t, body, code, 200)
+	groups = getModel("model")["groups"].(map[string]any)
+	xCheck(t, groups["dirs"].(map[string]any)["resources"] == nil,
+		"Rollback didn't remove resources: %v", groups)
+
+	code, body = do("GET", "model/history", "")
+	xCheckEqual(t, body, code, 200)
+	xCheck(t, strings.Contains(body, `"epoch": 4,`), "Missing rev 4: %s", body)
+
+	// And via the Go APIs
+	reg.LoadModel()
+	xNoErr(t, reg.RollbackModel(1))
+	xNoErr(t, reg.Commit())
+	xCheck(t, getModel("model")["groups"] == nil, "Should be no groups")
+	xCheckErr(t, reg.RollbackModel(9), "Model revision 9 not found")
+	reg.Rollback()
+}
+
+func TestModelRollback(t *testing.T) {
+	reg := NewRegistry("TestModelRollback")
+	defer PassDeleteReg(t, reg)
+
+	// Revision 1 is the empty model, this is revision 2
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddAttr("size", registry.INTEGER)
+	xNoErr(t, reg.Commit())
+
+	// Revision 3
+	_, err := gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, err)
+	xNoErr(t, reg.Commit())
+
+	xNoErr(t, reg.RollbackModel(2))
+	xNoErr(t, reg.Commit())
+
+	gm = reg.Model.FindGroupModel("dirs")
+	xCheck(t, gm != nil && gm.Attributes["size"] != nil,
+		"Missing dirs.size: %s", registry.ToJSON(reg.Model))
+	xCheck(t, len(gm.Resources) == 0, "Should be no resources: %s",
+		registry.ToJSON(reg.Model))
+
+	// The old revisions are still there, the rollback added a new one
+	epoch, err := reg.GetModelEpoch()
+	xNoErr(t, err)
+	xCheckEqual(t, "", epoch, 4)
+
+	rev2, err := reg.GetModelRevision(2)
+	xNoErr(t, err)
+	rev4, err := reg.GetModelRevision(4)
+	xNoErr(t, err)
+	xCheckEqual(t, "", registry.ToJSON(rev4.Model.Groups),
+		registry.ToJSON(rev2.Model.Groups))
+
+	rev3, err := reg.GetModelRevision(3)
+	xNoErr(t, err)
+	xCheck(t, len(rev3.Model.Groups["dirs"].Resources) == 1,
+		"Revision 3 changed: %s", registry.ToJSON(rev3.Model))
+
+	// Rolling back to the current model is ok, it's just another revision
+	xNoErr(t, reg.RollbackModel(4))
+	xNoErr(t, reg.Commit())
+	epoch, err = reg.GetModelEpoch()
+	xNoErr(t, err)
+	xCheckEqual(t, "", epoch, 5)
+
+	xCheckErr(t, reg.RollbackModel(0), "Model revision 0 not found")
+	xCheckErr(t, reg.RollbackModel(6), "Model revision 6 not found")
+	xNoErr(t, reg.Rollback())
+}
+
+func TestModelEpochFlag(t *testing.T) {
+	reg := NewRegistry("TestModelEpochFlag")
+	defer PassDeleteReg(t, reg)
+
+	// Revision 1 is the empty model, this is revision 2
+	reg.Model.AddGroupModel("dirs", "dir")
+	xNoErr(t, reg.Commit())
+
+	newModel := `{"groups":{"dirs":{"plural":"dirs","singular":"dir"},` +
+		`"foos":{"plural":"foos","singular":"foo"}}}`
+
+	xHTTP(t, reg, "PUT", "/model?epoch=1", newModel, 400,
+		"Epoch value for the model must be 2\n")
+	xHTTP(t, reg, "PUT", "/model?epoch=abc", newModel, 400,
+		`Epoch value "abc" must be an UINTEGER`+"\n")
+	xHTTP(t, reg, "PUT", "/model?epoch=-1", newModel, 400,
+		`Epoch value "-1" must be an UINTEGER`+"\n")
+	xCheck(t, reg.Model.Groups["foos"] == nil, "foos shouldn't exist")
+
+	req, err := http.NewRequest("PUT", "http://localhost:8181/model?epoch=2",
+		strings.NewReader(newModel))
+	xNoErr(t, err)
+	res, err := http.DefaultClient.Do(req)
+	xNoErr(t, err)
+	buf, _ := io.ReadAll(res.Body)
+	xCheckEqual(t, string(buf), res.StatusCode, 200)
+
+	reg.LoadModel()
+	xCheck(t, reg.Model.Groups["foos"] != nil, "foos should exist")
+
+	// Now the old epoch is stale
+	xHTTP(t, reg, "PUT", "/model?epoch=2", newModel, 400,
+		"Epoch value for the model must be 3\n")
+}
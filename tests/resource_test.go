@@ -265,3 +265,142 @@ func TestResourceMaxVersions(t *testing.T) {
 	xCheck(t, len(vers) == 1, "Should be 1, but is: %s", ToJSON(vers))
 	xCheck(t, vers[0].Object["id"] == "v5", "0=v5")
 }
+
+func TestResourceDeprecated(t *testing.T) {
+	reg := NewRegistry("TestResourceDeprecated")
+	defer PassDeleteReg(t, reg)
+	xCheck(t, reg != nil, "can't create reg")
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	_, err := gm.AddResourceModelFull(&registry.ResourceModel{
+		Plural:                   "files",
+		Singular:                 "file",
+		HasDocument:              registry.PtrBool(true),
+		DeprecatedBlocksVersions: true,
+	})
+	xNoErr(t, err)
+
+	d1, _ := reg.AddGroup("dirs", "d1")
+	f1, _ := d1.AddResource("files", "f1", "v1")
+	f1.AddVersion("v2")
+	d1.AddResource("files", "f2", "v1")
+	xNoErr(t, reg.Commit())
+
+	xCheckErr(t, f1.SetDeprecated(map[string]any{"removal": "soon"}),
+		`Attribute "deprecated.removal" is a malformed timestamp`)
+	xNoErr(t, reg.Rollback())
+
+	xNoErr(t, f1.SetDeprecated(map[string]any{
+		"effective":   "2025-01-01T00:00:00Z",
+		"removal":     "2026-06-01T00:00:00Z",
+		"alternative": "http://example.com/f2",
+	}))
+	v1, _ := f1.FindVersion("v1", false)
+	xNoErr(t, v1.SetSave("deprecated",
+		map[string]any{"alternative": "http://example.com/v2"}))
+	xNoErr(t, reg.Commit())
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/dirs/d1/files/f1",
+		Method: "GET",
+		Code:   200,
+		ResHeaders: []string{
+			"xRegistry-id: f1",
+			"xRegistry-epoch: 2",
+			"xRegistry-defaultversionid: v2",
+			"xRegistry-defaultversionurl: http://localhost:8181/dirs/d1/files/f1/versions/v2",
+			"xRegistry-self: http://localhost:8181/dirs/d1/files/f1",
+			"xRegistry-createdat: 2024-01-01T12:00:01Z",
+			"xRegistry-modifiedat: 2024-01-01T12:00:02Z",
+			"xRegistry-versionscount: 2",
+			"xRegistry-versionsurl: http://localhost:8181/dirs/d1/files/f1/versions",
+			"Deprecation: @1735689600",
+			"Sunset: Mon, 01 Jun 2026 00:00:00 GMT",
+			"Link: <http://example.com/f2>; rel=\"successor-version\"",
+		},
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/dirs/d1/files/f1/versions/v1$meta",
+		Method: "GET",
+		Code:   200,
+		ResHeaders: []string{
+			"Deprecation: true",
+			"Link: <http://example.com/v2>; rel=\"successor-version\"",
+		},
+		ResBody: `{
+  "id": "v1",
+  "epoch": 2,
+  "self": "http://localhost:8181/dirs/d1/files/f1/versions/v1$meta",
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:02Z",
+  "deprecated": {
+    "alternative": "http://example.com/v2"
+  }
+}
+`,
+	})
+
+	// The default Version's "deprecated" isn't the Resource's
+	xCheckGet(t, reg, "dirs/d1/files?deprecated", `{
+  "f1": {
+    "id": "f1",
+    "epoch": 2,
+    "self": "http://localhost:8181/dirs/d1/files/f1$meta",
+    "defaultversionid": "v2",
+    "defaultversionurl": "http://localhost:8181/dirs/d1/files/f1/versions/v2$meta",
+    "createdat": "2024-01-01T12:00:01Z",
+    "modifiedat": "2024-01-01T12:00:02Z",
+    "deprecated": {
+      "alternative": "http://example.com/f2",
+      "effective": "2025-01-01T00:00:00Z",
+      "removal": "2026-06-01T00:00:00Z"
+    },
+
+    "versionscount": 2,
+    "versionsurl": "http://localhost:8181/dirs/d1/files/f1/versions"
+  }
+}
+`)
+	xCheckGet(t, reg, "dirs/d1/files/f1/versions?deprecated",
+		`{
+  "v1": {
+    "id": "v1",
+    "epoch": 2,
+    "self": "http://localhost:8181/dirs/d1/files/f1/versions/v1$meta",
+    "createdat": "2024-01-01T12:00:01Z",
+    "modifiedat": "2024-01-01T12:00:02Z",
+    "deprecated": {
+      "alternative": "http://example.com/v2"
+    }
+  }
+}
+`)
+	xCheckGet(t, reg, "dirs/d1/files?deprecated&filter=id=f2", "{}\n")
+
+	// The model says deprecated Resources can't get new Versions
+	_, err = f1.AddVersion("v3")
+	xCheckErr(t, err, `Can't add a new Version to Resource "f1", it's deprecated`)
+	xNoErr(t, reg.Rollback())
+
+	// Updating an existing Version is still ok
+	xNoErr(t, v1.SetSave("description", "old"))
+
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1$meta", `{"deprecated":null}`,
+		200, `{
+  "id": "f1",
+  "epoch": 3,
+  "self": "http://localhost:8181/dirs/d1/files/f1$meta",
+  "defaultversionid": "v2",
+  "defaultversionurl": "http://localhost:8181/dirs/d1/files/f1/versions/v2$meta",
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:02Z",
+
+  "versionscount": 2,
+  "versionsurl": "http://localhost:8181/dirs/d1/files/f1/versions"
+}
+`)
+	f1.Refresh()
+	_, err = f1.AddVersion("v3")
+	xNoErr(t, err)
+}
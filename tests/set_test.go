@@ -444,3 +444,95 @@ func TestSetLabels(t *testing.T) {
 }
 `)
 }
+
+func TestSetImmutable(t *testing.T) {
+	reg := NewRegistry("TestSetImmutable")
+	defer PassDeleteReg(t, reg)
+
+	_, err := reg.Model.AddAttribute(&registry.Attribute{
+		Name:      "regid",
+		Type:      registry.STRING,
+		Immutable: true,
+	})
+	xNoErr(t, err)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	rm, _ := gm.AddResourceModel("files", "file", 0, true, true, true)
+	_, err = rm.AddAttribute(&registry.Attribute{
+		Name:      "owner",
+		Type:      registry.STRING,
+		Immutable: true,
+	})
+	xNoErr(t, err)
+	obj, err := rm.AddAttrObj("info")
+	xNoErr(t, err)
+	_, err = obj.AddAttribute(&registry.Attribute{
+		Name:      "key",
+		Type:      registry.STRING,
+		Immutable: true,
+	})
+	xNoErr(t, err)
+	_, err = obj.AddAttr("note", registry.STRING)
+	xNoErr(t, err)
+
+	dir, _ := reg.AddGroup("dirs", "d1")
+	file, _ := dir.AddResource("files", "f1", "v1")
+	v1, _ := file.FindVersion("v1", false)
+
+	// Setting it the first time, or to the same value, is ok
+	xNoErr(t, reg.SetSave("regid", "r1"))
+	xNoErr(t, reg.SetSave("regid", "r1"))
+	xNoErr(t, v1.SetSave("owner", "alice"))
+	xNoErr(t, v1.SetSave("owner", "alice"))
+	xNoErr(t, v1.SetSave("info.key", "k1"))
+	xNoErr(t, v1.SetSave("info.note", "n1"))
+	xNoErr(t, v1.SetSave("info.note", "n2"))
+	xNoErr(t, reg.Commit())
+
+	xCheckErr(t, reg.SetSave("regid", "r2"),
+		`Attribute "regid" is immutable and can't be changed`)
+	xCheckErr(t, v1.SetSave("owner", "bob"),
+		`Attribute "owner" is immutable and can't be changed`)
+	xCheckErr(t, v1.SetSave("owner", nil),
+		`Attribute "owner" is immutable and can't be changed`)
+	xCheckErr(t, v1.SetSave("info.key", "k2"),
+		`Attribute "info.key" is immutable and can't be changed`)
+	xCheckErr(t, v1.SetSave("info", nil),
+		`Attribute "info.key" is immutable and can't be changed`)
+	xNoErr(t, reg.Rollback())
+
+	// New Versions can have their own value
+	v2, err := file.AddVersion("v2")
+	xNoErr(t, err)
+	xNoErr(t, v2.SetSave("owner", "bob"))
+
+	// The same rules apply to HTTP requests
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1$meta",
+		`{"owner":"bob","info":{"key":"k1"}}`, 400,
+		`Attribute "owner" is immutable and can't be changed`+"\n")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1$meta",
+		`{"owner":"alice"}`, 400,
+		`Attribute "info.key" is immutable and can't be changed`+"\n")
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/versions/v1$meta",
+		`{"info":{}}`, 400,
+		`Attribute "info.key" is immutable and can't be changed`+"\n")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1$meta", `{}`, 400,
+		`Attribute "owner" is immutable and can't be changed`+"\n")
+	xHTTP(t, reg, "PATCH", "/", `{"regid":null}`, 400,
+		`Attribute "regid" is immutable and can't be changed`+"\n")
+
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/versions/v1$meta",
+		`{"description":"hi","info":{"key":"k1"}}`, 200, `{
+  "id": "v1",
+  "epoch": 2,
+  "self": "http://localhost:8181/dirs/d1/files/f1/versions/v1$meta",
+  "description": "hi",
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:02Z",
+  "info": {
+    "key": "k1"
+  },
+  "owner": "alice"
+}
+`)
+}
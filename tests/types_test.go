@@ -556,3 +556,190 @@ func TestWildcard2LayersTypes(t *testing.T) {
 	xCheck(t, val == nil, fmt.Sprintf("set obj.myany.bogus.k1.k2: %v", val))
 
 }
+
+func TestXIDTypes(t *testing.T) {
+	reg := NewRegistry("TestXIDTypes")
+	defer PassDeleteReg(t, reg)
+	xCheck(t, reg != nil, "can't create reg")
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, false)
+
+	gm, _ = reg.Model.AddGroupModel("msgs", "msg")
+	rm, _ := gm.AddResourceModel("events", "event", 0, true, true, false)
+	_, err := rm.AddAttr("schemaref", registry.XID)
+	xNoErr(t, err)
+	_, err = rm.AddAttribute(&registry.Attribute{
+		Name:     "parent",
+		Type:     registry.XID,
+		OnDelete: registry.ONDELETE_CASCADE,
+	})
+	xNoErr(t, err)
+
+	d1, _ := reg.AddGroup("dirs", "d1")
+	d1.AddResource("files", "f1", "v1")
+	d1.AddResource("files", "f2", "v1")
+
+	m1, _ := reg.AddGroup("msgs", "m1")
+	e1, _ := m1.AddResource("events", "e1", "v1")
+	xNoErr(t, e1.SetSave("schemaref", "/dirs/d1/files/f1"))
+	e2, _ := m1.AddResource("events", "e2", "v1")
+	xNoErr(t, e2.SetSave("parent", "/dirs/d1/files/f2"))
+	xNoErr(t, reg.Commit())
+
+	// Dangling and malformed references
+	xCheckErr(t, e1.SetSave("schemaref", "/dirs/d1/files/fx"),
+		`Attribute "schemaref" references an unknown entity `+
+			`(/dirs/d1/files/fx)`)
+	xCheckErr(t, e1.SetSave("schemaref", "dirs/d1"),
+		`Attribute "schemaref" has an invalid xid (dirs/d1), must be of `+
+			`the form /GROUPS/gID[/RESOURCES/rID[/versions/vID]]`)
+	xCheckErr(t, e1.SetSave("schemaref", "/dirs/d1/files"),
+		`Attribute "schemaref" has an invalid xid (/dirs/d1/files), must `+
+			`be of the form /GROUPS/gID[/RESOURCES/rID[/versions/vID]]`)
+	xNoErr(t, reg.Rollback())
+
+	xCheckGet(t, reg, "msgs/m1/events/e1?inline=schemaref", `{
+  "id": "e1",
+  "epoch": 1,
+  "self": "http://localhost:8181/msgs/m1/events/e1",
+  "defaultversionid": "v1",
+  "defaultversionurl": "http://localhost:8181/msgs/m1/events/e1/versions/v1",
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:01Z",
+  "schemaref": {
+    "id": "f1",
+    "epoch": 1,
+    "self": "http://localhost:8181/dirs/d1/files/f1",
+    "defaultversionid": "v1",
+    "defaultversionurl": "http://localhost:8181/dirs/d1/files/f1/versions/v1",
+    "createdat": "2024-01-01T12:00:01Z",
+    "modifiedat": "2024-01-01T12:00:01Z"
+  },
+
+  "versionscount": 1,
+  "versionsurl": "http://localhost:8181/msgs/m1/events/e1/versions"
+}
+`)
+	xCheckGet(t, reg, "msgs/m1/events/e1?inline=foo",
+		"Invalid 'inline' value: msgs.events.foo\n")
+
+	// "block" is the default
+	xHTTP(t, reg, "DELETE", "/dirs/d1/files/f1", "", 409,
+		`Can't delete "/dirs/d1/files/f1", it's referenced by `+
+			`"/msgs/m1/events/e1/versions/v1"`+"\n")
+	xHTTP(t, reg, "DELETE", "/dirs/d1", "", 409,
+		`Can't delete "/dirs/d1", it's referenced by `+
+			`"/msgs/m1/events/e1/versions/v1"`+"\n")
+
+	// "cascade" deletes the referencing Version, and since it's the last
+	// one the Resource goes too
+	f2, err := d1.FindResource("files", "f2", false)
+	xNoErr(t, err)
+	xNoErr(t, f2.Delete())
+	e2, err = m1.FindResource("events", "e2", false)
+	xNoErr(t, err)
+	xCheck(t, e2 == nil, "e2 should be gone")
+
+	// Once the reference is removed the delete is ok
+	xNoErr(t, e1.SetSave("schemaref", nil))
+	xHTTP(t, reg, "DELETE", "/dirs/d1/files/f1", "", 204, "")
+}
+
+func TestAttributeConstraints(t *testing.T) {
+	reg := NewRegistry("TestAttributeConstraints")
+	defer PassDeleteReg(t, reg)
+	xCheck(t, reg != nil, "can't create reg")
+
+	attrs := []*registry.Attribute{
+		{Name: "code", Type: registry.STRING, Pattern: "^[A-Z]{3}$"},
+		{Name: "name", Type: registry.STRING, MinLength: registry.PtrInt(2),
+			MaxLength: registry.PtrInt(4)},
+		{Name: "port", Type: registry.INTEGER,
+			Minimum: registry.PtrFloat(1), Maximum: registry.PtrFloat(65535)},
+		{Name: "ratio", Type: registry.DECIMAL,
+			Maximum: registry.PtrFloat(0.5)},
+		{Name: "tags", Type: registry.ARRAY, MinItems: registry.PtrInt(1),
+			MaxItems: registry.PtrInt(2),
+			Item:     &registry.Item{Type: registry.STRING}},
+		{Name: "env", Type: registry.MAP, MaxItems: registry.PtrInt(1),
+			Item: &registry.Item{Type: registry.STRING}},
+		{Name: "email", Type: registry.STRING, Format: registry.FORMAT_EMAIL},
+		{Name: "host", Type: registry.STRING,
+			Format: registry.FORMAT_HOSTNAME},
+		{Name: "ip4", Type: registry.STRING, Format: registry.FORMAT_IPV4},
+		{Name: "ip6", Type: registry.STRING, Format: registry.FORMAT_IPV6},
+		{Name: "ver", Type: registry.STRING, Format: registry.FORMAT_SEMVER},
+		{Name: "uid", Type: registry.STRING, Format: registry.FORMAT_UUID},
+		{Name: "day", Type: registry.STRING, Format: registry.FORMAT_DATE},
+	}
+	for _, attr := range attrs {
+		_, err := reg.Model.AddAttribute(attr)
+		xNoErr(t, err)
+	}
+
+	type Test struct {
+		name string
+		val  any
+		err  string
+	}
+	tests := []Test{
+		{"code", "ABC", ""},
+		{"code", "AB", `Attribute "code" must match the pattern "^[A-Z]{3}$"`},
+		{"name", "abcd", ""},
+		{"name", "héé", ""},
+		{"name", "a", `Attribute "name" must be at least 2 characters long`},
+		{"name", "abcde", `Attribute "name" must be at most 4 characters long`},
+		{"port", 80, ""},
+		{"port", 0, `Attribute "port" must be >= 1`},
+		{"port", 70000, `Attribute "port" must be <= 65535`},
+		{"ratio", 0.5, ""},
+		{"ratio", 0.51, `Attribute "ratio" must be <= 0.5`},
+		{"tags", []any{"a"}, ""},
+		{"tags", []any{}, `Attribute "tags" must have at least 1 items`},
+		{"tags", []any{"a", "b", "c"},
+			`Attribute "tags" must have at most 2 items`},
+		{"env", map[string]any{"a": "b"}, ""},
+		{"env", map[string]any{"a": "b", "c": "d"},
+			`Attribute "env" must have at most 1 items`},
+		{"email", "me@example.com", ""},
+		{"email", "me", `Attribute "email" must be a valid email`},
+		{"host", "example.com", ""},
+		{"host", "-bad.com", `Attribute "host" must be a valid hostname`},
+		{"ip4", "10.0.0.1", ""},
+		{"ip4", "::1", `Attribute "ip4" must be a valid ipv4`},
+		{"ip6", "::1", ""},
+		{"ip6", "10.0.0.1", `Attribute "ip6" must be a valid ipv6`},
+		{"ver", "1.2.3-rc.1", ""},
+		{"ver", "1.2", `Attribute "ver" must be a valid semver`},
+		{"uid", "123e4567-e89b-12d3-a456-426614174000", ""},
+		{"uid", "123e4567", `Attribute "uid" must be a valid uuid`},
+		{"day", "2024-02-29", ""},
+		{"day", "2023-02-29", `Attribute "day" must be a valid date`},
+	}
+
+	for _, test := range tests {
+		err := reg.SetSave(test.name, test.val)
+		if test.err == "" {
+			xNoErr(t, err)
+			xNoErr(t, reg.SetSave(test.name, nil))
+		} else {
+			xCheckErr(t, err, test.err)
+		}
+	}
+
+	// Inconsistent constraints are rejected when the model is updated
+	xHTTP(t, reg, "PUT", "/model", `{
+  "attributes": {
+    "port": {
+      "name": "port",
+      "type": "integer",
+      "minimum": 10,
+      "maximum": 1
+    }
+  }
+}`, 400, `"model.port" "minimum" must be <= "maximum"`+"\n")
+
+	xHTTP(t, reg, "PUT", "/", `{"code":"abc"}`, 400,
+		`Attribute "code" must match the pattern "^[A-Z]{3}$"`+"\n")
+}
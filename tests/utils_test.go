@@ -8,6 +8,7 @@ import (
 	"net/http"
 	gourl "net/url"
 	"os"
+	"os/exec"
 	"path"
 	"reflect"
 	"regexp"
@@ -239,6 +240,43 @@ func xJSONCheck(t *testing.T, gotObj any, expObj any) {
 	xCheckEqual(t, "", got, exp)
 }
 
+// Runs the "xr" CLI, from the root of the repo, against the test server.
+// "stdin" is sent to it and "env" (NAME=VALUE) is added to its environment.
+func xXRCmd(t *testing.T, stdin string, env []string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("../xr", args...)
+	cmd.Env = append(os.Environ(), "XR_SERVER=http://localhost:8181")
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func xXR(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	return xXRCmd(t, "", nil, args...)
+}
+
+// Same as xXR but it must work, and just the output is returned
+func xXRMust(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := xXR(t, args...)
+	xCheck(t, err == nil, "xr %s: %v\n%s", strings.Join(args, " "), err, out)
+	return out
+}
+
+// Same as xXRMust but the output is parsed as a JSON object, an empty
+// output is an empty object
+func xXRJSON(t *testing.T, args ...string) map[string]any {
+	t.Helper()
+	out := xXRMust(t, args...)
+	obj := map[string]any{}
+	if len(out) > 0 {
+		xNoErr(t, json.Unmarshal([]byte(out), &obj))
+	}
+	return obj
+}
+
 func OneLine(buf []byte) []byte {
 	buf = RemoveProps(buf)
 
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/duglin/xreg-github/registry"
@@ -481,3 +482,492 @@ func TestVersionRequiredFields(t *testing.T) {
 	err = v1.SetSave("clireq", "again")
 	xNoErr(t, err)
 }
+
+func TestVersionCompatibility(t *testing.T) {
+	reg := NewRegistry("TestVersionCompatibility")
+	defer PassDeleteReg(t, reg)
+	xCheck(t, reg != nil, "can't create reg")
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	_, err := gm.AddResourceModelFull(&registry.ResourceModel{
+		Plural:        "files",
+		Singular:      "file",
+		Compatibility: "sideways",
+	})
+	xCheckErr(t, err, `Resource "files" has an invalid 'compatibility' `+
+		`value (sideways). Must be one of none, backward, forward, full`)
+
+	_, err = gm.AddResourceModelFull(&registry.ResourceModel{
+		Plural:        "files",
+		Singular:      "file",
+		Compatibility: registry.COMPAT_BACKWARD,
+	})
+	xNoErr(t, err)
+
+	d1, _ := reg.AddGroup("dirs", "d1")
+	f1, err := d1.AddResourceWithObject("files", "f1", "v1", registry.Object{
+		"file": `{"properties":{"a":{"type":"string"}}}`,
+	}, false, true)
+	xNoErr(t, err)
+	xNoErr(t, reg.Commit())
+
+	// Adding a required property breaks backward compatibility
+	_, _, err = f1.UpsertVersionWithObject("v2", registry.Object{
+		"file": `{"properties":{"a":{"type":"string"}},"required":["a"]}`,
+	}, registry.ADD_ADD)
+	xCheckErr(t, err, `Version "v2" is not backward compatible with `+
+		`Version "v1": backward: #: property "a" is now required`)
+	xNoErr(t, reg.Rollback())
+
+	// Dry-run should report the issue but not change anything
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2?checkcompat",
+		`{"properties":{"a":{"type":"string"}},"required":["a"]}`, 200,
+		`{
+  "compatible": false,
+  "issues": [
+    "dirs/d1/files/f1/versions/v2: backward: #: property \"a\" is now required"
+  ]
+}
+`)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2?checkcompat",
+		`{"properties":{"a":{"type":"string"},"b":{}}}`, 200,
+		`{
+  "compatible": true,
+  "issues": []
+}
+`)
+
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1/versions/v2", ``, 404,
+		"Not found\n")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2",
+		`{"properties":{"a":{"type":"string"}},"required":["a"]}`, 400,
+		`Version "v2" is not backward compatible with Version "v1": `+
+			`backward: #: property "a" is now required
+`)
+
+	_, _, err = f1.UpsertVersionWithObject("v2", registry.Object{
+		"file": `{"properties":{"a":{"type":"string"},"b":{}}}`,
+	}, registry.ADD_ADD)
+	xNoErr(t, err)
+	xNoErr(t, reg.Commit())
+
+	// Replacing the document of an existing Version is checked too, even
+	// if it's the default Version
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2",
+		`{"properties":{"a":{"type":"string"}},"required":["a"]}`, 400,
+		`Version "v2" is not backward compatible with Version "v2": `+
+			`backward: #: property "a" is now required
+`)
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1",
+		`{"properties":{"a":{"type":"string"},"b":{}},"required":["b"]}`,
+		400, `Version "v1" is not backward compatible with Version "v2": `+
+			`backward: #: property "b" is now required
+`)
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1",
+		`{"properties":{"a":{"type":"string"},"b":{}},"required":["b"]}`,
+		400, `Version "v2" is not backward compatible with Version "v2": `+
+			`backward: #: property "b" is now required
+`)
+
+	// Nothing is saved on a failure, even if the error is ignored
+	_, _, err = f1.UpsertVersionWithObject("v3", registry.Object{
+		"file": `{"properties":{"a":{"type":"string"}},"required":["a"]}`,
+	}, registry.ADD_ADD)
+	xCheck(t, err != nil, "Should have failed")
+	xNoErr(t, reg.Commit())
+	v3, err := f1.FindVersion("v3", false)
+	xNoErr(t, err)
+	xCheck(t, v3 == nil, "v3 should not exist")
+	xCheckGet(t, reg, "dirs/d1/files/f1/versions/v2",
+		`{"properties":{"a":{"type":"string"},"b":{}}}`)
+}
+
+func TestVersionIdStrategy(t *testing.T) {
+	reg := NewRegistry("TestVersionIdStrategy")
+	defer PassDeleteReg(t, reg)
+	xCheck(t, reg != nil, "can't create reg")
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	_, err := gm.AddResourceModelFull(&registry.ResourceModel{
+		Plural:            "files",
+		Singular:          "file",
+		VersionIdStrategy: "random",
+	})
+	xCheckErr(t, err, `Resource "files" has an invalid 'versionidstrategy' `+
+		`value (random). Must be one of counter, semver, timestamp, uuid`)
+
+	_, err = gm.AddResourceModelFull(&registry.ResourceModel{
+		Plural:            "files",
+		Singular:          "file",
+		MaxVersions:       3,
+		SetStickyDefault:  registry.PtrBool(false),
+		VersionIdStrategy: registry.VERSIONID_SEMVER,
+	})
+	xNoErr(t, err)
+
+	d1, _ := reg.AddGroup("dirs", "d1")
+	f1, err := d1.AddResource("files", "f1", "1.0.0")
+	xNoErr(t, err)
+	xNoErr(t, reg.Commit())
+
+	_, err = f1.AddVersion("v2")
+	xCheckErr(t, err, `Invalid Version ID "v2", must be a semantic version `+
+		`(e.g. 1.2.3)`)
+	xNoErr(t, reg.Rollback())
+
+	// Server generated IDs bump the patch level by default
+	v, _, err := f1.UpsertVersion("")
+	xNoErr(t, err)
+	xCheckEqual(t, "", v.UID, "1.0.1")
+
+	// Older semvers don't become the default
+	_, err = f1.AddVersion("0.9.0")
+	xNoErr(t, err)
+	xCheckEqual(t, "", f1.Get("defaultversionid"), "1.0.1")
+
+	vIDs, err := f1.GetVersionIDs()
+	xNoErr(t, err)
+	xJSONCheck(t, vIDs, []string{"0.9.0", "1.0.0", "1.0.1"})
+
+	// Pruning removes the lowest semver, not the oldest Version
+	_, err = f1.AddVersion("2.0.0-rc.1")
+	xNoErr(t, err)
+	vIDs, err = f1.GetVersionIDs()
+	xNoErr(t, err)
+	xJSONCheck(t, vIDs, []string{"1.0.0", "1.0.1", "2.0.0-rc.1"})
+	xCheckEqual(t, "", f1.Get("defaultversionid"), "2.0.0-rc.1")
+
+	xHTTP(t, reg, "POST", "/dirs/d1/files/f1?versionbump=huge", "", 400,
+		"Invalid semver bump value \"huge\", must be one of: major, "+
+			"minor, patch\n")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/dirs/d1/files/f1?versionbump=minor",
+		Method: "POST",
+		Code:   201,
+		ResHeaders: []string{
+			"xRegistry-id: 2.1.0",
+			"xRegistry-epoch: 1",
+			"xRegistry-isdefault: true",
+			"xRegistry-self: http://localhost:8181/dirs/d1/files/f1/versions/2.1.0",
+			"xRegistry-createdat: 2024-01-01T12:00:01Z",
+			"xRegistry-modifiedat: 2024-01-01T12:00:01Z",
+			"Content-Location: http://localhost:8181/dirs/d1/files/f1/versions/2.1.0",
+		},
+	})
+}
+
+func TestVersionTags(t *testing.T) {
+	reg := NewRegistry("TestVersionTags")
+	defer PassDeleteReg(t, reg)
+	xCheck(t, reg != nil, "can't create reg")
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 2, true, true, true)
+
+	d1, _ := reg.AddGroup("dirs", "d1")
+	f1, _ := d1.AddResource("files", "f1", "v1")
+	f1.AddVersion("v2")
+	xNoErr(t, f1.SetVersionTag("stable", "v1"))
+
+	// v1 is tagged so v2 should be the one that's pruned
+	f1.AddVersion("v3")
+	d1.AddResource("files", "f2", "v1")
+
+	vIDs, err := f1.GetVersionIDs()
+	xNoErr(t, err)
+	xJSONCheck(t, vIDs, []string{"v1", "v3"})
+
+	xCheckErr(t, f1.SetVersionTag("Bad!", "v1"),
+		`Invalid version tag "Bad!" - must match "^[a-z0-9][a-z0-9_\\-]{0,62}$"`)
+	xCheckErr(t, f1.SetVersionTag("beta", "v2"),
+		`Can't tag Version "v2" as "beta", it doesn't exist`)
+
+	xCheckGet(t, reg, "dirs/d1/files?filter=versiontags.stable=v1", `{
+  "f1": {
+    "id": "f1",
+    "epoch": 1,
+    "self": "http://localhost:8181/dirs/d1/files/f1$meta",
+    "defaultversionid": "v3",
+    "defaultversionurl": "http://localhost:8181/dirs/d1/files/f1/versions/v3$meta",
+    "createdat": "2024-01-01T12:00:01Z",
+    "modifiedat": "2024-01-01T12:00:01Z",
+    "versiontags": {
+      "stable": "v1"
+    },
+
+    "versionscount": 2,
+    "versionsurl": "http://localhost:8181/dirs/d1/files/f1/versions"
+  }
+}
+`)
+
+	xCheckGet(t, reg, "dirs/d1/files/f1/versions/@stable$meta", `{
+  "id": "v1",
+  "epoch": 1,
+  "self": "http://localhost:8181/dirs/d1/files/f1/versions/v1$meta",
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:01Z"
+}
+`)
+	xCheckGet(t, reg, "dirs/d1/files/f1/versions/@beta$meta",
+		"Version tag \"beta\" not found\n")
+
+	// Moving a tag bumps the Resource's epoch
+	xNoErr(t, f1.SetVersionTag("beta", "v3"))
+	xCheckEqual(t, "", f1.Get("epoch"), 2)
+	xJSONCheck(t, f1.GetVersionTags(),
+		map[string]string{"beta": "v3", "stable": "v1"})
+
+	xNoErr(t, f1.SetVersionTags(map[string]any{"beta": nil}, true))
+	xJSONCheck(t, f1.GetVersionTags(), map[string]string{"stable": "v1"})
+
+	// Deleting a Version removes its tags
+	v1, _ := f1.FindVersion("v1", false)
+	xNoErr(t, v1.Delete(""))
+	xJSONCheck(t, f1.GetVersionTags(), map[string]string{})
+
+	// Every move is recorded, along with the Resource's new epoch
+	moves, err := f1.GetVersionTagMoves()
+	xNoErr(t, err)
+	list := []string{}
+	for _, move := range moves {
+		list = append(list, fmt.Sprintf("%s:%s->%s", move.Tag, move.From,
+			move.To))
+	}
+	xJSONCheck(t, list, []string{"stable:->v1", "beta:->v3", "beta:v3->",
+		"stable:v1->"})
+	xCheckEqual(t, "", moves[1].Epoch, 2)
+}
+
+func TestVersionRetention(t *testing.T) {
+	reg := NewRegistry("TestVersionRetention")
+	defer PassDeleteReg(t, reg)
+	xCheck(t, reg != nil, "can't create reg")
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	_, err := gm.AddResourceModelFull(&registry.ResourceModel{
+		Plural:    "files",
+		Singular:  "file",
+		Retention: &registry.RetentionPolicy{MaxAge: "forever"},
+	})
+	xCheckErr(t, err, `Resource "files" has an invalid 'retention.maxage' `+
+		`value: "forever" is not a valid duration`)
+
+	_, err = gm.AddResourceModelFull(&registry.ResourceModel{
+		Plural:    "files",
+		Singular:  "file",
+		Retention: &registry.RetentionPolicy{KeepLastPerMajor: 2},
+	})
+	xCheckErr(t, err, `Resource "files" can only use `+
+		`'retention.keeplastpermajor' with a 'versionidstrategy' of "semver"`)
+
+	rm, err := gm.AddResourceModelFull(&registry.ResourceModel{
+		Plural:    "files",
+		Singular:  "file",
+		Retention: &registry.RetentionPolicy{MaxAge: "30d"},
+	})
+	xNoErr(t, err)
+	_, err = rm.AddAttr("ref", registry.XID)
+	xNoErr(t, err)
+
+	d1, _ := reg.AddGroup("dirs", "d1")
+	f1, _ := d1.AddResource("files", "f1", "v1")
+	v1, _ := f1.FindVersion("v1", false)
+	xNoErr(t, v1.SetSave("createdat", "2020-01-01T12:00:00Z"))
+	v2, _ := f1.AddVersion("v2")
+	xNoErr(t, v2.SetSave("createdat", "2020-01-01T12:00:00Z"))
+	xNoErr(t, f1.SetVersionTag("stable", "v2"))
+	f1.AddVersion("v3")
+
+	// f2's old Version is still referenced, so it's kept too
+	f2, _ := d1.AddResource("files", "f2", "v1")
+	f2v1, _ := f2.FindVersion("v1", false)
+	xNoErr(t, f2v1.SetSave("createdat", "2020-01-01T12:00:00Z"))
+	f2v2, _ := f2.AddVersion("v2")
+	xNoErr(t, f2v2.SetSave("ref", "/dirs/d1/files/f2/versions/v1"))
+	xNoErr(t, reg.Commit())
+
+	// Dry-run report - the tagged, default and referenced Versions are kept
+	xHTTP(t, reg, "GET", "/retention", "", 200, `{
+  "dirs/d1/files/f1/versions/v1": "older than 30d"
+}
+`)
+	xHTTP(t, reg, "POST", "/retention", "{}", 405,
+		"POST not allowed on /retention\n")
+
+	vIDs, err := f1.GetVersionIDs()
+	xNoErr(t, err)
+	xJSONCheck(t, vIDs, []string{"v1", "v2", "v3"})
+
+	list, skipped, err := reg.ApplyRetention(false)
+	xNoErr(t, err)
+	xJSONCheck(t, list,
+		map[string]string{"dirs/d1/files/f1/versions/v1": "older than 30d"})
+	xJSONCheck(t, skipped, map[string]string{
+		"dirs/d1/files/f2/versions/v1": "older than 30d, but it's " +
+			"referenced by \"/dirs/d1/files/f2/versions/v2\""})
+
+	vIDs, err = f1.GetVersionIDs()
+	xNoErr(t, err)
+	xJSONCheck(t, vIDs, []string{"v2", "v3"})
+	vIDs, err = f2.GetVersionIDs()
+	xNoErr(t, err)
+	xJSONCheck(t, vIDs, []string{"v1", "v2"})
+
+	xHTTP(t, reg, "GET", "/retention", "", 200, "{}\n")
+}
+
+func TestVersionDefaults(t *testing.T) {
+	reg := NewRegistry("TestVersionDefaults")
+	defer PassDeleteReg(t, reg)
+	xCheck(t, reg != nil, "can't create reg")
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	_, err := gm.AddAttribute(&registry.Attribute{
+		Name:           "region",
+		Type:           registry.STRING,
+		ServerRequired: true,
+		Default:        "us",
+	})
+	xNoErr(t, err)
+	_, err = gm.AddAttribute(&registry.Attribute{
+		Name:           "tier",
+		Type:           registry.STRING,
+		ClientRequired: true,
+		ServerRequired: true,
+		Default:        "gold",
+	})
+	xNoErr(t, err)
+
+	rm, _ := gm.AddResourceModel("files", "file", 0, true, true, true)
+	_, err = rm.AddAttribute(&registry.Attribute{
+		Name:           "format",
+		Type:           registry.STRING,
+		ServerRequired: true,
+		Default:        "json",
+		IfValues: registry.IfValues{
+			"json": &registry.IfValue{
+				SiblingAttributes: registry.Attributes{
+					"indent": &registry.Attribute{
+						Name:           "indent",
+						Type:           registry.INTEGER,
+						ServerRequired: true,
+						Default:        2,
+					},
+				},
+			},
+		},
+	})
+	xNoErr(t, err)
+	_, err = rm.AddAttribute(&registry.Attribute{
+		Name:           "meta",
+		Type:           registry.OBJECT,
+		ServerRequired: true,
+		Attributes: registry.Attributes{
+			"owner": &registry.Attribute{
+				Name:           "owner",
+				Type:           registry.STRING,
+				ServerRequired: true,
+				Default:        "nobody",
+			},
+		},
+	})
+	xNoErr(t, err)
+
+	gm, _ = reg.Model.AddGroupModel("teams", "team")
+	_, err = gm.AddAttribute(&registry.Attribute{
+		Name:           "lead",
+		Type:           registry.STRING,
+		ServerRequired: true,
+	})
+	xNoErr(t, err)
+
+	// Defaults are validated just like client values
+	gm, _ = reg.Model.AddGroupModel("tags", "tag")
+	_, err = gm.AddAttribute(&registry.Attribute{
+		Name:           "dir",
+		Type:           registry.XID,
+		ServerRequired: true,
+		Default:        "/dirs/missing",
+	})
+	xNoErr(t, err)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", "{}", 201, `{
+  "id": "d1",
+  "epoch": 1,
+  "self": "http://localhost:8181/dirs/d1",
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:01Z",
+  "region": "us",
+  "tier": "gold",
+
+  "filescount": 0,
+  "filesurl": "http://localhost:8181/dirs/d1/files"
+}
+`)
+
+	// "indent" is only there because "format" defaulted to "json"
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1$meta", "{}", 201, `{
+  "id": "f1",
+  "epoch": 1,
+  "self": "http://localhost:8181/dirs/d1/files/f1$meta",
+  "defaultversionid": "1",
+  "defaultversionurl": "http://localhost:8181/dirs/d1/files/f1/versions/1$meta",
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:01Z",
+  "format": "json",
+  "indent": 2,
+  "meta": {
+    "owner": "nobody"
+  },
+
+  "versionscount": 1,
+  "versionsurl": "http://localhost:8181/dirs/d1/files/f1/versions"
+}
+`)
+
+	// Client provided values win
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/2$meta",
+		`{"format":"json","indent":4,"meta":{"owner":"me"}}`, 201, `{
+  "id": "2",
+  "epoch": 1,
+  "self": "http://localhost:8181/dirs/d1/files/f1/versions/2$meta",
+  "isdefault": true,
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:01Z",
+  "format": "json",
+  "indent": 4,
+  "meta": {
+    "owner": "me"
+  }
+}
+`)
+
+	// The Go APIs get them too
+	d2, err := reg.AddGroup("dirs", "d2")
+	xNoErr(t, err)
+	xCheckEqual(t, "", d2.Get("region"), "us")
+	xCheckEqual(t, "", d2.Get("tier"), "gold")
+
+	// "serverrequired" with no default and no value
+	xHTTP(t, reg, "PUT", "/teams/t1", "{}", 400,
+		`Required property "lead" is missing`+"\n")
+	_, err = reg.AddGroup("teams", "t1")
+	xCheckErr(t, err, `Required property "lead" is missing`)
+	xNoErr(t, reg.Rollback())
+
+	xHTTP(t, reg, "PUT", "/tags/t1", "{}", 400,
+		`Attribute "dir" references an unknown entity (/dirs/missing)`+"\n")
+	xHTTP(t, reg, "PUT", "/tags/t1", `{"dir":"/dirs/d1"}`, 201, `{
+  "id": "t1",
+  "epoch": 1,
+  "self": "http://localhost:8181/tags/t1",
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:01Z",
+  "dir": "/dirs/d1"
+}
+`)
+}
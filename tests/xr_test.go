@@ -1,10 +1,15 @@
 package tests
 
 import (
+	"encoding/json"
+	"net/http"
 	"os"
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/duglin/xreg-github/registry"
 )
 
 var RepoBase = "https://raw.githubusercontent.com/xregistry/spec/main"
@@ -38,4 +43,537 @@ func TestXRBasic(t *testing.T) {
 		}
 		xCheckEqual(t, "", string(out), "")
 	}
+
+	cmd = exec.Command("../xr", "model", "diff", "sample-model.json",
+		"sample-model.json")
+	out, err = cmd.CombinedOutput()
+	xNoErr(t, err)
+	xCheckEqual(t, "", string(out), "No changes\n")
+}
+
+func TestXRCrud(t *testing.T) {
+	reg := NewRegistry("TestXRCrud")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.Commit())
+
+	get := func(path string) map[string]any {
+		t.Helper()
+		return xXRJSON(t, "registry", "get", path)
+	}
+
+	out, err := xXR(t, "registry", "set", "name=myreg", "labels.a=b")
+	xNoErr(t, err)
+	obj := get("")
+	xCheckEqual(t, out, obj["name"], "myreg")
+	xCheckEqual(t, out, obj["labels"], map[string]any{"a": "b"})
+
+	out, err = xXR(t, "registry", "set", "name-")
+	xNoErr(t, err)
+	xCheck(t, get("")["name"] == nil, "name should be gone: %s", out)
+
+	// "null" is just a string, only "name-" deletes it
+	xXRMust(t, "registry", "set", "name=null")
+	xCheckEqual(t, "", get("")["name"], "null")
+	xXRMust(t, "registry", "set", "name-")
+
+	out, err = xXR(t, "registry", "get", "dirs/d9")
+	xCheck(t, err != nil, "should have failed")
+	xCheckEqual(t, "", out, "Not found\n")
+
+	out, err = xXR(t, "groups", "add", "dirs/d1", "name=dir1")
+	xNoErr(t, err)
+	xCheckEqual(t, out, get("dirs/d1")["name"], "dir1")
+
+	out, err = xXR(t, "groups", "add", "dirs/d1")
+	xCheck(t, err != nil, "should have failed")
+	xCheckEqual(t, "", out, "Group \"/dirs/d1\" already exists\n")
+
+	out, err = xXR(t, "groups", "update", "dirs/d1", "name=dir2", "--epoch", "9")
+	xCheck(t, err != nil, "epoch should have been wrong: %s", out)
+
+	out, err = xXR(t, "groups", "update", "dirs/d1", "name=dir2", "--epoch", "1")
+	xNoErr(t, err)
+	xCheckEqual(t, out, get("dirs/d1")["name"], "dir2")
+
+	out, err = xXR(t, "resources", "add", "dirs/d1/files/f1",
+		"-d", `{"description":"my file"}`)
+	xNoErr(t, err)
+	xCheckEqual(t, out, get("dirs/d1/files/f1$meta")["description"],
+		"my file")
+
+	out, err = xXR(t, "versions", "add", "dirs/d1/files/f1/versions/v2")
+	xNoErr(t, err)
+	out, err = xXR(t, "versions", "list", "dirs/d1/files/f1/versions")
+	xNoErr(t, err)
+	xCheckEqual(t, out, len(get("dirs/d1/files/f1/versions")), 2)
+
+	out, err = xXR(t, "versions", "delete", "dirs/d1/files/f1/versions/v2")
+	xNoErr(t, err)
+	out, err = xXR(t, "groups", "delete", "dirs/d1")
+	xNoErr(t, err)
+	xCheckEqual(t, out, len(get("dirs")), 0)
+
+	out, err = xXR(t, "groups", "get", "dirs")
+	xCheck(t, err != nil, "should have failed")
+	xCheckEqual(t, "", out, "Invalid Group path \"dirs\", must be of the "+
+		"form: GROUPS/gID\n")
+}
+
+func TestXRDocuments(t *testing.T) {
+	reg := NewRegistry("TestXRDocuments")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	gm.AddResourceModel("infos", "info", 0, true, true, false)
+	xNoErr(t, reg.Commit())
+
+	dir := t.TempDir()
+	doc1 := dir + "/doc1.json"
+	xNoErr(t, os.WriteFile(doc1, []byte(`{"v":1}`), 0644))
+	doc2 := dir + "/doc2.txt"
+	xNoErr(t, os.WriteFile(doc2, []byte("hello"), 0644))
+
+	obj := xXRJSON(t, "resources", "put", "dirs/d1/files/f1", "--file", doc1,
+		"description=first", "labels.stage=dev")
+	xCheckEqual(t, "", obj["id"], "1")
+	xCheckEqual(t, "", obj["contenttype"], "application/json")
+	xCheckEqual(t, "", obj["description"], "first")
+	xCheckEqual(t, "", obj["labels"], map[string]any{"stage": "dev"})
+
+	// New Version, the server picks the ID
+	obj = xXRJSON(t, "versions", "add", "dirs/d1/files/f1/versions",
+		"--document", doc2)
+	xCheckEqual(t, "", obj["id"], "2")
+	xCheck(t, strings.HasPrefix(obj["contenttype"].(string), "text/plain"),
+		"Bad contenttype: %v", obj["contenttype"])
+
+	obj = xXRJSON(t, "versions", "add", "dirs/d1/files/f1/versions/v3",
+		"--document", doc1, "--setdefaultversionid", "1")
+	xCheckEqual(t, "", obj["id"], "v3")
+	xCheckEqual(t, "", xXRJSON(t, "resources", "get",
+		"dirs/d1/files/f1")["defaultversionid"], "1")
+
+	out := dir + "/out"
+	xXRJSON(t, "resources", "get", "dirs/d1/files/f1", "-o", out)
+	buf, err := os.ReadFile(out)
+	xNoErr(t, err)
+	xCheckEqual(t, "", string(buf), `{"v":1}`)
+
+	xXRJSON(t, "versions", "get", "dirs/d1/files/f1/versions/2", "-o", out)
+	buf, err = os.ReadFile(out)
+	xNoErr(t, err)
+	xCheckEqual(t, "", string(buf), "hello")
+
+	// No documents, so there's no "$meta" and the metadata is the body
+	obj = xXRJSON(t, "resources", "add", "dirs/d1/infos/i1",
+		"description=info")
+	xCheckEqual(t, "", obj["description"], "info")
+	obj = xXRJSON(t, "versions", "update", "dirs/d1/infos/i1/versions/1",
+		"name=one")
+	xCheckEqual(t, "", obj["name"], "one")
+	obj = xXRJSON(t, "resources", "get", "dirs/d1/infos/i1")
+	xCheckEqual(t, "", obj["self"], "http://localhost:8181/dirs/d1/infos/i1")
+	xCheckEqual(t, "", obj["name"], "one")
+
+	res, err := xXR(t, "resources", "put", "dirs/d1/infos/i1", "--file", doc1)
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", res, "The \"dirs/infos\" Resource type doesn't "+
+		"have documents\n")
+	res, err = xXR(t, "resources", "get", "dirs/d1/infos/i1", "-o", out)
+	xCheck(t, err != nil, "Should have failed")
+	xCheck(t, strings.HasPrefix(res, "Unknown output format"), "Bad: %s", res)
+}
+
+func TestXROutput(t *testing.T) {
+	reg := NewRegistry("TestXROutput")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.Commit())
+
+	xXRMust(t, "groups", "add", "dirs/d1", "name=one")
+	xXRMust(t, "groups", "add", "dirs/d2", "name=two")
+	xXRMust(t, "resources", "add", "dirs/d1/files/f1")
+	xXRMust(t, "versions", "add", "dirs/d1/files/f1/versions/v2")
+
+	xCheckEqual(t, "", xXRMust(t, "groups", "list", "dirs", "-o", "ids"), "d1\nd2\n")
+	xCheckEqual(t, "", xXRMust(t, "groups", "get", "dirs/d2", "-o", "ids"), "d2\n")
+	xCheckEqual(t, "", xXRMust(t, "registry", "get", "dirs", "--query", "*.name"),
+		"one\ntwo\n")
+	xCheckEqual(t, "", xXRMust(t, "groups", "get", "dirs/d1", "--query", "epoch"),
+		"1\n")
+	xCheckEqual(t, "", xXRMust(t, "resources", "get", "dirs/d1/files/f1",
+		"--query", "versionscount"), "2\n")
+	xCheckEqual(t, "", xXRMust(t, "groups", "get", "dirs/d1", "-o", "yaml",
+		"--query", "name"), "one\n")
+
+	out := xXRMust(t, "resources", "list", "dirs/d1/files", "-o", "table")
+	lines := strings.Split(out, "\n")
+	xCheck(t, len(lines) == 3, "Bad table: %s", out)
+	xCheck(t, strings.Fields(lines[0])[4] == "VERSIONS", "Bad table: %s", out)
+	xCheck(t, strings.Fields(lines[1])[0] == "f1", "Bad table: %s", out)
+
+	out = xXRMust(t, "groups", "get", "dirs/d1", "-o", "yaml")
+	xCheck(t, strings.Contains(out, "\nname: one\n"), "Bad yaml: %s", out)
+
+	obj := map[string]any{}
+	xNoErr(t, json.Unmarshal([]byte(xXRMust(t, "registry", "get", "dirs/d1",
+		"-o", "json")), &obj))
+	xCheckEqual(t, "", obj["id"], "d1")
+
+	out, err := xXR(t, "groups", "get", "dirs/d1", "--query", "foo")
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", out, "Attribute \"foo\" not found\n")
+}
+
+func TestXRConfig(t *testing.T) {
+	config := t.TempDir() + "/xr/config"
+
+	env := []string{"XR_CONFIG=" + config, "XR_SERVER=", "XR_PROFILE="}
+
+	out, err := xXRCmd(t, "", env, "config", "set", "server=http://localhost:8181",
+		"registry=reg-dev", "token=abc")
+	xNoErr(t, err)
+	xCheckEqual(t, "", out, "")
+
+	_, err = xXRCmd(t, "", env, "config", "set", "--profile", "prod",
+		"server=https://example.com", "username=me", "password=pw")
+	xNoErr(t, err)
+
+	out, err = xXRCmd(t, "", env, "config", "list")
+	xNoErr(t, err)
+	xCheckEqual(t, "", out, ""+
+		"   NAME     SERVER                 REGISTRY  AUTH\n"+
+		"*  default  http://localhost:8181  dev       token\n"+
+		"   prod     https://example.com              basic (me)\n")
+
+	info, err := os.Stat(config)
+	xNoErr(t, err)
+	xCheckEqual(t, "", info.Mode().Perm(), os.FileMode(0600))
+
+	_, err = xXRCmd(t, "", env, "config", "use", "prod")
+	xNoErr(t, err)
+	out, _ = xXRCmd(t, "", env, "config", "list")
+	xCheck(t, strings.Contains(out, "\n*  prod "), "Bad list: %s", out)
+
+	out, err = xXRCmd(t, "", env, "config", "use", "foo")
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", out, "Profile \"foo\" not found\n")
+
+	out, err = xXRCmd(t, "", env, "config", "set", "color=blue")
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", out, "Unknown key \"color\", must be one of: "+
+		"server, registry, token, username, password, cacert\n")
+
+	out, err = xXRCmd(t, "", env, "--profile", "foo", "registry", "get")
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", out, "Profile \"foo\" not found in \""+config+"\"\n")
+}
+
+func TestXRModelApply(t *testing.T) {
+	reg := NewRegistry("TestXRModelApply")
+	defer PassDeleteReg(t, reg)
+
+	reg.Model.AddGroupModel("dirs", "dir")
+	xNoErr(t, reg.Commit())
+
+	file := t.TempDir() + "/model.json"
+	xNoErr(t, os.WriteFile(file, []byte(`{
+  "groups": {
+    "dirs": {
+      "plural": "dirs",
+      "singular": "dir",
+      "resources": {
+        "files": { "plural": "files", "singular": "file" }
+      }
+    }
+  }
+}`), 0644))
+
+	// Say "no"
+	out, err := xXRCmd(t, "n\n", nil, "model", "apply", file)
+	xCheck(t, err != nil, "Should have failed")
+	xCheck(t, strings.Contains(out, "groups.dirs.resources.files"),
+		"Missing change: %s", out)
+	xCheck(t, strings.HasSuffix(out, "Apply these changes? [y/N] "+
+		"Model not applied\n"), "Bad output: %s", out)
+
+	// Stale epoch
+	out, err = xXRCmd(t, "", nil, "model", "apply", file, "--yes", "--epoch", "1")
+	xCheck(t, err != nil, "Should have failed")
+	xCheck(t, strings.HasSuffix(out, "Epoch value for the model must be 2\n"),
+		"Bad output: %s", out)
+
+	out, err = xXRCmd(t, "y\n", nil, "model", "apply", file)
+	xNoErr(t, err)
+	xCheck(t, strings.HasSuffix(out, "Model applied\n"), "Bad output: %s", out)
+
+	out, err = xXRCmd(t, "", nil, "model", "apply", file, "--yes")
+	xNoErr(t, err)
+	xCheckEqual(t, "", out, "No changes\n")
+
+	out, err = xXRCmd(t, "", nil, "model", "get")
+	xNoErr(t, err)
+	model := map[string]any{}
+	xNoErr(t, json.Unmarshal([]byte(out), &model))
+	dirs := model["groups"].(map[string]any)["dirs"].(map[string]any)
+	xCheck(t, dirs["resources"] != nil, "Missing files: %s", out)
+
+	out, err = xXRCmd(t, "", nil, "model", "get", "--schema", "jsonschema")
+	xNoErr(t, err)
+	xCheck(t, strings.Contains(out, `"$schema"`), "Not jsonschema: %s", out)
+}
+
+func TestXRModelNormalize(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data string) string {
+		t.Helper()
+		xNoErr(t, os.WriteFile(dir+"/"+name, []byte(data), 0644))
+		return dir + "/" + name
+	}
+
+	a := write("a.json", `{"groups":{"dirs":{"plural":"dirs","singular":"dir"}}}`)
+	b := write("b.json", `{"groups":{"dirs":{"plural":"dirs",`+
+		`"resources":{"files":{"plural":"files","singular":"file"}}}}}`)
+	c := write("c.json", `{"groups":{"dirs":{"singular":"d"}}}`)
+
+	// Each file on its own, none are skipped
+	out, err := xXR(t, "model", "normalize", a, b)
+	xNoErr(t, err)
+	xCheck(t, strings.HasPrefix(out, a+":\n{"), "Missing a.json: %s", out)
+	xCheck(t, strings.Contains(out, "\n"+b+":\n{"), "Missing b.json: %s", out)
+
+	out, err = xXR(t, "model", "normalize", "--merge", a, b)
+	xNoErr(t, err)
+	model := map[string]any{}
+	xNoErr(t, json.Unmarshal([]byte(out), &model))
+	dirs := model["groups"].(map[string]any)["dirs"].(map[string]any)
+	xCheckEqual(t, "", dirs["singular"], "dir")
+	xCheck(t, dirs["resources"] != nil, "Missing files: %s", out)
+
+	out, err = xXR(t, "model", "normalize", "--merge", a, c)
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", out, `Conflicting definitions of `+
+		`"groups.dirs.singular": "dir" in "`+a+`", and "d" in "`+c+`"`+"\n")
+
+	// The merged model must be valid
+	out, err = xXR(t, "model", "normalize", "--merge", b)
+	xCheck(t, err != nil, "Should have failed")
+	xCheck(t, strings.HasPrefix(out, "Merged model: Invalid Group "+
+		"'singular' value"), "Bad output: %s", out)
+}
+
+func TestXRExportImport(t *testing.T) {
+	reg := NewRegistry("TestXRExportImport")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.Commit())
+
+	get := func(path string) map[string]any {
+		t.Helper()
+		return xXRJSON(t, "registry", "get", path)
+	}
+
+	xNoErr(t, reg.SetSave("name", "myreg"))
+	d1, err := reg.AddGroup("dirs", "d1")
+	xNoErr(t, err)
+	f1, err := d1.AddResource("files", "f1", "v2")
+	xNoErr(t, err)
+	v2, err := f1.FindVersion("v2", false)
+	xNoErr(t, err)
+	xNoErr(t, v2.SetSave("contenttype", "application/json"))
+	xNoErr(t, v2.SetSave("#resource", `{"v":2}`))
+	v1, err := f1.AddVersion("v1")
+	xNoErr(t, err)
+	xNoErr(t, v1.SetSave("#resource", "hello"))
+	v3, err := f1.AddVersion("v3")
+	xNoErr(t, err)
+	xNoErr(t, v3.SetSave("#resourceURL", "http://example.com/f"))
+	xNoErr(t, f1.SetDefault(v2))
+	f2, err := d1.AddResource("files", "f2", "x")
+	xNoErr(t, err)
+	x, err := f2.FindVersion("x", false)
+	xNoErr(t, err)
+	xNoErr(t, x.SetSave("#resource", "x"))
+	xNoErr(t, reg.Commit())
+
+	dir := t.TempDir() + "/export"
+	out, err := xXR(t, "export", dir)
+	xNoErr(t, err)
+	xCheckEqual(t, "", out, "")
+
+	for _, file := range []string{"model.json", "registry.json",
+		"dirs/d1/meta.json", "dirs/d1/files/f1/meta.json",
+		"dirs/d1/files/f1/versions/v1/meta.json",
+		"dirs/d1/files/f1/versions/v2/file.json",
+		"dirs/d1/files/f1/versions/v3/meta.json",
+		"dirs/d1/files/f2/versions/x/meta.json"} {
+		_, err := os.Stat(dir + "/" + file)
+		xNoErr(t, err)
+	}
+
+	out, err = xXR(t, "export", dir)
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", out, "Directory \""+dir+"\" isn't empty\n")
+
+	// Load it into a new Registry, which is now the server's default one
+	reg2 := NewRegistry("TestXRExportImport2")
+	defer PassDeleteReg(t, reg2)
+
+	out, err = xXR(t, "import", dir)
+	xNoErr(t, err)
+	xCheckEqual(t, "", out, "Imported \""+dir+"\"\n")
+
+	xCheckEqual(t, "", get("")["name"], "myreg")
+	meta := get("dirs/d1/files/f1$meta")
+	xCheckEqual(t, "", meta["defaultversionid"], "v2")
+	xCheckEqual(t, "", meta["stickydefaultversion"], true)
+	xCheckEqual(t, "", meta["versionscount"], float64(3))
+
+	out, err = xXR(t, "registry", "get", "dirs/d1/files/f1/versions/v1")
+	xNoErr(t, err)
+	xCheckEqual(t, "", out, "hello")
+	xCheckEqual(t, "", get("dirs/d1/files/f1/versions/v3$meta")["fileurl"],
+		"http://example.com/f")
+	xCheckEqual(t, "", get("dirs/d1/files/f2$meta")["defaultversionid"], "x")
+
+	out, err = xXR(t, "import", dir)
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", out, "The Registry isn't empty\n")
+
+	// And the same thing via the Go API
+	reg3 := NewRegistry("TestXRExportImport3")
+	defer PassDeleteReg(t, reg3)
+
+	xNoErr(t, reg3.ImportDir(dir))
+	xNoErr(t, reg3.Commit())
+	xCheckEqual(t, "", get("dirs/d1/files/f1$meta")["defaultversionid"], "v2")
+	xCheckErr(t, reg3.ImportDir(dir),
+		`Registry "TestXRExportImport3" isn't empty`)
+}
+
+func TestXRServe(t *testing.T) {
+	reg := NewRegistry("TestXRServe")
+	defer PassDeleteReg(t, reg)
+
+	reg.Model.AddGroupModel("dirs", "dir")
+	xNoErr(t, reg.SetSave("name", "myreg"))
+	_, err := reg.AddGroup("dirs", "d1")
+	xNoErr(t, err)
+	xNoErr(t, reg.Commit())
+
+	dir := t.TempDir() + "/export"
+	xCheckEqual(t, "", xXRMust(t, "export", dir), "")
+
+	cmd := exec.Command("../xr", "serve", "-P", "8282", "--db", "testreg",
+		"--name", "TestXRServe2", "--import", dir)
+	xNoErr(t, cmd.Start())
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		if reg2, _ := registry.FindRegistry(nil, "TestXRServe2"); reg2 != nil {
+			reg2.Delete()
+			reg2.Commit()
+		}
+	}()
+
+	var res *http.Response
+	for i := 0; i < 50; i++ {
+		if res, err = http.Get("http://localhost:8282/dirs/d1"); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	xNoErr(t, err)
+	res.Body.Close()
+	xCheckEqual(t, "", res.StatusCode, 200)
+
+	res, err = http.Get("http://localhost:8282/")
+	xNoErr(t, err)
+	obj := map[string]any{}
+	xNoErr(t, json.NewDecoder(res.Body).Decode(&obj))
+	res.Body.Close()
+	xCheckEqual(t, "", obj["id"], "TestXRServe2")
+	xCheckEqual(t, "", obj["name"], "myreg")
+}
+
+func TestXRServeData(t *testing.T) {
+	reg := NewRegistry("TestXRServeData")
+	defer PassDeleteReg(t, reg)
+
+	reg.Model.AddGroupModel("dirs", "dir")
+	xNoErr(t, reg.SetSave("name", "myreg"))
+	_, err := reg.AddGroup("dirs", "d1")
+	xNoErr(t, err)
+	xNoErr(t, reg.Commit())
+
+	dir := t.TempDir() + "/export"
+	xCheckEqual(t, "", xXRMust(t, "export", dir), "")
+	data := t.TempDir() + "/data"
+
+	// Runs "xr serve" on "data" until the returned func is called
+	serve := func(args ...string) func() {
+		cmd := exec.Command("../xr", append([]string{"serve", "-P", "8283",
+			"--data", data, "--name", "TestXRServeData2"}, args...)...)
+		xNoErr(t, cmd.Start())
+
+		var err error
+		for i := 0; i < 50; i++ {
+			var res *http.Response
+			if res, err = http.Get("http://localhost:8283/"); err == nil {
+				res.Body.Close()
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		xNoErr(t, err)
+		return func() {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+	}
+
+	get := func(path string) (int, map[string]any) {
+		res, err := http.Get("http://localhost:8283/" + path)
+		xNoErr(t, err)
+		defer res.Body.Close()
+		obj := map[string]any{}
+		json.NewDecoder(res.Body).Decode(&obj)
+		return res.StatusCode, obj
+	}
+
+	stop := serve("--import", dir)
+	code, obj := get("")
+	xCheckEqual(t, "", code, 200)
+	xCheckEqual(t, "", obj["name"], "myreg")
+	code, _ = get("dirs/d1")
+	xCheckEqual(t, "", code, 200)
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8283/dirs/d2",
+		strings.NewReader(`{}`))
+	res, err := http.DefaultClient.Do(req)
+	xNoErr(t, err)
+	res.Body.Close()
+	xCheckEqual(t, "", res.StatusCode, 201)
+	stop()
+
+	_, err = os.Stat(data + "/registry.db")
+	xNoErr(t, err)
+
+	// It's all still there after a restart, without the import
+	stop = serve()
+	defer stop()
+	code, obj = get("")
+	xCheckEqual(t, "", code, 200)
+	xCheckEqual(t, "", obj["id"], "TestXRServeData2")
+	xCheckEqual(t, "", obj["name"], "myreg")
+	code, _ = get("dirs/d2")
+	xCheckEqual(t, "", code, 200)
 }